@@ -0,0 +1,116 @@
+package chatlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+	"github.com/sjzar/chatlog/pkg/audit"
+	"github.com/sjzar/chatlog/pkg/util"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(auditCmd)
+	auditCmd.Flags().StringVarP(&auditTimeRange, "time", "t", "", "time range, same format as the chatlog API")
+	auditCmd.Flags().StringVarP(&auditFilter.Source, "source", "s", "", "source (http/mcp/export)")
+	auditCmd.Flags().StringVarP(&auditFilter.Caller, "caller", "c", "", "caller")
+	auditCmd.Flags().StringVarP(&auditFilter.Account, "account", "a", "", "account")
+	auditCmd.Flags().StringVar(&auditFilter.Action, "action", "", "action (route, tool or resource)")
+	auditCmd.Flags().StringVarP(&auditFilter.Talker, "talker", "k", "", "talker whose data was accessed")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 100, "show the last n entries, 0 for all")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "print entries as JSON lines")
+	auditCmd.Flags().StringVarP(&auditFile, "file", "f", "", "audit log file, defaults to audit.jsonl in the config directory")
+}
+
+var (
+	auditTimeRange string
+	auditFilter    audit.Filter
+	auditLimit     int
+	auditJSON      bool
+	auditFile      string
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Query the data access audit log",
+	Run: func(cmd *cobra.Command, args []string) {
+		if auditFile == "" {
+			m, err := chatlog.New("")
+			if err != nil {
+				log.Err(err).Msg("failed to create chatlog instance")
+				return
+			}
+			auditFile = m.AuditPath()
+		}
+		if auditTimeRange != "" {
+			start, end, ok := util.TimeRangeOf(auditTimeRange)
+			if !ok {
+				log.Error().Msgf("invalid time range: %s", auditTimeRange)
+				return
+			}
+			auditFilter.Start, auditFilter.End = start, end
+		}
+
+		// 只保留最后 limit 条
+		entries := make([]*audit.Entry, 0)
+		err := audit.Read(auditFile, &auditFilter, func(e *audit.Entry) error {
+			entries = append(entries, e)
+			if auditLimit > 0 && len(entries) > auditLimit {
+				entries = entries[1:]
+			}
+			return nil
+		})
+		if err != nil {
+			if os.IsNotExist(err) {
+				fmt.Println("no audit log found")
+				return
+			}
+			log.Err(err).Msg("failed to read audit log")
+			return
+		}
+
+		for _, e := range entries {
+			if auditJSON {
+				b, _ := json.Marshal(e)
+				fmt.Println(string(b))
+				continue
+			}
+			fmt.Println(formatAuditEntry(e))
+		}
+	},
+}
+
+// formatAuditEntry 以单行文本输出审计记录
+func formatAuditEntry(e *audit.Entry) string {
+	keys := make([]string, 0, len(e.Params))
+	for k := range e.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	params := make([]string, 0, len(keys))
+	for _, k := range keys {
+		params = append(params, k+"="+e.Params[k])
+	}
+
+	line := fmt.Sprintf("%s %-6s %-24s %-20s %-32s count=%d", e.Time.Local().Format(time.DateTime), e.Source, e.Caller, e.Account, e.Action, e.Count)
+	if e.Status != 0 {
+		line += fmt.Sprintf(" status=%d", e.Status)
+	}
+	if len(params) > 0 {
+		line += " params=[" + strings.Join(params, " ") + "]"
+	}
+	if len(e.Talkers) > 0 {
+		line += " talkers=[" + strings.Join(e.Talkers, ",") + "]"
+	}
+	if e.Error != "" {
+		line += " error=" + e.Error
+	}
+	return line
+}
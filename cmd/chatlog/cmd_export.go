@@ -8,6 +8,7 @@ import (
 
 	"github.com/sjzar/chatlog/internal/chatlog"
 	"github.com/sjzar/chatlog/internal/export"
+	"github.com/sjzar/chatlog/pkg/audit"
 	"github.com/sjzar/chatlog/pkg/util"
 
 	"github.com/rs/zerolog/log"
@@ -153,6 +154,17 @@ var exportCmd = &cobra.Command{
 			return
 		}
 
+		count, talkers := database.Tally(messages)
+		audit.Record(audit.Entry{
+			Source:  "export",
+			Caller:  "cli",
+			Account: m.Context().Account,
+			Action:  "cli",
+			Params:  map[string]string{"format": exportFormat, "time": exportTimeRange, "talker": exportTalker, "output": exportOutput},
+			Talkers: talkers,
+			Count:   count,
+		})
+
 		fmt.Printf("Successfully exported chat logs to %s\n", exportOutput)
 	},
 }
@@ -0,0 +1,46 @@
+package chatlog
+
+import (
+	"runtime"
+
+	"github.com/sjzar/chatlog/internal/chatlog"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(mcpCmd)
+	mcpCmd.Flags().StringVarP(&mcpAccount, "account", "a", "", "account in history config, defaults to the last used account")
+	mcpCmd.Flags().StringVarP(&mcpDataDir, "data-dir", "d", "", "data dir")
+	mcpCmd.Flags().StringVarP(&mcpWorkDir, "work-dir", "w", "", "work dir, overrides the history config")
+	mcpCmd.Flags().StringVarP(&mcpPlatform, "platform", "p", runtime.GOOS, "platform")
+	mcpCmd.Flags().IntVarP(&mcpVer, "version", "v", 3, "version")
+	mcpCmd.Flags().StringVarP(&mcpToken, "token", "t", "", "access token, selects the access rule like the HTTP Authorization header")
+}
+
+var (
+	mcpAccount  string
+	mcpDataDir  string
+	mcpWorkDir  string
+	mcpPlatform string
+	mcpVer      int
+	mcpToken    string
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Start MCP server over stdio",
+	Long:  "Start MCP server over stdio, JSON-RPC messages are read from stdin and written to stdout, logs go to stderr",
+	Run: func(cmd *cobra.Command, args []string) {
+		m, err := chatlog.New("")
+		if err != nil {
+			log.Err(err).Msg("failed to create chatlog instance")
+			return
+		}
+		if err := m.CommandMCPServer(mcpAccount, mcpDataDir, mcpWorkDir, mcpPlatform, mcpVer, mcpToken); err != nil {
+			log.Err(err).Msg("failed to start mcp server")
+			return
+		}
+	},
+}
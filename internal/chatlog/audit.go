@@ -0,0 +1,27 @@
+package chatlog
+
+import (
+	"path/filepath"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/pkg/audit"
+)
+
+// AuditFile 审计日志文件名，位于配置目录下
+const AuditFile = "audit.jsonl"
+
+// openAudit 打开审计日志并设为默认记录器，失败时不影响其他功能
+func openAudit(configDir string) {
+	l, err := audit.Open(filepath.Join(configDir, AuditFile))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to open audit log")
+		return
+	}
+	audit.SetDefault(l)
+}
+
+// AuditPath 返回审计日志文件路径
+func (m *Manager) AuditPath() string {
+	return filepath.Join(m.conf.GetConfig().ConfigDir, AuditFile)
+}
@@ -6,6 +6,8 @@ type Config struct {
 	ConfigDir   string          `mapstructure:"-"`
 	LastAccount string          `mapstructure:"last_account" json:"last_account"`
 	History     []ProcessConfig `mapstructure:"history" json:"history"`
+	Webhooks    []WebhookConfig `mapstructure:"webhooks" json:"webhooks"`
+	Access      []AccessConfig  `mapstructure:"access" json:"access"`
 }
 
 type ProcessConfig struct {
@@ -23,6 +25,31 @@ type ProcessConfig struct {
 	Files       []File `mapstructure:"files" json:"files"`
 }
 
+// WebhookConfig 新消息 Webhook 配置，过滤条件为空时推送所有新消息
+type WebhookConfig struct {
+	URL        string  `mapstructure:"url" json:"url"`
+	Secret     string  `mapstructure:"secret" json:"secret"`                       // HMAC-SHA256 签名密钥
+	Talker     string  `mapstructure:"talker" json:"talker"`                       // 多个值用英文逗号分隔
+	Sender     string  `mapstructure:"sender" json:"sender"`                       // 多个值用英文逗号分隔
+	Keyword    string  `mapstructure:"keyword" json:"keyword"`                     // 正则表达式
+	Types      []int64 `mapstructure:"types" json:"types"`                         // 消息类型
+	MaxRetries *int    `mapstructure:"max_retries" json:"max_retries" default:"5"` // 未配置时为 5，0 表示不重试
+	Timeout    *int    `mapstructure:"timeout" json:"timeout" default:"10"`        // 秒，未配置时为 10，0 表示不限制
+}
+
+// AccessConfig 访问控制规则，按令牌限制可访问的聊天对象
+// 聊天对象支持 ID、备注名、昵称，需完全匹配；Deny 优先于 Allow，Allow 为空时允许 Deny 以外的所有聊天对象
+type AccessConfig struct {
+	Name    string   `mapstructure:"name" json:"name"`
+	Token   string   `mapstructure:"token" json:"token"`     // HTTP 请求头 Authorization: Bearer <token>，或 chatlog mcp --token
+	Clients []string `mapstructure:"clients" json:"clients"` // 允许使用该令牌的 MCP 客户端名称，即 initialize 请求中的 clientInfo.name，需同时配置 Token
+	Allow   []string `mapstructure:"allow" json:"allow"`
+	Deny    []string `mapstructure:"deny" json:"deny"`
+
+	// Accounts 当前账号以外可以访问的账号，为空时只能访问当前账号
+	Accounts []string `mapstructure:"accounts" json:"accounts"`
+}
+
 type File struct {
 	Path         string `mapstructure:"path" json:"path"`
 	ModifiedTime int64  `mapstructure:"modified_time" json:"modified_time"`
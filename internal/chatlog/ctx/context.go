@@ -1,6 +1,7 @@
 package ctx
 
 import (
+	"sort"
 	"sync"
 	"time"
 
@@ -15,7 +16,9 @@ type Context struct {
 	conf *conf.Service
 	mu   sync.RWMutex
 
-	History map[string]conf.ProcessConfig
+	History  map[string]conf.ProcessConfig
+	Webhooks []conf.WebhookConfig
+	Access   []conf.AccessConfig
 
 	// 微信账号相关状态
 	Account     string
@@ -37,6 +40,7 @@ type Context struct {
 	// 自动解密
 	AutoDecrypt bool
 	LastSession time.Time
+	decrypts    map[string]DecryptRecord // 数据库文件 -> 最近一次解密结果
 
 	// 当前选中的微信实例
 	Current *wechat.Account
@@ -61,6 +65,8 @@ func New(conf *conf.Service) *Context {
 func (c *Context) loadConfig() {
 	conf := c.conf.GetConfig()
 	c.History = conf.ParseHistory()
+	c.Webhooks = conf.Webhooks
+	c.Access = conf.Access
 	c.SwitchHistory(conf.LastAccount)
 	c.Refresh()
 }
@@ -186,3 +192,71 @@ func (c *Context) UpdateConfig() {
 	conf := c.conf.GetConfig()
 	conf.UpdateHistory(c.Account, pconf)
 }
+
+// NewFromHistory 根据历史账号配置创建上下文，用于在同一服务中访问其他账号的数据，修改不会写回配置
+func NewFromHistory(history conf.ProcessConfig) *Context {
+	c := &Context{
+		Account:     history.Account,
+		Platform:    history.Platform,
+		Version:     history.Version,
+		FullVersion: history.FullVersion,
+		DataDir:     history.DataDir,
+		DataKey:     history.DataKey,
+		WorkDir:     history.WorkDir,
+	}
+	c.Refresh()
+	return c
+}
+
+// GetHistory 返回账号的历史配置
+func (c *Context) GetHistory(account string) (conf.ProcessConfig, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	history, ok := c.History[account]
+	return history, ok
+}
+
+// GetHistories 返回所有历史账号配置，按账号名排序
+func (c *Context) GetHistories() []conf.ProcessConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list := make([]conf.ProcessConfig, 0, len(c.History))
+	for _, history := range c.History {
+		list = append(list, history)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Account < list[j].Account })
+	return list
+}
+
+// DecryptRecord 数据库文件最近一次解密的结果
+type DecryptRecord struct {
+	File  string    `json:"file"` // 相对于数据目录的路径
+	Time  time.Time `json:"time"`
+	Error string    `json:"error,omitempty"`
+}
+
+// RecordDecrypt 记录数据库文件的解密结果
+func (c *Context) RecordDecrypt(file string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.decrypts == nil {
+		c.decrypts = make(map[string]DecryptRecord)
+	}
+	record := DecryptRecord{File: file, Time: time.Now()}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	c.decrypts[file] = record
+}
+
+// GetDecrypts 返回各数据库文件最近一次的解密结果，按文件名排序
+func (c *Context) GetDecrypts() []DecryptRecord {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list := make([]DecryptRecord, 0, len(c.decrypts))
+	for _, record := range c.decrypts {
+		list = append(list, record)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].File < list[j].File })
+	return list
+}
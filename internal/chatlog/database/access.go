@@ -0,0 +1,463 @@
+package database
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+)
+
+var (
+	// AccessResolveInterval 访问控制规则中的名称重新解析为聊天对象 ID 的间隔
+	AccessResolveInterval = time.Minute
+
+	// accessPageSize 过滤消息时每次读取的最少消息数量
+	accessPageSize = 200
+)
+
+// Access 聊天对象访问控制，Deny 优先于 Allow，Allow 为空时允许 Deny 以外的所有聊天对象
+// 规则中的聊天对象支持 ID、备注名、昵称，需完全匹配
+type Access struct {
+	Name  string
+	Allow []string
+	Deny  []string
+
+	// Accounts 当前账号以外可以访问的账号，为空时只能访问当前账号
+	Accounts []string
+
+	allow   map[string]bool
+	deny    map[string]bool
+	clients map[string]bool
+}
+
+func NewAccess(name string, allow, deny []string) *Access {
+	a := &Access{
+		Name:  name,
+		Allow: allow,
+		Deny:  deny,
+		allow: make(map[string]bool),
+		deny:  make(map[string]bool),
+	}
+	for _, v := range allow {
+		a.allow[v] = true
+	}
+	for _, v := range deny {
+		a.deny[v] = true
+	}
+	return a
+}
+
+// allowAccount 判断是否可以访问当前账号以外的账号
+func (a *Access) allowAccount(account string) bool {
+	return slices.Contains(a.Accounts, account)
+}
+
+// accessIDs 规则中的名称解析得到的聊天对象 ID
+type accessIDs struct {
+	allow map[string]bool
+	deny  map[string]bool
+
+	// 聊天对象 ID 的 MD5，用于判断媒体文件路径所属的聊天对象
+	allowMD5  map[string]bool
+	denyMD5   map[string]bool
+	talkerMD5 map[string]bool
+}
+
+// match 判断聊天对象是否可以访问，names 为聊天对象的备注名、昵称等显示名称
+func (a *Access) match(ids *accessIDs, id string, names ...string) bool {
+	if a.deny[id] || ids.deny[id] {
+		return false
+	}
+	for _, name := range names {
+		if name != "" && a.deny[name] {
+			return false
+		}
+	}
+	if len(a.allow) == 0 {
+		return true
+	}
+	if a.allow[id] || ids.allow[id] {
+		return true
+	}
+	for _, name := range names {
+		if name != "" && a.allow[name] {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessRules 访问控制规则集合
+// 没有令牌的请求使用既没有令牌也没有客户端的规则，未配置时不受限制
+type AccessRules struct {
+	byToken  map[string]*Access
+	fallback *Access
+
+	// strict 配置了规则但没有默认规则，没有令牌的 MCP 会话不能访问数据
+	strict bool
+}
+
+func NewAccessRules(list []conf.AccessConfig) *AccessRules {
+	r := &AccessRules{
+		byToken: make(map[string]*Access),
+	}
+	for _, c := range list {
+		a := NewAccess(c.Name, c.Allow, c.Deny)
+		a.Accounts = c.Accounts
+		// 客户端名称由客户端自行上报，只作为令牌的附加限制，不能单独用于选择规则
+		if c.Token == "" && len(c.Clients) > 0 {
+			log.Warn().Msgf("access rule %s has clients but no token, ignored", c.Name)
+			continue
+		}
+		if c.Token != "" {
+			a.clients = make(map[string]bool)
+			for _, client := range c.Clients {
+				a.clients[client] = true
+			}
+			r.byToken[c.Token] = a
+		} else if r.fallback == nil {
+			r.fallback = a
+		}
+	}
+	r.strict = len(list) > 0 && r.fallback == nil
+	return r
+}
+
+// ForToken 返回令牌对应的规则，配置了令牌规则时未知的令牌返回错误
+func (r *AccessRules) ForToken(token string) (*Access, error) {
+	if r == nil {
+		return nil, nil
+	}
+	if token == "" {
+		return r.fallback, nil
+	}
+	if a, ok := r.byToken[token]; ok {
+		return a, nil
+	}
+	if len(r.byToken) > 0 {
+		return nil, errors.Unauthorized()
+	}
+	return r.fallback, nil
+}
+
+// ForClient 返回 MCP 会话使用的规则，a 为会话令牌对应的规则，没有令牌时为 nil
+// 规则配置了客户端名称时，只允许其中的客户端使用该令牌；没有令牌的会话使用默认规则，
+// 配置了规则但没有默认规则时返回错误
+func (r *AccessRules) ForClient(a *Access, name string) (*Access, error) {
+	if r == nil {
+		return a, nil
+	}
+	if a != nil {
+		if len(a.clients) > 0 && !a.clients[name] {
+			return nil, errors.Unauthorized()
+		}
+		return a, nil
+	}
+	if r.strict {
+		return nil, errors.Unauthorized()
+	}
+	return r.fallback, nil
+}
+
+// accessScope 受限服务的访问控制状态
+type accessScope struct {
+	mu         sync.Mutex
+	ids        *accessIDs
+	resolvedAt time.Time
+}
+
+// ForToken 返回 HTTP 令牌对应的数据库服务
+func (s *Service) ForToken(token string) (*Service, error) {
+	a, err := s.base().rules.ForToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.WithAccess(a), nil
+}
+
+// ForClient 返回 MCP 会话对应的数据库服务，a 为会话令牌对应的规则
+func (s *Service) ForClient(a *Access, name string) (*Service, error) {
+	a, err := s.base().rules.ForClient(a, name)
+	if err != nil {
+		return nil, err
+	}
+	return s.WithAccess(a), nil
+}
+
+// WithAccess 返回受访问控制限制的数据库服务，与原服务共享数据库连接
+// 同一规则返回同一个服务，导出任务等按服务区分的状态不会在规则之间共享
+func (s *Service) WithAccess(a *Access) *Service {
+	if a == nil {
+		return s
+	}
+	root := s.base()
+
+	root.scopeMutex.Lock()
+	defer root.scopeMutex.Unlock()
+	if svc, ok := root.scopes[a]; ok {
+		return svc
+	}
+	svc := &Service{
+		ctx:      root.ctx,
+		notifier: root.notifier,
+		root:     root,
+		access:   a,
+		scope:    &accessScope{},
+	}
+	if root.scopes == nil {
+		root.scopes = make(map[*Access]*Service)
+	}
+	root.scopes[a] = svc
+	return svc
+}
+
+// Access 返回服务的访问控制规则，不受限时为 nil
+func (s *Service) Access() *Access {
+	return s.access
+}
+
+// base 返回受限服务对应的原始服务
+func (s *Service) base() *Service {
+	if s.root != nil {
+		return s.root
+	}
+	return s
+}
+
+// accessIDs 返回规则中的名称对应的聊天对象 ID，定期重新解析以适应备注名的修改
+func (s *Service) accessIDs() *accessIDs {
+	sc := s.scope
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.ids != nil && time.Since(sc.resolvedAt) < AccessResolveInterval {
+		return sc.ids
+	}
+
+	ids := &accessIDs{
+		allow:     make(map[string]bool),
+		deny:      make(map[string]bool),
+		allowMD5:  make(map[string]bool),
+		denyMD5:   make(map[string]bool),
+		talkerMD5: make(map[string]bool),
+	}
+	add := func(id string, names ...string) {
+		sum := md5.Sum([]byte(id))
+		hash := hex.EncodeToString(sum[:])
+		ids.talkerMD5[hash] = true
+		for _, v := range append([]string{id}, names...) {
+			if v == "" {
+				continue
+			}
+			if s.access.allow[v] {
+				ids.allow[id] = true
+				ids.allowMD5[hash] = true
+			}
+			if s.access.deny[v] {
+				ids.deny[id] = true
+				ids.denyMD5[hash] = true
+			}
+		}
+	}
+	db := s.base().db
+	if db != nil {
+		if resp, err := db.GetContacts("", 0, 0); err == nil {
+			for _, c := range resp.Items {
+				add(c.UserName, c.Alias, c.Remark, c.NickName)
+			}
+		}
+		if resp, err := db.GetChatRooms("", 0, 0); err == nil {
+			for _, c := range resp.Items {
+				add(c.Name, c.Remark, c.NickName)
+			}
+		}
+		// 缓存未就绪时不保存结果，下次重新解析
+		if db.Ready() {
+			sc.ids = ids
+			sc.resolvedAt = time.Now()
+		}
+	}
+	return ids
+}
+
+// allowed 判断聊天对象是否可以访问
+func (s *Service) allowed(id string, names ...string) bool {
+	if s.access == nil {
+		return true
+	}
+	return s.access.match(s.accessIDs(), id, names...)
+}
+
+// AllowMessage 判断消息是否可以访问
+func (s *Service) AllowMessage(msg *model.Message) bool {
+	return s.allowed(msg.Talker, msg.TalkerName)
+}
+
+// AllowMediaPath 判断数据目录中的媒体文件是否可以访问
+// 路径中包含聊天对象 ID 的 MD5（如图片）时按所属的聊天对象判断；语音、v4 的视频与文件等
+// 无法从路径确定所属聊天对象的媒体，只能通过消息得知，此时不拒绝访问，只拒绝明确不可访问的聊天对象
+func (s *Service) AllowMediaPath(path string) bool {
+	if s.access == nil {
+		return true
+	}
+	ids := s.accessIDs()
+	for _, seg := range strings.Split(filepath.ToSlash(path), "/") {
+		seg = strings.ToLower(seg)
+		if !ids.talkerMD5[seg] {
+			continue
+		}
+		if ids.denyMD5[seg] {
+			return false
+		}
+		return len(s.access.allow) == 0 || ids.allowMD5[seg]
+	}
+	return true
+}
+
+// talkerFilter 受限服务查询消息时使用的聊天对象
+// 未指定聊天对象且配置了 Allow 时只查询允许的聊天对象，返回 false 表示没有可查询的聊天对象
+func (s *Service) talkerFilter(talker string) (string, bool) {
+	if talker != "" || len(s.access.allow) == 0 {
+		return talker, true
+	}
+	list := make([]string, 0)
+	for id := range s.accessIDs().allow {
+		if s.allowed(id) {
+			list = append(list, id)
+		}
+	}
+	return strings.Join(list, ","), len(list) > 0
+}
+
+// scopedMessages 查询消息并过滤不可访问的聊天对象，分批读取以保证分页结果的数量
+func (s *Service) scopedMessages(ctx context.Context, start, end time.Time, talker, sender, keyword string, limit, offset int) ([]*model.Message, error) {
+	talker, ok := s.talkerFilter(talker)
+	if !ok {
+		return []*model.Message{}, nil
+	}
+	db := s.base().db
+
+	if limit <= 0 {
+		messages, err := db.GetMessagesContext(ctx, start, end, talker, sender, keyword, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		ret := s.filterMessages(messages)
+		if offset >= len(ret) {
+			return []*model.Message{}, nil
+		}
+		return ret[offset:], nil
+	}
+
+	ret := make([]*model.Message, 0, limit)
+	size := max(accessPageSize, 2*(offset+limit))
+	for dbOffset := 0; ; dbOffset += size {
+		messages, err := db.GetMessagesContext(ctx, start, end, talker, sender, keyword, size, dbOffset)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, s.filterMessages(messages)...)
+		if len(ret) >= offset+limit || len(messages) < size {
+			break
+		}
+	}
+	if offset >= len(ret) {
+		return []*model.Message{}, nil
+	}
+	return ret[offset:min(offset+limit, len(ret))], nil
+}
+
+func (s *Service) filterMessages(messages []*model.Message) []*model.Message {
+	ret := make([]*model.Message, 0, len(messages))
+	for _, msg := range messages {
+		if s.AllowMessage(msg) {
+			ret = append(ret, msg)
+		}
+	}
+	return ret
+}
+
+// scopedMessageStats 只统计可访问的聊天对象，结果不使用缓存
+func (s *Service) scopedMessageStats(ctx context.Context, start, end time.Time, talker string) (*model.MessageStats, error) {
+	talker, ok := s.talkerFilter(talker)
+	if !ok {
+		return model.NewMessageStatsCollector(start, end).Result(), nil
+	}
+	return s.base().db.GetMessageStatsFunc(ctx, start, end, talker, func(msg *model.Message) bool {
+		return s.allowed(msg.Talker)
+	})
+}
+
+func (s *Service) scopedContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
+	resp, err := s.base().db.GetContacts(key, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*model.Contact, 0)
+	for _, c := range resp.Items {
+		if s.allowed(c.UserName, c.Alias, c.Remark, c.NickName) {
+			items = append(items, c)
+		}
+	}
+	return &wechatdb.GetContactsResp{Items: paginate(items, limit, offset)}, nil
+}
+
+func (s *Service) scopedChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
+	resp, err := s.base().db.GetChatRooms(key, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*model.ChatRoom, 0)
+	for _, c := range resp.Items {
+		if s.allowed(c.Name, c.Remark, c.NickName) {
+			items = append(items, c)
+		}
+	}
+	return &wechatdb.GetChatRoomsResp{Items: paginate(items, limit, offset)}, nil
+}
+
+func (s *Service) scopedSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	resp, err := s.base().db.GetSessions(key, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*model.Session, 0)
+	for _, session := range resp.Items {
+		if s.allowed(session.UserName, session.NickName) {
+			items = append(items, session)
+		}
+	}
+	return &wechatdb.GetSessionsResp{Items: paginate(items, limit, offset)}, nil
+}
+
+func (s *Service) scopedMedia(_type string, key string) (*model.Media, error) {
+	media, err := s.base().db.GetMedia(_type, key)
+	if err != nil {
+		return nil, err
+	}
+	if !s.AllowMediaPath(media.Path) {
+		return nil, errors.AccessDenied(_type + "/" + key)
+	}
+	return media, nil
+}
+
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
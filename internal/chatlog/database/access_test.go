@@ -0,0 +1,112 @@
+package database
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+)
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestAccessRules(t *testing.T) {
+	rules := NewAccessRules([]conf.AccessConfig{
+		{Name: "assistant", Token: "secret", Clients: []string{"claude-ai"}, Allow: []string{"工作群"}},
+		{Name: "default", Deny: []string{"家人群"}},
+	})
+
+	if a, err := rules.ForToken("secret"); err != nil || a.Name != "assistant" {
+		t.Errorf("ForToken(secret) = %v, %v", a, err)
+	}
+	if _, err := rules.ForToken("wrong"); err == nil {
+		t.Error("unknown token should be rejected")
+	}
+	if a, err := rules.ForToken(""); err != nil || a.Name != "default" {
+		t.Errorf("ForToken(\"\") = %v, %v", a, err)
+	}
+	// 客户端名称只能限制令牌的使用者，不能单独选择规则
+	assistant, _ := rules.ForToken("secret")
+	if a, err := rules.ForClient(assistant, "claude-ai"); err != nil || a.Name != "assistant" {
+		t.Errorf("ForClient(assistant, claude-ai) = %v, %v", a, err)
+	}
+	if _, err := rules.ForClient(assistant, "other"); err == nil {
+		t.Error("token should be rejected for clients not in the rule")
+	}
+	if a, err := rules.ForClient(nil, "claude-ai"); err != nil || a.Name != "default" {
+		t.Errorf("ForClient(nil, claude-ai) = %v, %v", a, err)
+	}
+
+	// 只有客户端名称的规则不生效，没有默认规则时没有令牌的会话不能访问
+	strict := NewAccessRules([]conf.AccessConfig{{Name: "assistant", Clients: []string{"claude-ai"}, Allow: []string{"工作群"}}})
+	if _, err := strict.ForClient(nil, "claude-ai"); err == nil {
+		t.Error("client name alone should not select a rule")
+	}
+
+	// 未配置规则时不受限制
+	empty := NewAccessRules(nil)
+	if a, err := empty.ForToken("anything"); err != nil || a != nil {
+		t.Errorf("empty rules ForToken = %v, %v", a, err)
+	}
+	if a, err := empty.ForClient(nil, "anything"); err != nil || a != nil {
+		t.Errorf("empty rules ForClient = %v, %v", a, err)
+	}
+}
+
+func TestAccessScope(t *testing.T) {
+	root := NewService(&ctx.Context{})
+	work := root.WithAccess(NewAccess("work", []string{"工作群", "wxid_boss"}, []string{"wxid_spy"}))
+	if work != root.WithAccess(work.Access()) {
+		t.Error("WithAccess should return the same service for the same rule")
+	}
+	work.scope.ids = &accessIDs{
+		allow:    map[string]bool{"123@chatroom": true, "wxid_boss": true},
+		deny:     map[string]bool{"wxid_spy": true},
+		allowMD5: map[string]bool{md5Hex("123@chatroom"): true, md5Hex("wxid_boss"): true},
+		denyMD5:  map[string]bool{md5Hex("wxid_spy"): true},
+		talkerMD5: map[string]bool{
+			md5Hex("123@chatroom"): true, md5Hex("wxid_boss"): true, md5Hex("wxid_spy"): true, md5Hex("789@chatroom"): true,
+		},
+	}
+	work.scope.resolvedAt = time.Now()
+
+	tests := []struct {
+		id    string
+		names []string
+		want  bool
+	}{
+		{"123@chatroom", nil, true},
+		{"456@chatroom", []string{"工作群"}, true},
+		{"wxid_boss", nil, true},
+		{"789@chatroom", []string{"家人群"}, false},
+		{"wxid_spy", []string{"工作群"}, false},
+	}
+	for _, tt := range tests {
+		if got := work.allowed(tt.id, tt.names...); got != tt.want {
+			t.Errorf("allowed(%s, %v) = %v, want %v", tt.id, tt.names, got, tt.want)
+		}
+	}
+
+	paths := map[string]bool{
+		"FileStorage/MsgAttach/" + md5Hex("123@chatroom") + "/Image/2024-01/a.dat": true,
+		"msg/attach/" + md5Hex("789@chatroom") + "/2024-01/Img/a.dat":              false,
+		"msg/attach/" + md5Hex("wxid_spy") + "/2024-01/Img/a.dat":                  false,
+		// 无法从路径确定所属聊天对象时不拒绝访问
+		"FileStorage/Video/2024-01/a.mp4":               true,
+		"msg/video/2024-01/" + md5Hex("a.mp4") + ".mp4": true,
+		"": true,
+	}
+	for path, want := range paths {
+		if got := work.AllowMediaPath(path); got != want {
+			t.Errorf("AllowMediaPath(%s) = %v, want %v", path, got, want)
+		}
+	}
+	if !root.AllowMediaPath("FileStorage/Video/2024-01/a.mp4") {
+		t.Error("unrestricted service should allow all media")
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// AccountInfo 可访问的账号信息
+type AccountInfo struct {
+	Account     string `json:"account"`
+	Platform    string `json:"platform"`
+	Version     int    `json:"version"`
+	FullVersion string `json:"fullVersion"`
+	Current     bool   `json:"current"` // 是否为当前选中的账号
+	Loaded      bool   `json:"loaded"`  // 数据库是否已加载
+}
+
+// Account 返回账号对应的数据库服务
+// 账号为空或为当前账号时返回自身，其他账号从历史记录中按需加载，并随当前服务一起停止
+// 受限服务只能访问规则中允许的其他账号
+func (s *Service) Account(account string) (*Service, error) {
+	if s.root != nil {
+		if account != "" && account != s.root.ctx.Account && !s.access.allowAccount(account) {
+			return nil, errors.AccountNotFound(account)
+		}
+		svc, err := s.root.Account(account)
+		if err != nil {
+			return nil, err
+		}
+		return svc.WithAccess(s.access), nil
+	}
+	if account == "" || account == s.ctx.Account {
+		return s, nil
+	}
+
+	s.accountMutex.Lock()
+	defer s.accountMutex.Unlock()
+	if svc, ok := s.accounts[account]; ok {
+		return svc, nil
+	}
+
+	history, ok := s.ctx.GetHistory(account)
+	if !ok || history.WorkDir == "" {
+		return nil, errors.AccountNotFound(account)
+	}
+	svc := NewService(ctx.NewFromHistory(history))
+	if err := svc.Start(); err != nil {
+		return nil, err
+	}
+	if s.accounts == nil {
+		s.accounts = make(map[string]*Service)
+	}
+	s.accounts[account] = svc
+	return svc, nil
+}
+
+// Accounts 返回可访问的账号列表，当前账号排在第一位
+func (s *Service) Accounts() []AccountInfo {
+	if s.root != nil {
+		list := make([]AccountInfo, 0)
+		for _, info := range s.root.Accounts() {
+			if info.Current || s.access.allowAccount(info.Account) {
+				list = append(list, info)
+			}
+		}
+		return list
+	}
+	s.accountMutex.Lock()
+	defer s.accountMutex.Unlock()
+
+	list := make([]AccountInfo, 0)
+	if s.ctx.Account != "" {
+		list = append(list, AccountInfo{
+			Account:     s.ctx.Account,
+			Platform:    s.ctx.Platform,
+			Version:     s.ctx.Version,
+			FullVersion: s.ctx.FullVersion,
+			Current:     true,
+			Loaded:      s.db != nil,
+		})
+	}
+	for _, history := range s.ctx.GetHistories() {
+		if history.Account == s.ctx.Account || history.WorkDir == "" {
+			continue
+		}
+		_, loaded := s.accounts[history.Account]
+		list = append(list, AccountInfo{
+			Account:     history.Account,
+			Platform:    history.Platform,
+			Version:     history.Version,
+			FullVersion: history.FullVersion,
+			Loaded:      loaded,
+		})
+	}
+	return list
+}
+
+// GetContext 返回数据库服务对应账号的上下文
+func (s *Service) GetContext() *ctx.Context {
+	return s.ctx
+}
+
+// stopAccounts 关闭按需加载的其他账号
+func (s *Service) stopAccounts() {
+	s.accountMutex.Lock()
+	defer s.accountMutex.Unlock()
+	for _, svc := range s.accounts {
+		svc.Stop()
+	}
+	s.accounts = nil
+}
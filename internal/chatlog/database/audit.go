@@ -0,0 +1,41 @@
+package database
+
+import (
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+)
+
+// Tally 统计查询结果的条目数与涉及的聊天对象，用于记录审计日志
+func Tally(data interface{}) (count int, talkers []string) {
+	switch v := data.(type) {
+	case []*model.Message:
+		seen := make(map[string]bool)
+		for _, m := range v {
+			if !seen[m.Talker] {
+				seen[m.Talker] = true
+				talkers = append(talkers, m.Talker)
+			}
+		}
+		return len(v), talkers
+	case *model.Message:
+		return 1, []string{v.Talker}
+	case *model.Contact:
+		return 1, nil
+	case *model.ChatRoom:
+		return 1, []string{v.Name}
+	case *wechatdb.GetContactsResp:
+		return len(v.Items), nil
+	case *wechatdb.GetChatRoomsResp:
+		return len(v.Items), nil
+	case *wechatdb.GetSessionsResp:
+		return len(v.Items), nil
+	case *model.MessageStats:
+		for _, t := range v.Talkers {
+			talkers = append(talkers, t.Key)
+		}
+		return v.Total, talkers
+	case *model.Media:
+		return 1, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,264 @@
+package database
+
+import (
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+var (
+	// NotifyDelay 数据库变更后等待多久再检查新消息，用于合并短时间内的多次变更
+	NotifyDelay = 2 * time.Second
+
+	// NotifyRecheck 消息时间只精确到秒，最后消息时间在此范围内的会话即使时间未变化，
+	// 也会重新检查与水位同一秒内写入的新消息
+	NotifyRecheck = time.Minute
+)
+
+// MessageHandler 新消息处理函数，需要尽快返回，不能阻塞检查流程
+type MessageHandler func(messages []*model.Message)
+
+// MessageFilter 新消息过滤条件，各条件之间为"且"关系，条件为空时不过滤
+type MessageFilter struct {
+	Talkers []string
+	Senders []string
+	Keyword *regexp.Regexp
+	Types   []int64
+}
+
+// NewMessageFilter 创建过滤条件，talker 与 sender 支持以英文逗号分隔的多个值
+func NewMessageFilter(talker, sender string) *MessageFilter {
+	return &MessageFilter{
+		Talkers: util.Str2List(talker, ","),
+		Senders: util.Str2List(sender, ","),
+	}
+}
+
+// Match 判断消息是否符合过滤条件，支持 ID 或显示名称匹配
+func (f *MessageFilter) Match(msg *model.Message) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.Talkers) > 0 && !matchAny(f.Talkers, msg.Talker, msg.TalkerName) {
+		return false
+	}
+	if len(f.Senders) > 0 && !matchAny(f.Senders, msg.Sender, msg.SenderName) {
+		return false
+	}
+	if len(f.Types) > 0 {
+		matched := false
+		for _, t := range f.Types {
+			if msg.Type == t {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if f.Keyword != nil && !f.Keyword.MatchString(msg.PlainTextContent()) {
+		return false
+	}
+	return true
+}
+
+func matchAny(list []string, values ...string) bool {
+	for _, item := range list {
+		for _, v := range values {
+			if v != "" && v == item {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// watermark 记录每个聊天对象已推送的最后一条消息
+// 与 time 同一秒的消息按 seq 判断是否已推送
+type watermark struct {
+	time time.Time
+	seq  int64
+}
+
+// after 判断消息是否在水位之后
+func (m watermark) after(msg *model.Message) bool {
+	return msg.Time.After(m.time) || (msg.Time.Equal(m.time) && msg.Seq > m.seq)
+}
+
+// notifier 基于会话列表的最后消息时间与消息 Seq 检测新消息
+type notifier struct {
+	mu         sync.Mutex
+	marks      map[string]watermark
+	since      time.Time
+	handlers   map[int]MessageHandler
+	nextID     int
+	timer      *time.Timer
+	checkMutex sync.Mutex
+}
+
+func newNotifier() *notifier {
+	return &notifier{
+		marks:    make(map[string]watermark),
+		handlers: make(map[int]MessageHandler),
+	}
+}
+
+// Subscribe 订阅新消息，返回取消订阅函数
+func (s *Service) Subscribe(handler MessageHandler) (unsubscribe func()) {
+	if s.access != nil {
+		// 受限的服务只推送可访问的聊天对象的消息
+		next := handler
+		handler = func(messages []*model.Message) {
+			if messages = s.filterMessages(messages); len(messages) > 0 {
+				next(messages)
+			}
+		}
+	}
+	n := s.notifier
+	n.mu.Lock()
+	id := n.nextID
+	n.nextID++
+	n.handlers[id] = handler
+	n.mu.Unlock()
+
+	return func() {
+		n.mu.Lock()
+		delete(n.handlers, id)
+		n.mu.Unlock()
+	}
+}
+
+// NotifyChanged 通知数据库已变更，将在 NotifyDelay 之后检查新消息
+func (s *Service) NotifyChanged() {
+	n := s.notifier
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.timer != nil {
+		n.timer.Stop()
+	}
+	n.timer = time.AfterFunc(NotifyDelay, s.checkNewMessages)
+}
+
+// watchChanges 监听消息数据库文件变更，并初始化各聊天对象的水位
+func (s *Service) watchChanges() {
+	callback := func(event fsnotify.Event) error {
+		if event.Op.Has(fsnotify.Create) {
+			s.NotifyChanged()
+		}
+		return nil
+	}
+	for _, name := range []string{"message", "session"} {
+		if err := s.db.SetCallback(name, callback); err != nil {
+			log.Debug().Err(err).Msgf("failed to watch %s db", name)
+		}
+	}
+
+	s.resetWatermarks()
+}
+
+// resetWatermarks 以当前会话列表的最后消息时间作为各聊天对象的初始水位
+// 最近有消息的会话查询最后一秒内的消息 seq，其他会话的最后一秒内不会再有新消息
+func (s *Service) resetWatermarks() {
+	marks := make(map[string]watermark)
+	if resp, err := s.db.GetSessions("", 0, 0); err == nil {
+		for _, session := range resp.Items {
+			mark := watermark{time: session.NTime, seq: math.MaxInt64}
+			if time.Since(session.NTime) < NotifyRecheck {
+				mark.seq = 0
+				if messages, err := s.db.GetMessages(session.NTime, session.NTime, session.UserName, "", "", 0, 0); err == nil {
+					for _, msg := range messages {
+						mark.seq = max(mark.seq, msg.Seq)
+					}
+				}
+			}
+			marks[session.UserName] = mark
+		}
+	} else {
+		log.Debug().Err(err).Msg("failed to load sessions for watermarks")
+	}
+
+	n := s.notifier
+	n.mu.Lock()
+	n.marks = marks
+	n.since = time.Now().Truncate(time.Second)
+	n.mu.Unlock()
+}
+
+// checkNewMessages 对比会话的最后消息时间，查询水位之后的新消息并分发
+func (s *Service) checkNewMessages() {
+	n := s.notifier
+	n.checkMutex.Lock()
+	defer n.checkMutex.Unlock()
+
+	db := s.db
+	if db == nil {
+		return
+	}
+
+	resp, err := db.GetSessions("", 0, 0)
+	if err != nil {
+		log.Debug().Err(err).Msg("failed to get sessions")
+		return
+	}
+
+	for _, session := range resp.Items {
+		n.mu.Lock()
+		mark, ok := n.marks[session.UserName]
+		if !ok {
+			// 水位初始化之后新出现的会话，初始化所在的一秒内的消息也是新消息
+			mark = watermark{time: n.since}
+		}
+		n.mu.Unlock()
+		if session.NTime.Before(mark.time) || (session.NTime.Equal(mark.time) && time.Since(mark.time) >= NotifyRecheck) {
+			continue
+		}
+
+		messages, err := db.GetMessages(mark.time, time.Now(), session.UserName, "", "", 0, 0)
+		if err != nil {
+			log.Debug().Err(err).Msgf("failed to get new messages of %s", session.UserName)
+			continue
+		}
+
+		newMessages := make([]*model.Message, 0)
+		for _, msg := range messages {
+			if mark.after(msg) {
+				newMessages = append(newMessages, msg)
+			}
+		}
+
+		// 会话已更新但消息库尚未写入时保持水位不变，等待下次检查
+		if len(newMessages) == 0 {
+			continue
+		}
+
+		last := newMessages[len(newMessages)-1]
+		n.mu.Lock()
+		n.marks[session.UserName] = watermark{time: last.Time, seq: last.Seq}
+		n.mu.Unlock()
+
+		s.dispatch(newMessages)
+	}
+}
+
+// dispatch 将新消息分发给所有订阅者
+func (s *Service) dispatch(messages []*model.Message) {
+	n := s.notifier
+	n.mu.Lock()
+	handlers := make([]MessageHandler, 0, len(n.handlers))
+	for _, handler := range n.handlers {
+		handlers = append(handlers, handler)
+	}
+	n.mu.Unlock()
+
+	for _, handler := range handlers {
+		handler(messages)
+	}
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// testDB 在临时目录中创建 v4 格式的数据库，通过 wechatdb.New 加载，写入消息时同时更新会话的最后消息时间
+type testDB struct {
+	t       *testing.T
+	dir     string
+	message *sql.DB
+	session *sql.DB
+}
+
+func newTestDB(t *testing.T) *testDB {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 上读取的是数据库的临时拷贝，看不到之后写入的消息")
+	}
+	dir := t.TempDir()
+	open := func(name string, stmts ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db
+	}
+	open("contact.db",
+		"CREATE TABLE contact (username TEXT, local_type INTEGER, alias TEXT, remark TEXT, nick_name TEXT)",
+		"CREATE TABLE chat_room (username TEXT, owner TEXT, ext_buffer BLOB)",
+		"INSERT INTO contact VALUES ('wxid_a', 1, '', '', 'A'), ('wxid_b', 1, '', '', 'B')",
+	)
+	return &testDB{
+		t:   t,
+		dir: dir,
+		session: open("session.db",
+			"CREATE TABLE SessionTable (username TEXT PRIMARY KEY, summary TEXT, last_timestamp INTEGER, last_msg_sender TEXT, last_sender_display_name TEXT, sort_timestamp INTEGER)",
+		),
+		message: open("message_0.db",
+			"CREATE TABLE Timestamp (timestamp INTEGER)",
+			"INSERT INTO Timestamp VALUES (0)",
+			"CREATE TABLE Name2Id (user_name TEXT UNIQUE)",
+		),
+	}
+}
+
+func (f *testDB) add(talker string, seq int64, t time.Time) {
+	sum := md5.Sum([]byte(talker))
+	table := "Msg_" + hex.EncodeToString(sum[:])
+	for _, stmt := range []struct {
+		db    *sql.DB
+		query string
+		args  []interface{}
+	}{
+		{f.message, "CREATE TABLE IF NOT EXISTS " + table + " (sort_seq INTEGER, server_id INTEGER, local_type INTEGER, real_sender_id INTEGER, create_time INTEGER, message_content BLOB, packed_info_data BLOB, status INTEGER)", nil},
+		{f.message, "INSERT OR IGNORE INTO Name2Id VALUES (?)", []interface{}{talker}},
+		{f.message, "INSERT INTO " + table + " SELECT ?, 0, 1, rowid, ?, 'hello', NULL, 0 FROM Name2Id WHERE user_name = ?", []interface{}{seq, t.Unix(), talker}},
+		{f.session, "INSERT OR REPLACE INTO SessionTable VALUES (?, 'hello', ?, ?, '', ?)", []interface{}{talker, t.Unix(), talker, t.Unix()}},
+	} {
+		if _, err := stmt.db.Exec(stmt.query, stmt.args...); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+}
+
+func TestCheckNewMessages(t *testing.T) {
+	base := time.Now().Add(-10 * time.Second).Truncate(time.Second)
+	ds := newTestDB(t)
+	ds.add("wxid_a", 1, base)
+	ds.add("wxid_b", 2, base)
+
+	s := NewService(&ctx.Context{WorkDir: ds.dir, Platform: "windows", Version: 4})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	var mu sync.Mutex
+	var all, scoped []int64
+	collect := func(seqs *[]int64) MessageHandler {
+		return func(messages []*model.Message) {
+			mu.Lock()
+			defer mu.Unlock()
+			for _, msg := range messages {
+				*seqs = append(*seqs, msg.Seq)
+			}
+		}
+	}
+	s.Subscribe(collect(&all))
+	s.WithAccess(NewAccess("work", []string{"wxid_a"}, nil)).Subscribe(collect(&scoped))
+
+	// 水位初始化之前的消息不推送
+	s.checkNewMessages()
+	if len(all) != 0 {
+		t.Fatalf("old messages dispatched: %v", all)
+	}
+
+	// 与初始水位同一秒、在初始化之后写入的消息
+	ds.add("wxid_a", 3, base)
+	s.checkNewMessages()
+
+	ds.add("wxid_a", 4, base.Add(time.Second))
+	ds.add("wxid_b", 5, base.Add(time.Second))
+	s.checkNewMessages()
+	if mark := s.notifier.marks["wxid_a"]; mark.seq != 4 || !mark.time.Equal(base.Add(time.Second)) {
+		t.Errorf("watermark of wxid_a = %+v", mark)
+	}
+	if mark := s.notifier.marks["wxid_b"]; mark.seq != 5 {
+		t.Errorf("watermark of wxid_b = %+v", mark)
+	}
+
+	// 没有新消息时再次检查不会重复推送，与水位同一秒的新消息按 seq 推送
+	s.checkNewMessages()
+	ds.add("wxid_a", 6, base.Add(time.Second))
+	s.checkNewMessages()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if got := seqSet(all); len(all) != 4 || !got[3] || !got[4] || !got[5] || !got[6] {
+		t.Errorf("dispatched = %v, want [3 4 5 6]", all)
+	}
+	// 受限的订阅者只收到可访问的聊天对象的消息
+	if len(scoped) != 3 || scoped[0] != 3 || scoped[1] != 4 || scoped[2] != 6 {
+		t.Errorf("scoped dispatched = %v, want [3 4 6]", scoped)
+	}
+}
+
+func seqSet(seqs []int64) map[int64]bool {
+	ret := make(map[int64]bool, len(seqs))
+	for _, seq := range seqs {
+		ret[seq] = true
+	}
+	return ret
+}
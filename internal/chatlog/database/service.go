@@ -1,22 +1,43 @@
 package database
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
 	"github.com/sjzar/chatlog/internal/model"
 	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/internal/wechatdb/repository"
 )
 
 type Service struct {
 	ctx *ctx.Context
 	db  *wechatdb.DB
+
+	notifier *notifier
+
+	accountMutex sync.Mutex
+	accounts     map[string]*Service
+
+	// 访问控制，受限的服务由 WithAccess 创建，与 root 共享数据库
+	rules      *AccessRules
+	root       *Service
+	access     *Access
+	scope      *accessScope
+	scopeMutex sync.Mutex
+	scopes     map[*Access]*Service
 }
 
 func NewService(ctx *ctx.Context) *Service {
-	return &Service{
-		ctx: ctx,
+	s := &Service{
+		ctx:      ctx,
+		notifier: newNotifier(),
 	}
+	if ctx != nil {
+		s.rules = NewAccessRules(ctx.Access)
+	}
+	return s
 }
 
 func (s *Service) Start() error {
@@ -25,10 +46,12 @@ func (s *Service) Start() error {
 		return err
 	}
 	s.db = db
+	s.watchChanges()
 	return nil
 }
 
 func (s *Service) Stop() error {
+	s.stopAccounts()
 	if s.db != nil {
 		s.db.Close()
 	}
@@ -37,32 +60,108 @@ func (s *Service) Stop() error {
 }
 
 func (s *Service) GetDB() *wechatdb.DB {
-	return s.db
+	return s.base().db
 }
 
 func (s *Service) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
-	return s.db.GetMessages(start, end, talker, sender, keyword, limit, offset)
+	return s.GetMessagesContext(context.Background(), start, end, talker, sender, keyword, limit, offset)
+}
+
+// GetMessagesContext 查询消息，ctx 取消时中止查询
+func (s *Service) GetMessagesContext(ctx context.Context, start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
+	if s.access != nil {
+		return s.scopedMessages(ctx, start, end, talker, sender, keyword, limit, offset)
+	}
+	return s.db.GetMessagesContext(ctx, start, end, talker, sender, keyword, limit, offset)
+}
+
+func (s *Service) GetMessageStats(start, end time.Time, talker string) (*model.MessageStats, error) {
+	return s.GetMessageStatsContext(context.Background(), start, end, talker)
+}
+
+// GetMessageStatsContext 统计消息，ctx 取消时中止统计
+func (s *Service) GetMessageStatsContext(ctx context.Context, start, end time.Time, talker string) (*model.MessageStats, error) {
+	if s.access != nil {
+		return s.scopedMessageStats(ctx, start, end, talker)
+	}
+	return s.db.GetMessageStatsContext(ctx, start, end, talker)
 }
 
 func (s *Service) GetContacts(key string, limit, offset int) (*wechatdb.GetContactsResp, error) {
+	if s.access != nil {
+		return s.scopedContacts(key, limit, offset)
+	}
 	return s.db.GetContacts(key, limit, offset)
 }
 
 func (s *Service) GetChatRooms(key string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
+	if s.access != nil {
+		return s.scopedChatRooms(key, limit, offset)
+	}
 	return s.db.GetChatRooms(key, limit, offset)
 }
 
 // GetSession retrieves session information
 func (s *Service) GetSessions(key string, limit, offset int) (*wechatdb.GetSessionsResp, error) {
+	if s.access != nil {
+		return s.scopedSessions(key, limit, offset)
+	}
 	return s.db.GetSessions(key, limit, offset)
 }
 
 func (s *Service) GetMedia(_type string, key string) (*model.Media, error) {
+	if s.access != nil {
+		return s.scopedMedia(_type, key)
+	}
 	return s.db.GetMedia(_type, key)
 }
 
+// CompleteTalkers 补全联系人与群聊，受限服务只返回可访问的对象
+func (s *Service) CompleteTalkers(key string, contacts, chatRooms bool) []repository.Completion {
+	list := s.base().db.CompleteTalkers(key, contacts, chatRooms)
+	if s.access == nil {
+		return list
+	}
+	ret := make([]repository.Completion, 0, len(list))
+	for _, c := range list {
+		if s.allowed(c.UserName) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+// CompleteChatRoomMembers 补全群成员，受限服务只补全可访问的群聊
+// 受限服务要求 chatRoom 与群聊的 ID、备注名或昵称完全一致，不使用模糊匹配，避免补全其他群聊的成员
+func (s *Service) CompleteChatRoomMembers(chatRoom, key string) []repository.Completion {
+	if s.access != nil {
+		list, err := s.scopedChatRooms(chatRoom, 0, 0)
+		if err != nil {
+			return []repository.Completion{}
+		}
+		var names []string
+		for _, c := range list.Items {
+			if c.Name == chatRoom {
+				names = []string{c.Name}
+				break
+			}
+			if c.Remark == chatRoom || c.NickName == chatRoom {
+				names = append(names, c.Name)
+			}
+		}
+		// 没有完全一致的群聊，或名称对应多个群聊
+		if len(names) != 1 {
+			return []repository.Completion{}
+		}
+		chatRoom = names[0]
+	}
+	return s.base().db.CompleteChatRoomMembers(chatRoom, key)
+}
+
 // Close closes the database connection
 func (s *Service) Close() {
 	// Add cleanup code if needed
-	s.db.Close()
+	if db := s.base().db; db != nil {
+		db.Close()
+	}
 }
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb/repository"
+)
+
+// Status 账号数据的服务状态
+type Status struct {
+	Account     string `json:"account"`
+	Platform    string `json:"platform"`
+	Version     int    `json:"version"`
+	FullVersion string `json:"fullVersion"`
+	DataDir     string `json:"dataDir"`
+	DataUsage   string `json:"dataUsage"`
+	WorkDir     string `json:"workDir"`
+	WorkUsage   string `json:"workUsage"`
+
+	// 自动解密是否运行中，以及各数据库文件最近一次的解密结果
+	AutoDecrypt bool                `json:"autoDecrypt"`
+	Decrypts    []ctx.DecryptRecord `json:"decrypts"`
+
+	// 数据库是否已加载且缓存已完成首次加载
+	Ready  bool                     `json:"ready"`
+	Shards []*model.MessageShard    `json:"shards"`
+	Caches []repository.CacheStatus `json:"caches"`
+}
+
+// Ready 数据库是否已加载且缓存已完成首次加载，未就绪时查询会失败
+func (s *Service) Ready() bool {
+	db := s.base().db
+	return db != nil && db.Ready()
+}
+
+// Status 返回账号数据的服务状态
+func (s *Service) Status() *Status {
+	if s.root != nil {
+		return s.root.Status()
+	}
+	status := &Status{
+		Account:     s.ctx.Account,
+		Platform:    s.ctx.Platform,
+		Version:     s.ctx.Version,
+		FullVersion: s.ctx.FullVersion,
+		DataDir:     s.ctx.DataDir,
+		DataUsage:   s.ctx.DataUsage,
+		WorkDir:     s.ctx.WorkDir,
+		WorkUsage:   s.ctx.WorkUsage,
+		AutoDecrypt: s.ctx.AutoDecrypt,
+		Decrypts:    s.ctx.GetDecrypts(),
+		Shards:      make([]*model.MessageShard, 0),
+		Caches:      make([]repository.CacheStatus, 0),
+	}
+	if db := s.db; db != nil {
+		status.Ready = db.Ready()
+		status.Shards = db.GetMessageShards()
+		status.Caches = db.GetCacheStatus()
+	}
+	return status
+}
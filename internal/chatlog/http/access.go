@@ -0,0 +1,48 @@
+package http
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/mcp"
+)
+
+const (
+	// callerKey 请求上下文中保存访问控制规则名称的键，用于审计日志
+	callerKey = "chatlog.caller"
+
+	// TokenCookie 保存访问令牌的 Cookie，供无法设置请求头的图片、语音与 SSE 请求使用
+	TokenCookie = "chatlog_token"
+)
+
+// AccessMiddleware 根据请求头 Authorization: Bearer <token> 选择访问控制规则，没有请求头时使用 TokenCookie
+// 受限的请求使用对应规则的数据库服务，后续的账号、导出等接口均基于该服务
+// 令牌对应的规则同时保存到 MCP 会话中，会话内的请求均使用该规则
+func (s *Service) AccessMiddleware(c *gin.Context) {
+	token := bearerToken(c)
+	db, err := s.db.ForToken(token)
+	if err != nil {
+		errors.Err(c, err)
+		c.Abort()
+		return
+	}
+	if a := db.Access(); a != nil {
+		c.Set(accountKey, db)
+		if token != "" {
+			c.Set(callerKey, "token:"+a.Name)
+			c.Set(mcp.AuthContextKey, a)
+		}
+	}
+	c.Next()
+}
+
+func bearerToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		token, _ := strings.CutPrefix(header, "Bearer ")
+		return strings.TrimSpace(token)
+	}
+	token, _ := c.Cookie(TokenCookie)
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,43 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+)
+
+func TestAccessToken(t *testing.T) {
+	c := &ctx.Context{Access: []conf.AccessConfig{{Name: "work", Token: "secret", Allow: []string{"工作群"}}}}
+	s := NewService(c, database.NewService(c), nil)
+
+	tests := []struct {
+		header, cookie string
+		code           int
+	}{
+		{"", "", http.StatusOK},
+		{"Bearer secret", "", http.StatusOK},
+		{"Bearer wrong", "", http.StatusUnauthorized},
+		// 图片、语音与 SSE 请求无法设置请求头，使用 Cookie 中的令牌
+		{"", "secret", http.StatusOK},
+		{"", "wrong", http.StatusUnauthorized},
+		{"Bearer secret", "wrong", http.StatusOK},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/status", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: TokenCookie, Value: tt.cookie})
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		if w.Code != tt.code {
+			t.Errorf("header %q cookie %q: got %d, want %d", tt.header, tt.cookie, w.Code, tt.code)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/export"
+)
+
+// accountKey 请求上下文中保存账号数据库服务的键
+const accountKey = "chatlog.account"
+
+// AccountMiddleware 根据路径中的 account 参数加载对应账号的数据库服务
+func (s *Service) AccountMiddleware(c *gin.Context) {
+	db, err := s.dbOf(c).Account(c.Param("account"))
+	if err != nil {
+		errors.Err(c, err)
+		c.Abort()
+		return
+	}
+	c.Set(accountKey, db)
+	c.Next()
+}
+
+// dbOf 返回请求对应账号的数据库服务，未指定账号时为当前账号
+func (s *Service) dbOf(c *gin.Context) *database.Service {
+	if v, ok := c.Get(accountKey); ok {
+		return v.(*database.Service)
+	}
+	return s.db
+}
+
+// ctxOf 返回请求对应账号的上下文
+func (s *Service) ctxOf(c *gin.Context) *ctx.Context {
+	if v, ok := c.Get(accountKey); ok {
+		return v.(*database.Service).GetContext()
+	}
+	return s.ctx
+}
+
+// mediaHost 返回纯文本消息中媒体链接使用的地址，指定账号时链接指向该账号的媒体接口
+func mediaHost(c *gin.Context) string {
+	if account := c.Param("account"); account != "" {
+		return c.Request.Host + "/api/v1/accounts/" + url.PathEscape(account)
+	}
+	return c.Request.Host
+}
+
+// exportsOf 返回请求对应账号的导出任务管理器
+func (s *Service) exportsOf(c *gin.Context) *export.JobManager {
+	db := s.dbOf(c)
+
+	s.exportMutex.Lock()
+	defer s.exportMutex.Unlock()
+	m, ok := s.exports[db]
+	if !ok {
+		m = export.NewJobManager(db)
+		s.exports[db] = m
+	}
+	return m
+}
+
+// closeExports 取消所有导出任务，并移除其他账号的任务管理器，其数据库服务会随当前账号一起停止
+func (s *Service) closeExports() {
+	s.exportMutex.Lock()
+	defer s.exportMutex.Unlock()
+	for db, m := range s.exports {
+		m.Close()
+		if db != s.db {
+			delete(s.exports, db)
+		}
+	}
+}
+
+// GetAccounts 返回请求可访问的账号列表
+func (s *Service) GetAccounts(c *gin.Context) {
+	c.JSON(http.StatusOK, s.dbOf(c).Accounts())
+}
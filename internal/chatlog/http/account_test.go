@@ -0,0 +1,65 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+)
+
+func TestAccounts(t *testing.T) {
+	c := &ctx.Context{
+		Account: "alice",
+		History: map[string]conf.ProcessConfig{
+			"alice": {Account: "alice", WorkDir: t.TempDir()},
+			"bob":   {Account: "bob", Platform: "windows", Version: 4, WorkDir: t.TempDir()},
+			"carol": {Account: "carol"}, // 未解密
+		},
+		Access: []conf.AccessConfig{
+			{Name: "assistant", Token: "secret", Allow: []string{"工作群"}},
+		},
+	}
+	s := NewService(c, database.NewService(c), nil)
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/accounts", nil))
+	var accounts []database.AccountInfo
+	if err := json.Unmarshal(w.Body.Bytes(), &accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 2 || accounts[0].Account != "alice" || !accounts[0].Current || accounts[1].Account != "bob" || accounts[1].Loaded {
+		t.Errorf("unexpected accounts: %+v", accounts)
+	}
+
+	for _, account := range []string{"carol", "dave"} {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/accounts/"+account+"/session", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: unexpected status %d", account, w.Code)
+		}
+	}
+
+	// 受限的令牌只能看到并访问当前账号与规则中允许的账号
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/accounts", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	s.router.ServeHTTP(w, req)
+	accounts = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &accounts); err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0].Account != "alice" {
+		t.Errorf("unexpected scoped accounts: %+v", accounts)
+	}
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/accounts/bob/session", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	s.router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("scoped bob: unexpected status %d", w.Code)
+	}
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/pkg/audit"
+)
+
+// auditKey 请求上下文中保存审计记录的键
+const auditKey = "chatlog.audit"
+
+// auditResult 处理请求过程中累计的审计信息，批量查询等场景会并发写入
+type auditResult struct {
+	mu      sync.Mutex
+	count   int
+	talkers []string
+	seen    map[string]bool
+	params  map[string]string
+}
+
+func newAuditResult() *auditResult {
+	return &auditResult{seen: make(map[string]bool), params: make(map[string]string)}
+}
+
+func (r *auditResult) add(data interface{}) {
+	count, talkers := database.Tally(data)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.count += count
+	for _, t := range talkers {
+		if t != "" && !r.seen[t] {
+			r.seen[t] = true
+			r.talkers = append(r.talkers, t)
+		}
+	}
+}
+
+// auditOf 返回请求的审计信息，未经过 AuditMiddleware 的请求返回一个不会被记录的临时对象
+func auditOf(c *gin.Context) *auditResult {
+	if v, ok := c.Get(auditKey); ok {
+		return v.(*auditResult)
+	}
+	return newAuditResult()
+}
+
+// setAudit 将查询结果计入审计记录，可多次调用
+func setAudit(c *gin.Context, data interface{}) {
+	auditOf(c).add(data)
+}
+
+// setAuditParams 补充请求体中的查询参数
+func setAuditParams(c *gin.Context, params map[string]string) {
+	r := auditOf(c)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, v := range params {
+		if v != "" {
+			r.params[k] = v
+		}
+	}
+}
+
+// AuditMiddleware 在请求结束后记录一条数据访问审计日志
+func (s *Service) AuditMiddleware(c *gin.Context) {
+	r := newAuditResult()
+	for k, v := range c.Request.URL.Query() {
+		r.params[k] = strings.Join(v, ",")
+	}
+	for _, p := range c.Params {
+		if p.Key != "account" {
+			r.params[p.Key] = strings.TrimPrefix(p.Value, "/")
+		}
+	}
+	c.Set(auditKey, r)
+
+	c.Next()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e := audit.Entry{
+		Source:  "http",
+		Caller:  callerOf(c),
+		Account: s.ctxOf(c).Account,
+		Action:  c.Request.Method + " " + c.FullPath(),
+		Params:  r.params,
+		Talkers: r.talkers,
+		Count:   r.count,
+		Status:  c.Writer.Status(),
+	}
+	if e.Status >= http.StatusBadRequest {
+		e.Error = http.StatusText(e.Status)
+	}
+	if err := audit.Record(e); err != nil {
+		log.Debug().Err(err).Msg("failed to record audit log")
+	}
+}
+
+// callerOf 返回请求方标识，匹配访问控制规则时使用规则名称，其他令牌使用摘要，避免令牌明文写入日志
+func callerOf(c *gin.Context) string {
+	if caller := c.GetString(callerKey); caller != "" {
+		return caller
+	}
+	if token := bearerToken(c); token != "" {
+		sum := sha256.Sum256([]byte(token))
+		return "token:" + hex.EncodeToString(sum[:])[:8]
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	// MaxBatchQueries 单次批量查询最多包含的查询数量
+	MaxBatchQueries = 100
+	// BatchConcurrency 批量查询中同时执行的查询数量
+	BatchConcurrency = 8
+)
+
+// BatchQueryTypes 批量查询支持的查询类型
+var BatchQueryTypes = []string{"chatlog", "contact", "chatroom", "session"}
+
+// BatchQuery 批量查询中的单个查询，参数与对应的 GET 接口相同
+type BatchQuery struct {
+	ID      string `json:"id,omitempty"` // 调用方自定义的标识，原样返回
+	Type    string `json:"type"`         // chatlog, contact, chatroom, session
+	Time    string `json:"time,omitempty"`
+	Talker  string `json:"talker,omitempty"`
+	Sender  string `json:"sender,omitempty"`
+	Keyword string `json:"keyword,omitempty"`
+	Limit   int    `json:"limit,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+}
+
+// BatchResult 单个查询的结果，顺序与请求中的查询一致
+// 成功时 data 与对应接口 format=json 的返回相同，失败时 error 为错误信息
+type BatchResult struct {
+	ID     string      `json:"id,omitempty"`
+	Type   string      `json:"type"`
+	Status int         `json:"status"`
+	Data   interface{} `json:"data,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// PostBatch 并发执行多个查询，并在一个响应中返回所有结果
+func (s *Service) PostBatch(c *gin.Context) {
+	var queries []BatchQuery
+	if err := c.ShouldBindJSON(&queries); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+	if len(queries) > MaxBatchQueries {
+		errors.Err(c, errors.InvalidArg(fmt.Sprintf("too many queries, max %d", MaxBatchQueries)))
+		return
+	}
+
+	db := s.dbOf(c)
+	ctx := c.Request.Context()
+	results := make([]BatchResult, len(queries))
+	sem := make(chan struct{}, BatchConcurrency)
+	var wg sync.WaitGroup
+	for i, q := range queries {
+		wg.Add(1)
+		go func(i int, q BatchQuery) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			var data interface{}
+			var err error
+			if err = ctx.Err(); err == nil {
+				data, err = runBatchQuery(db, q)
+			}
+			results[i] = BatchResult{ID: q.ID, Type: q.Type, Status: errors.GetCode(err)}
+			if err != nil {
+				results[i].Error = err.Error()
+			} else {
+				results[i].Data = data
+				setAudit(c, data)
+			}
+		}(i, q)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, results)
+}
+
+// runBatchQuery 执行单个查询，查询中的 panic 转换为错误，不影响其他查询
+func runBatchQuery(db *database.Service, q BatchQuery) (data interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Error().Msgf("batch query %s panic: %v", q.Type, r)
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	limit, offset := max(q.Limit, 0), max(q.Offset, 0)
+	switch q.Type {
+	case "chatlog":
+		start, end, ok := util.TimeRangeOf(q.Time)
+		if !ok {
+			return nil, errors.InvalidArg("time")
+		}
+		return db.GetMessages(start, end, q.Talker, q.Sender, q.Keyword, limit, offset)
+	case "contact":
+		return db.GetContacts(q.Keyword, limit, offset)
+	case "chatroom":
+		return db.GetChatRooms(q.Keyword, limit, offset)
+	case "session":
+		return db.GetSessions(q.Keyword, limit, offset)
+	}
+	return nil, errors.InvalidArg("type")
+}
@@ -0,0 +1,25 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+func TestRunBatchQuery(t *testing.T) {
+	for _, tc := range []struct {
+		query BatchQuery
+		code  int
+	}{
+		{BatchQuery{Type: "unknown"}, http.StatusBadRequest},
+		{BatchQuery{Type: "chatlog", Talker: "wxid_a"}, http.StatusBadRequest},
+		// 查询中的 panic 不影响其他查询
+		{BatchQuery{Type: "contact"}, http.StatusInternalServerError},
+	} {
+		_, err := runBatchQuery(nil, tc.query)
+		if code := errors.GetCode(err); code != tc.code {
+			t.Errorf("%+v: got %d (%v), want %d", tc.query, code, err, tc.code)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/export"
+)
+
+const (
+	ExportDir = "exports"
+)
+
+// CreateExport 创建后台导出任务
+func (s *Service) CreateExport(c *gin.Context) {
+	var opts export.JobOptions
+	if err := c.ShouldBindJSON(&opts); err != nil {
+		errors.Err(c, errors.InvalidArg("body"))
+		return
+	}
+
+	ctx := s.ctxOf(c)
+	if ctx.WorkDir == "" {
+		errors.Err(c, errors.InvalidArg("work dir"))
+		return
+	}
+
+	setAuditParams(c, opts.AuditParams())
+	opts.Caller = callerOf(c)
+	opts.Account = ctx.Account
+	job, err := s.exportsOf(c).Create(opts, filepath.Join(ctx.WorkDir, ExportDir), ctx.DataDir)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	setAuditParams(c, map[string]string{"id": job.ID})
+	c.JSON(http.StatusAccepted, job)
+}
+
+// ListExports 返回所有导出任务
+func (s *Service) ListExports(c *gin.Context) {
+	c.JSON(http.StatusOK, s.exportsOf(c).List())
+}
+
+// GetExport 返回导出任务的状态与进度
+func (s *Service) GetExport(c *gin.Context) {
+	job, err := s.exportsOf(c).Get(c.Param("id"))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadExport 下载已完成的导出任务压缩包
+func (s *Service) DownloadExport(c *gin.Context) {
+	id := c.Param("id")
+	path, err := s.exportsOf(c).Archive(id)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.FileAttachment(path, "chatlog_export_"+id+".zip")
+}
+
+// DeleteExport 取消运行中的导出任务，已结束的任务会被删除
+func (s *Service) DeleteExport(c *gin.Context) {
+	job, err := s.exportsOf(c).Cancel(c.Param("id"))
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
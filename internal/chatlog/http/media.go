@@ -0,0 +1,164 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util/dat2img"
+	"github.com/sjzar/chatlog/pkg/util/thumbnail"
+)
+
+const (
+	// MediaCacheControl 通过 MD5 访问的媒体内容不会改变，允许客户端长期缓存
+	MediaCacheControl = "private, max-age=31536000, immutable"
+
+	// DataCacheControl 通过路径访问的文件可能被替换，缓存过期后使用 ETag 重新验证
+	DataCacheControl = "private, max-age=86400"
+)
+
+// serveMediaFile 返回数据目录下的媒体文件，支持 Range 与条件请求
+// key 为媒体文件的 MD5，为空时根据文件修改时间与大小生成 ETag
+// 加密的 .dat 图片会先解密，指定 w 或 h 参数时返回缩略图
+func (s *Service) serveMediaFile(c *gin.Context, path, key string) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "File not found",
+		})
+		return
+	}
+
+	opts, thumb, err := thumbnailOptions(c)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	etag, cacheControl := key, MediaCacheControl
+	if etag == "" {
+		etag, cacheControl = fmt.Sprintf("%x-%x", info.ModTime().UnixNano(), info.Size()), DataCacheControl
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case ext == ".dat" || thumb && isImageExt(ext):
+		if thumb {
+			etag += "-" + opts.String()
+		}
+		// 命中缓存时跳过解密与缩放
+		if cacheHit(c, etag, cacheControl, info.ModTime()) {
+			return
+		}
+		data, contentType, fallback, err := s.imageData(path, s.ctxOf(c).WorkDir, opts, thumb)
+		if err != nil {
+			errors.Err(c, err)
+			return
+		}
+		modTime := info.ModTime()
+		if fallback {
+			// 返回的内容与 ETag 对应的图片不一致，不能被缓存
+			c.Writer.Header().Del("ETag")
+			c.Header("Cache-Control", "no-store")
+			modTime = time.Time{}
+		}
+		serveContent(c, contentType, modTime, bytes.NewReader(data))
+	default:
+		f, err := os.Open(path)
+		if err != nil {
+			errors.Err(c, errors.OpenFileFailed(path, err))
+			return
+		}
+		defer f.Close()
+		setCacheHeaders(c, etag, cacheControl)
+		serveContent(c, mime.TypeByExtension(ext), info.ModTime(), f)
+	}
+}
+
+// imageData 读取图片，解密 .dat 文件并按需生成缩略图
+// 无法解密或缩放时返回原始内容，fallback 为 true；contentType 为空时由内容推断
+func (s *Service) imageData(path, workDir string, opts thumbnail.Options, thumb bool) (data []byte, contentType string, fallback bool, err error) {
+	data, err = os.ReadFile(path)
+	if err != nil {
+		return nil, "", false, errors.ReadFileFailed(path, err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".dat" {
+		out, outExt, err := dat2img.Dat2Image(data)
+		if err != nil {
+			log.Debug().Err(err).Msgf("decrypt image failed: %s", path)
+			return data, "", true, nil
+		}
+		data, ext = out, "."+outExt
+	}
+
+	if thumb && isImageExt(ext) {
+		out, outExt, err := s.getThumbnailer(workDir).Thumbnail(data, opts)
+		if err != nil {
+			log.Debug().Err(err).Msg("create thumbnail failed")
+			fallback = true
+		} else {
+			data, ext = out, "."+outExt
+		}
+	}
+
+	return data, mime.TypeByExtension(ext), fallback, nil
+}
+
+// isImageExt 判断是否为可生成缩略图的图片格式
+func isImageExt(ext string) bool {
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+func setCacheHeaders(c *gin.Context, etag, cacheControl string) {
+	c.Header("ETag", `"`+etag+`"`)
+	c.Header("Cache-Control", cacheControl)
+}
+
+// cacheHit 设置缓存响应头，客户端缓存仍然有效时直接返回 304
+func cacheHit(c *gin.Context, etag, cacheControl string, modTime time.Time) bool {
+	setCacheHeaders(c, etag, cacheControl)
+
+	if inm := c.GetHeader("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == `"`+etag+`"` {
+				c.Status(http.StatusNotModified)
+				return true
+			}
+		}
+		return false
+	}
+
+	if ims := c.GetHeader("If-Modified-Since"); ims != "" && modTime.Unix() > 0 {
+		if t, err := http.ParseTime(ims); err == nil && !modTime.Truncate(time.Second).After(t) {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// serveContent 返回内容，由 http.ServeContent 处理 Range 与条件请求
+// 调用前需要通过 setCacheHeaders 或 cacheHit 设置 ETag
+func serveContent(c *gin.Context, contentType string, modTime time.Time, content io.ReadSeeker) {
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	http.ServeContent(c.Writer, c.Request, "", modTime, content)
+}
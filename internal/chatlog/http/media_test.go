@@ -0,0 +1,104 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util/silk"
+)
+
+func TestGetMediaDataCaching(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "video.mp4"), []byte("0123456789"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &ctx.Context{DataDir: dir}
+	s := NewService(c, database.NewService(c), nil)
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/data/video.mp4", nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("", "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" || w.Header().Get("Accept-Ranges") != "bytes" {
+		t.Fatalf("unexpected response: %d %v", w.Code, w.Header())
+	}
+
+	w = get("Range", "bytes=2-4")
+	if w.Code != http.StatusPartialContent || w.Body.String() != "234" {
+		t.Errorf("unexpected range response: %d %q", w.Code, w.Body.String())
+	}
+
+	w = get("If-None-Match", etag)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", w.Code)
+	}
+
+	if w = get("If-None-Match", `"other"`); w.Code != http.StatusOK {
+		t.Errorf("expected 200 for stale etag, got %d", w.Code)
+	}
+}
+
+func TestHandleVoiceFallback(t *testing.T) {
+	c := &ctx.Context{WorkDir: t.TempDir()}
+	s := NewService(c, database.NewService(c), nil)
+
+	// 无法解码的语音返回原始数据，不能按转码后的格式缓存
+	w := httptest.NewRecorder()
+	gc, _ := gin.CreateTestContext(w)
+	gc.Request = httptest.NewRequest(http.MethodGet, "/voice/1?format=mp3", nil)
+	s.HandleVoice(gc, &model.Media{Type: "voice", Key: "1", Data: []byte("not silk"), ModifyTime: 1700000000})
+	if w.Code != http.StatusOK || w.Body.String() != "not silk" {
+		t.Fatalf("unexpected response: %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != "" {
+		t.Errorf("ETag = %s, want none", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %s", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != silk.FormatRaw.ContentType() {
+		t.Errorf("Content-Type = %s", got)
+	}
+}
+
+func TestGetMediaDataFallback(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{"a.dat": "xx", "b.jpg": "not an image"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := &ctx.Context{DataDir: dir, WorkDir: t.TempDir()}
+	s := NewService(c, database.NewService(c), nil)
+
+	// 无法解密或缩放时返回原始内容，不能按解密后的图片或缩略图缓存
+	for _, path := range []string{"/data/a.dat", "/data/b.jpg?w=100"} {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: unexpected status %d", path, w.Code)
+		}
+		if got := w.Header().Get("ETag"); got != "" {
+			t.Errorf("%s: ETag = %s, want none", path, got)
+		}
+		if got := w.Header().Get("Cache-Control"); got != "no-store" {
+			t.Errorf("%s: Cache-Control = %s", path, got)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package http
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/pkg/metrics"
+)
+
+var (
+	httpRequests = metrics.NewCounter("chatlog_http_requests_total", "Number of HTTP requests per route and status.", "method", "route", "status")
+	httpDuration = metrics.NewHistogram("chatlog_http_request_duration_seconds", "HTTP request latencies per route.", nil, "method", "route")
+)
+
+// metricsMiddleware 记录每个路由的请求数与耗时，未匹配的请求合并为同一个路由，避免标签数量不受控制
+func metricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.With(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.With(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// GetMetrics 以 Prometheus 文本格式输出运行指标
+func (s *Service) GetMetrics(c *gin.Context) {
+	metrics.Default.Handler().ServeHTTP(c.Writer, c.Request)
+}
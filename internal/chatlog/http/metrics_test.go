@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/common/expfmt"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+)
+
+func TestGetMetrics(t *testing.T) {
+	c := &ctx.Context{DataDir: t.TempDir()}
+	s := NewService(c, database.NewService(c), nil)
+	for _, path := range []string{"/data/missing.jpg", "/no/such/route"} {
+		s.router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+	if _, err := new(expfmt.TextParser).TextToMetricFamilies(strings.NewReader(body)); err != nil {
+		t.Fatalf("invalid text format: %v", err)
+	}
+	for _, want := range []string{
+		`chatlog_http_requests_total{method="GET",route="/data/*path",status="404"}`,
+		`chatlog_http_requests_total{method="GET",route="unmatched",status="302"}`,
+		`chatlog_http_request_duration_seconds_count{method="GET",route="/data/*path"}`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics output is missing %s", want)
+		}
+	}
+}
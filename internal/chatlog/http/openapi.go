@@ -0,0 +1,541 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/export"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/pkg/version"
+)
+
+const (
+	OpenAPIVersion = "3.0.3"
+)
+
+// TimeRangeDescription util.TimeRangeOf 支持的时间范围格式
+const TimeRangeDescription = `时间范围，支持以下格式：
+1. 单个时间点，按粒度扩展为完整范围：
+   - 时间戳(秒): 1609459200
+   - 日期: 20060102, 2006-01-02
+   - 带时间的日期: 20060102/15:04, 2006-01-02/15:04
+   - 完整时间: 20060102150405, 200601021504
+   - RFC3339: 2006-01-02T15:04:05Z07:00
+   - 年份: 2006；月份: 200601, 2006-01；季度: 2006Q1
+   - 相对时间: 5h-ago, 3d-ago, 1w-ago, 1m-ago, 1y-ago
+   - 自然语言: now, today, yesterday
+2. 时间区间: 2006-01-01~2006-01-31, 2006-01-01,2006-01-31, 2006-01-01 to 2006-01-31
+3. 最近一段时间: last-7d, last-30d, last-3m, last-1y
+4. 特定时间段: this-week, last-week, this-month, last-month, this-year, last-year
+5. all: 所有时间`
+
+// apiParam 接口参数
+type apiParam struct {
+	Name        string
+	In          string // query, path
+	Type        string // string, integer, boolean
+	Description string
+	Required    bool
+	Enum        []string
+	Example     interface{}
+}
+
+// apiOperation 接口描述，用于生成 OpenAPI 文档
+type apiOperation struct {
+	Method      string
+	Path        string // gin 路由格式，例如 /image/*key
+	Tag         string
+	ID          string
+	Summary     string
+	Description string
+	Params      []apiParam
+	Result      interface{}       // JSON 响应类型，为 nil 时表示不返回 JSON
+	Content     map[string]string // 其他响应类型，Content-Type -> 说明
+	Body        interface{}       // JSON 请求体类型
+	Status      int               // 成功时的状态码，默认为 200
+}
+
+var (
+	formatParam = func(formats ...string) apiParam {
+		return apiParam{Name: "format", In: "query", Type: "string", Description: "输出格式，默认为纯文本", Enum: formats}
+	}
+	keywordParam   = apiParam{Name: "keyword", In: "query", Type: "string", Description: "搜索关键词"}
+	limitParam     = apiParam{Name: "limit", In: "query", Type: "integer", Description: "返回数量，0 表示不限制"}
+	offsetParam    = apiParam{Name: "offset", In: "query", Type: "integer", Description: "偏移量"}
+	talkerParam    = apiParam{Name: "talker", In: "query", Type: "string", Description: "聊天对象，支持 wxid、群 ID、备注名或昵称，多个以英文逗号分隔"}
+	senderParam    = apiParam{Name: "sender", In: "query", Type: "string", Description: "发送者，支持 wxid 或名称，多个以英文逗号分隔"}
+	mediaKeyParam  = apiParam{Name: "key", In: "path", Type: "string", Required: true, Description: "媒体文件 MD5，或数据目录下的相对路径，多个以英文逗号分隔"}
+	mediaInfoParam = apiParam{Name: "info", In: "query", Type: "string", Description: "非空时返回媒体文件信息而非文件内容"}
+	thumbParams    = []apiParam{
+		{Name: "w", In: "query", Type: "integer", Description: "缩略图宽度，只对 JPEG/PNG/GIF 图片生效，不会放大图片"},
+		{Name: "h", In: "query", Type: "integer", Description: "缩略图高度，只指定宽或高时按比例计算另一边"},
+		{Name: "fit", In: "query", Type: "string", Enum: []string{"contain", "cover", "fill"}, Description: "缩放方式：contain 等比缩放至框内，cover 等比缩放并居中裁剪，fill 拉伸填满"},
+	}
+)
+
+// apiOperations 返回所有 HTTP 接口的描述
+func (s *Service) apiOperations() []apiOperation {
+	mediaContent := func(contentTypes ...string) map[string]string {
+		m := make(map[string]string, len(contentTypes))
+		for _, ct := range contentTypes {
+			m[ct] = "文件内容"
+		}
+		return m
+	}
+
+	statsParams := []apiParam{
+		{Name: "time", In: "query", Type: "string", Description: "默认为 all。" + TimeRangeDescription, Example: "last-30d"},
+		{Name: "talker", In: "query", Type: "string", Description: "聊天对象，为空时统计所有聊天对象，多个以英文逗号分隔；只统计一个群聊时发送者名称使用群昵称"},
+		{Name: "top", In: "query", Type: "integer", Description: "聊天对象与发送者排行返回的数量，默认 10，0 表示不限制"},
+	}
+
+	ops := []apiOperation{
+		{
+			Method: http.MethodGet, Path: "/api/v1/chatlog", Tag: "chatlog", ID: "getChatlog",
+			Summary:     "查询聊天记录",
+			Description: "查询指定时间范围内与特定联系人或群聊的聊天记录",
+			Params: []apiParam{
+				{Name: "time", In: "query", Type: "string", Required: true, Description: TimeRangeDescription, Example: "2023-01-01~2023-01-31"},
+				talkerParam, senderParam, keywordParam, limitParam, offsetParam,
+				formatParam("text", "json", "csv"),
+			},
+			Result:  []*model.Message{},
+			Content: map[string]string{"text/plain": "纯文本格式的聊天记录"},
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/contact", Tag: "contact", ID: "getContacts",
+			Summary: "查询联系人列表",
+			Params:  []apiParam{keywordParam, limitParam, offsetParam, formatParam("text", "json", "csv")},
+			Result:  &wechatdb.GetContactsResp{},
+			Content: map[string]string{"text/plain": "CSV 格式的联系人列表", "text/csv": "CSV 格式的联系人列表"},
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/chatroom", Tag: "chatroom", ID: "getChatRooms",
+			Summary: "查询群聊列表",
+			Params:  []apiParam{keywordParam, limitParam, offsetParam, formatParam("text", "json", "csv")},
+			Result:  &wechatdb.GetChatRoomsResp{},
+			Content: map[string]string{"text/plain": "CSV 格式的群聊列表", "text/csv": "CSV 格式的群聊列表"},
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/session", Tag: "session", ID: "getSessions",
+			Summary: "查询最近会话列表",
+			Params:  []apiParam{keywordParam, limitParam, offsetParam, formatParam("text", "json", "csv")},
+			Result:  &wechatdb.GetSessionsResp{},
+			Content: map[string]string{"text/plain": "纯文本格式的会话列表", "text/csv": "CSV 格式的会话列表"},
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/stream", Tag: "stream", ID: "getStream",
+			Summary:     "实时推送新消息",
+			Description: "默认以 SSE 推送，每条新消息为一个 message 事件，data 为 JSON 格式的 Message；请求头包含 \"Upgrade: websocket\" 时使用 WebSocket，每帧为一条 JSON 格式的 Message",
+			Params:      []apiParam{talkerParam, senderParam},
+			Content:     map[string]string{"text/event-stream": "SSE 事件流，data 为 JSON 格式的 Message"},
+		},
+		{
+			Method: http.MethodGet, Path: "/image/*key", Tag: "media", ID: "getImage",
+			Summary: "获取图片",
+			Params:  append([]apiParam{mediaKeyParam, mediaInfoParam}, thumbParams...),
+			Result:  &model.Media{},
+			Content: mediaContent("image/jpeg", "image/png", "image/gif"),
+		},
+		{
+			Method: http.MethodGet, Path: "/video/*key", Tag: "media", ID: "getVideo",
+			Summary: "获取视频",
+			Params:  []apiParam{mediaKeyParam, mediaInfoParam},
+			Result:  &model.Media{},
+			Content: mediaContent("video/mp4"),
+		},
+		{
+			Method: http.MethodGet, Path: "/file/*key", Tag: "media", ID: "getFile",
+			Summary: "获取文件",
+			Params:  []apiParam{mediaKeyParam, mediaInfoParam},
+			Result:  &model.Media{},
+			Content: mediaContent("application/octet-stream"),
+		},
+		{
+			Method: http.MethodGet, Path: "/voice/*key", Tag: "media", ID: "getVoice",
+			Summary:     "获取语音",
+			Description: "响应头 X-Audio-Duration 为语音时长（秒），转码结果缓存在工作目录的 voices 目录中",
+			Params: []apiParam{mediaKeyParam, mediaInfoParam,
+				{Name: "format", In: "query", Type: "string", Enum: []string{"mp3", "wav", "flac", "raw"}, Description: "输出格式，默认为 mp3；wav 为 16 位 PCM，flac 为无损 FLAC，raw 为原始 SILK 数据"},
+				{Name: "rate", In: "query", Type: "integer", Description: "采样率，支持 8000、12000、16000、24000、32000、44100、48000，默认为 24000"},
+				{Name: "bitrate", In: "query", Type: "integer", Description: "MP3 码率（kbps），范围 8-320，默认为 16"},
+			},
+			Result:  &model.Media{},
+			Content: mediaContent("audio/mp3", "audio/wav", "audio/flac", "audio/silk"),
+		},
+		{
+			Method: http.MethodGet, Path: "/data/*path", Tag: "media", ID: "getMediaData",
+			Summary:     "获取数据目录下的文件",
+			Description: "加密的 .dat 图片会自动解密，指定 w 或 h 时返回缩略图",
+			Params:      append([]apiParam{{Name: "path", In: "path", Type: "string", Required: true, Description: "数据目录下的相对路径"}}, thumbParams...),
+			Content:     mediaContent("application/octet-stream"),
+		},
+		{
+			Method: http.MethodGet, Path: "/api/v1/stats", Tag: "stats", ID: "getStats",
+			Summary:     "消息统计",
+			Description: "按聊天对象、发送者、消息类型、日期统计消息数量，并返回每小时与每周的活跃度热力图，自己发送的消息的发送者为 self",
+			Params:      statsParams,
+			Result:      &model.MessageStats{},
+		},
+	}
+
+	exportIDParam := apiParam{Name: "id", In: "path", Type: "string", Required: true, Description: "导出任务 ID"}
+	ops = append(ops,
+		apiOperation{
+			Method: http.MethodPost, Path: "/api/v1/exports", Tag: "export", ID: "createExport",
+			Summary:     "创建导出任务",
+			Description: "在后台导出聊天记录，完成后打包为 zip 文件。format 支持 json 与 csv，time 格式同聊天记录查询，默认为 all；talkers 为空时导出所有联系人；media 为 true 时同时导出图片与视频",
+			Body:        &export.JobOptions{},
+			Result:      &export.Job{},
+			Status:      http.StatusAccepted,
+		},
+		apiOperation{
+			Method: http.MethodGet, Path: "/api/v1/exports", Tag: "export", ID: "listExports",
+			Summary: "导出任务列表",
+			Result:  []*export.Job{},
+		},
+		apiOperation{
+			Method: http.MethodGet, Path: "/api/v1/exports/:id", Tag: "export", ID: "getExport",
+			Summary:     "查询导出任务进度",
+			Description: "stage 为当前阶段：messages 读取聊天记录、write 写入文件、media 导出媒体文件、archive 打包，current 与 total 为当前阶段的进度",
+			Params:      []apiParam{exportIDParam},
+			Result:      &export.Job{},
+		},
+		apiOperation{
+			Method: http.MethodGet, Path: "/api/v1/exports/:id/download", Tag: "export", ID: "downloadExport",
+			Summary: "下载导出文件",
+			Params:  []apiParam{exportIDParam},
+			Content: map[string]string{"application/zip": "导出的压缩包"},
+		},
+		apiOperation{
+			Method: http.MethodDelete, Path: "/api/v1/exports/:id", Tag: "export", ID: "deleteExport",
+			Summary: "取消或删除导出任务",
+			Params:  []apiParam{exportIDParam},
+			Result:  &export.Job{},
+		},
+	)
+
+	for _, dimension := range StatsDimensions {
+		ops = append(ops, apiOperation{
+			Method: http.MethodGet, Path: "/api/v1/stats/" + dimension, Tag: "stats", ID: "getStats" + strings.ToUpper(dimension[:1]) + dimension[1:],
+			Summary: "消息统计: " + dimension,
+			Params:  statsParams,
+			Result:  statsResults[dimension],
+		})
+	}
+
+	ops = append(ops, apiOperation{
+		Method: http.MethodPost, Path: "/api/v1/batch", Tag: "batch", ID: "postBatch",
+		Summary:     "批量查询",
+		Description: fmt.Sprintf("在一个请求中执行多个查询，type 支持 %s，其他参数与对应的 GET 接口相同，data 与 format=json 的返回相同。查询并发执行，结果顺序与请求一致，单个查询失败不影响其他查询；单次最多 %d 个查询", strings.Join(BatchQueryTypes, "、"), MaxBatchQueries),
+		Body:        []BatchQuery{},
+		Result:      []BatchResult{},
+	})
+
+	ops = append(ops, apiOperation{
+		Method: http.MethodGet, Path: "/api/v1/status", Tag: "status", ID: "getStatus",
+		Summary:     "服务状态",
+		Description: "返回账号与版本、数据目录与工作目录占用、自动解密状态与各数据库文件最近一次的解密结果、已加载的消息数据库分片及其时间范围、缓存加载状态。ready 为 false 时查询接口返回 503",
+		Result:      &database.Status{},
+	})
+
+	accounts := apiOperation{
+		Method: http.MethodGet, Path: "/api/v1/accounts", Tag: "account", ID: "getAccounts",
+		Summary:     "账号列表",
+		Description: "返回当前账号与配置历史记录中已解密的账号，其他账号的接口位于 /api/v1/accounts/{account} 下，首次访问时加载",
+		Result:      []database.AccountInfo{},
+	}
+	health := []apiOperation{
+		{
+			Method: http.MethodGet, Path: "/healthz", Tag: "status", ID: "healthz",
+			Summary: "存活检查",
+			Content: map[string]string{"text/plain": "ok"},
+		},
+		{
+			Method: http.MethodGet, Path: "/readyz", Tag: "status", ID: "readyz",
+			Summary:     "就绪检查",
+			Description: "当前账号的数据库与缓存加载完成后返回 200，否则返回 503",
+			Content:     map[string]string{"text/plain": "ok"},
+		},
+	}
+	return append(append(append(ops, accounts), health...), accountOperations(ops)...)
+}
+
+// accountOperations 返回指定账号的接口描述，与当前账号的接口相同，路径增加 /api/v1/accounts/{account} 前缀
+func accountOperations(ops []apiOperation) []apiOperation {
+	accountParam := apiParam{Name: "account", In: "path", Type: "string", Required: true, Description: "账号名称，见 /api/v1/accounts"}
+
+	list := make([]apiOperation, 0, len(ops))
+	for _, op := range ops {
+		op.Path = "/api/v1/accounts/:account" + strings.TrimPrefix(op.Path, "/api/v1")
+		op.ID += "ForAccount"
+		op.Params = append([]apiParam{accountParam}, op.Params...)
+		list = append(list, op)
+	}
+	return list
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  []byte
+	openAPIErr  error
+)
+
+// GetOpenAPI 返回 OpenAPI 文档
+func (s *Service) GetOpenAPI(c *gin.Context) {
+	openAPIOnce.Do(func() {
+		openAPIDoc, openAPIErr = json.MarshalIndent(s.OpenAPI(), "", "  ")
+	})
+	if openAPIErr != nil {
+		c.JSON(http.StatusInternalServerError, openAPIErr.Error())
+		return
+	}
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPIDoc)
+}
+
+// OpenAPI 根据接口描述生成 OpenAPI 3 文档
+func (s *Service) OpenAPI() map[string]interface{} {
+	g := newSchemaGenerator()
+	paths := make(map[string]interface{})
+	tags := make([]string, 0)
+
+	for _, op := range s.apiOperations() {
+		path := openAPIPath(op.Path)
+		item, ok := paths[path].(map[string]interface{})
+		if !ok {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+
+		params := make([]interface{}, 0, len(op.Params))
+		for _, p := range op.Params {
+			schema := map[string]interface{}{"type": p.Type}
+			if len(p.Enum) > 0 {
+				schema["enum"] = p.Enum
+			}
+			param := map[string]interface{}{
+				"name":     p.Name,
+				"in":       p.In,
+				"required": p.Required || p.In == "path",
+				"schema":   schema,
+			}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			if p.Example != nil {
+				param["example"] = p.Example
+			}
+			params = append(params, param)
+		}
+
+		content := make(map[string]interface{})
+		if op.Result != nil {
+			content["application/json"] = map[string]interface{}{"schema": g.schemaOf(reflect.TypeOf(op.Result))}
+		}
+		for ct, desc := range op.Content {
+			var schema map[string]interface{}
+			if strings.HasPrefix(ct, "text/") {
+				schema = map[string]interface{}{"type": "string", "description": desc}
+			} else {
+				schema = map[string]interface{}{"type": "string", "format": "binary", "description": desc}
+			}
+			content[ct] = map[string]interface{}{"schema": schema}
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		operation := map[string]interface{}{
+			"operationId": op.ID,
+			"summary":     op.Summary,
+			"tags":        []string{op.Tag},
+			"parameters":  params,
+			"responses": map[string]interface{}{
+				strconv.Itoa(status): map[string]interface{}{"description": http.StatusText(status), "content": content},
+				"default":            map[string]interface{}{"$ref": "#/components/responses/Error"},
+			},
+		}
+		if op.Body != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{"schema": g.schemaOf(reflect.TypeOf(op.Body))},
+				},
+			}
+		}
+		if op.Description != "" {
+			operation["description"] = op.Description
+		}
+		item[strings.ToLower(op.Method)] = operation
+
+		if !contains(tags, op.Tag) {
+			tags = append(tags, op.Tag)
+		}
+	}
+
+	tagList := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		tagList = append(tagList, map[string]interface{}{"name": tag})
+	}
+
+	return map[string]interface{}{
+		"openapi": OpenAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "Chatlog API",
+			"description": "Chatlog HTTP API，MCP 接口请参考 /sse 与 /messages",
+			"version":     version.Version,
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "/"}},
+		"tags":    tagList,
+		"paths":   paths,
+		// 令牌可选，配置了访问控制规则时用于选择规则
+		"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"bearer": []interface{}{}}},
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+			"responses": map[string]interface{}{
+				"Error": map[string]interface{}{
+					"description": "错误信息",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+					},
+				},
+			},
+		},
+	}
+}
+
+// openAPIPath 将 gin 路由参数 :name 与 *name 转换为 {name}
+func openAPIPath(path string) string {
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaGenerator 通过反射 json tag 生成 JSON Schema，具名结构体放入 components
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+func newSchemaGenerator() *schemaGenerator {
+	return &schemaGenerator{schemas: make(map[string]interface{})}
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (g *schemaGenerator) schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return map[string]interface{}{"type": "string", "format": "byte"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if _, ok := g.schemas[name]; !ok {
+			// 先占位，避免递归类型死循环
+			g.schemas[name] = map[string]interface{}{}
+			g.schemas[name] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := g.structSchema(ft)
+				for k, v := range embedded["properties"].(map[string]interface{}) {
+					properties[k] = v
+				}
+				if r, ok := embedded["required"].([]string); ok {
+					required = append(required, r...)
+				}
+				continue
+			}
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schemaOf(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Ptr {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema
+}
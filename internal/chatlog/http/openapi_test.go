@@ -0,0 +1,27 @@
+package http
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	s := NewService(&ctx.Context{}, nil, nil)
+	paths := s.OpenAPI()["paths"].(map[string]interface{})
+
+	for _, route := range s.router.Routes() {
+		if !strings.HasPrefix(route.Path, "/api/v1") {
+			continue
+		}
+		item, ok := paths[openAPIPath(route.Path)].(map[string]interface{})
+		if !ok {
+			t.Errorf("route %s %s is not documented", route.Method, route.Path)
+			continue
+		}
+		if _, ok := item[strings.ToLower(route.Method)]; !ok {
+			t.Errorf("method %s of %s is not documented", route.Method, route.Path)
+		}
+	}
+}
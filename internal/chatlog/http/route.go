@@ -1,17 +1,20 @@
 package http
 
 import (
+	"bytes"
 	"embed"
 	"fmt"
 	"io/fs"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
 	"github.com/sjzar/chatlog/pkg/util"
-	"github.com/sjzar/chatlog/pkg/util/dat2img"
 	"github.com/sjzar/chatlog/pkg/util/silk"
 
 	"github.com/gin-gonic/gin"
@@ -30,35 +33,82 @@ func (s *Service) initRouter() {
 	router.StaticFS("/static", http.FS(staticDir))
 	router.StaticFileFS("/favicon.ico", "./favicon.ico", http.FS(staticDir))
 	router.StaticFileFS("/", "./index.htm", http.FS(staticDir))
+	router.StaticFileFS("/docs", "./docs.htm", http.FS(staticDir))
+	router.StaticFileFS("/chat", "./chat.htm", http.FS(staticDir))
+
+	// OpenAPI
+	router.GET("/api/openapi.json", s.GetOpenAPI)
+
+	// Prometheus
+	router.GET("/metrics", s.GetMetrics)
+
+	// Health
+	router.GET("/healthz", s.Healthz)
+	router.GET("/readyz", s.Readyz)
 
 	// Media
-	router.GET("/image/*key", s.GetImage)
-	router.GET("/video/*key", s.GetVideo)
-	router.GET("/file/*key", s.GetFile)
-	router.GET("/voice/*key", s.GetVoice)
-	router.GET("/data/*path", s.GetMediaData)
+	s.initMediaRouter(router.Group("", s.AccessMiddleware))
 
 	// MCP Server
 	{
-		router.GET("/sse", s.mcp.HandleSSE)
-		router.POST("/messages", s.mcp.HandleMessages)
+		mcpRouter := router.Group("", s.AccessMiddleware)
+
+		// Streamable HTTP
+		mcpRouter.POST("/mcp", s.mcp.HandleStreamable)
+		mcpRouter.GET("/mcp", s.mcp.HandleStreamable)
+		mcpRouter.DELETE("/mcp", s.mcp.HandleStreamable)
+
+		// SSE，保留以兼容旧版客户端
+		mcpRouter.GET("/sse", s.mcp.HandleSSE)
+		mcpRouter.POST("/messages", s.mcp.HandleMessages)
 		// mcp inspector is shit
 		// https://github.com/modelcontextprotocol/inspector/blob/aeaf32f/server/src/index.ts#L155
-		router.POST("/message", s.mcp.HandleMessages)
+		mcpRouter.POST("/message", s.mcp.HandleMessages)
 	}
 
 	// API V1 Router
-	api := router.Group("/api/v1")
-	{
-		api.GET("/chatlog", s.GetChatlog)
-		api.GET("/contact", s.GetContacts)
-		api.GET("/chatroom", s.GetChatRooms)
-		api.GET("/session", s.GetSessions)
-	}
+	api := router.Group("/api/v1", s.AccessMiddleware)
+	s.initAPIRouter(api)
+	api.GET("/accounts", s.GetAccounts)
+
+	// 指定账号的接口，账号从配置的历史记录中按需加载
+	account := api.Group("/accounts/:account", s.AccountMiddleware)
+	s.initAPIRouter(account)
+	s.initMediaRouter(account)
 
 	router.NoRoute(s.NoRoute)
 }
 
+func (s *Service) initMediaRouter(r gin.IRoutes) {
+	r.GET("/image/*key", s.AuditMiddleware, s.GetImage)
+	r.GET("/video/*key", s.AuditMiddleware, s.GetVideo)
+	r.GET("/file/*key", s.AuditMiddleware, s.GetFile)
+	r.GET("/voice/*key", s.AuditMiddleware, s.GetVoice)
+	r.GET("/data/*path", s.AuditMiddleware, s.GetMediaData)
+}
+
+func (s *Service) initAPIRouter(r *gin.RouterGroup) {
+	r.GET("/status", s.GetStatus)
+
+	// 以下接口需要数据库已就绪
+	api := r.Group("", s.ReadyMiddleware, s.AuditMiddleware)
+	api.GET("/chatlog", s.GetChatlog)
+	api.GET("/contact", s.GetContacts)
+	api.GET("/chatroom", s.GetChatRooms)
+	api.GET("/session", s.GetSessions)
+	api.POST("/batch", s.PostBatch)
+	api.GET("/stream", s.GetStream)
+	api.GET("/stats", s.GetStats)
+	for _, dimension := range StatsDimensions {
+		api.GET("/stats/"+dimension, s.GetStatsDimension(dimension))
+	}
+	api.POST("/exports", s.CreateExport)
+	api.GET("/exports", s.ListExports)
+	api.GET("/exports/:id", s.GetExport)
+	api.GET("/exports/:id/download", s.DownloadExport)
+	api.DELETE("/exports/:id", s.DeleteExport)
+}
+
 // NoRoute handles 404 Not Found errors. If the request URL starts with "/api"
 // or "/static", it responds with a JSON error. Otherwise, it redirects to the root path.
 func (s *Service) NoRoute(c *gin.Context) {
@@ -102,11 +152,12 @@ func (s *Service) GetChatlog(c *gin.Context) {
 		q.Offset = 0
 	}
 
-	messages, err := s.db.GetMessages(start, end, q.Talker, q.Sender, q.Keyword, q.Limit, q.Offset)
+	messages, err := s.dbOf(c).GetMessages(start, end, q.Talker, q.Sender, q.Keyword, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
 	}
+	setAudit(c, messages)
 
 	switch strings.ToLower(q.Format) {
 	case "csv":
@@ -121,7 +172,7 @@ func (s *Service) GetChatlog(c *gin.Context) {
 		c.Writer.Flush()
 
 		for _, m := range messages {
-			c.Writer.WriteString(m.PlainText(strings.Contains(q.Talker, ","), util.PerfectTimeFormat(start, end), c.Request.Host))
+			c.Writer.WriteString(m.PlainText(strings.Contains(q.Talker, ","), util.PerfectTimeFormat(start, end), mediaHost(c)))
 			c.Writer.WriteString("\n")
 			c.Writer.Flush()
 		}
@@ -142,11 +193,12 @@ func (s *Service) GetContacts(c *gin.Context) {
 		return
 	}
 
-	list, err := s.db.GetContacts(q.Keyword, q.Limit, q.Offset)
+	list, err := s.dbOf(c).GetContacts(q.Keyword, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
 	}
+	setAudit(c, list)
 
 	format := strings.ToLower(q.Format)
 	switch format {
@@ -187,11 +239,12 @@ func (s *Service) GetChatRooms(c *gin.Context) {
 		return
 	}
 
-	list, err := s.db.GetChatRooms(q.Keyword, q.Limit, q.Offset)
+	list, err := s.dbOf(c).GetChatRooms(q.Keyword, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
 	}
+	setAudit(c, list)
 	format := strings.ToLower(q.Format)
 	switch format {
 	case "json":
@@ -231,11 +284,12 @@ func (s *Service) GetSessions(c *gin.Context) {
 		return
 	}
 
-	sessions, err := s.db.GetSessions(q.Keyword, q.Limit, q.Offset)
+	sessions, err := s.dbOf(c).GetSessions(q.Keyword, q.Limit, q.Offset)
 	if err != nil {
 		errors.Err(c, err)
 		return
 	}
+	setAudit(c, sessions)
 	format := strings.ToLower(q.Format)
 	switch format {
 	case "csv":
@@ -296,28 +350,33 @@ func (s *Service) GetMedia(c *gin.Context, _type string) {
 	var _err error
 	for _, k := range keys {
 		if len(k) != 32 {
-			absolutePath := filepath.Join(s.ctx.DataDir, k)
+			absolutePath := filepath.Join(s.ctxOf(c).DataDir, k)
 			if _, err := os.Stat(absolutePath); os.IsNotExist(err) {
 				continue
 			}
-			c.Redirect(http.StatusFound, "/data/"+k)
+			if !s.dbOf(c).AllowMediaPath(k) {
+				errors.Err(c, errors.AccessDenied(k))
+				return
+			}
+			s.serveMediaFile(c, absolutePath, "")
 			return
 		}
-		media, err := s.db.GetMedia(_type, k)
+		media, err := s.dbOf(c).GetMedia(_type, k)
 		if err != nil {
 			_err = err
 			continue
 		}
+		setAudit(c, media)
 		if c.Query("info") != "" {
 			c.JSON(http.StatusOK, media)
 			return
 		}
 		switch media.Type {
 		case "voice":
-			s.HandleVoice(c, media.Data)
+			s.HandleVoice(c, media)
 			return
 		default:
-			c.Redirect(http.StatusFound, "/data/"+media.Path)
+			s.serveMediaFile(c, filepath.Join(s.ctxOf(c).DataDir, media.Path), media.Key)
 			return
 		}
 	}
@@ -330,60 +389,39 @@ func (s *Service) GetMedia(c *gin.Context, _type string) {
 
 func (s *Service) GetMediaData(c *gin.Context) {
 	relativePath := filepath.Clean(c.Param("path"))
-
-	absolutePath := filepath.Join(s.ctx.DataDir, relativePath)
-
-	if _, err := os.Stat(absolutePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "File not found",
-		})
+	if !s.dbOf(c).AllowMediaPath(relativePath) {
+		errors.Err(c, errors.AccessDenied(relativePath))
 		return
 	}
 
-	ext := strings.ToLower(filepath.Ext(absolutePath))
-	switch {
-	case ext == ".dat":
-		s.HandleDatFile(c, absolutePath)
-	default:
-		// 直接返回文件
-		c.File(absolutePath)
-	}
+	absolutePath := filepath.Join(s.ctxOf(c).DataDir, relativePath)
 
+	s.serveMediaFile(c, absolutePath, "")
 }
 
-func (s *Service) HandleDatFile(c *gin.Context, path string) {
-
-	b, err := os.ReadFile(path)
+func (s *Service) HandleVoice(c *gin.Context, media *model.Media) {
+	opts, err := voiceOptions(c)
 	if err != nil {
 		errors.Err(c, err)
 		return
 	}
-	out, ext, err := dat2img.Dat2Image(b)
-	if err != nil {
-		c.File(path)
-		return
-	}
 
-	switch ext {
-	case "jpg":
-		c.Data(http.StatusOK, "image/jpeg", out)
-	case "png":
-		c.Data(http.StatusOK, "image/png", out)
-	case "gif":
-		c.Data(http.StatusOK, "image/gif", out)
-	case "bmp":
-		c.Data(http.StatusOK, "image/bmp", out)
-	default:
-		c.Data(http.StatusOK, "image/jpg", out)
-		// c.File(path)
-	}
-}
+	// 时长根据 SILK 帧数计算，无需解码
+	c.Header("X-Audio-Duration", strconv.FormatFloat(silk.Duration(media.Data).Seconds(), 'f', 3, 64))
 
-func (s *Service) HandleVoice(c *gin.Context, data []byte) {
-	out, err := silk.Silk2MP3(data)
+	// 转码结果只取决于语音内容与转码参数，可直接使用 MD5 作为缓存标识
+	etag := media.Key + "-" + opts.String()
+	modTime := time.Unix(media.ModifyTime, 0)
+	if cacheHit(c, etag, MediaCacheControl, modTime) {
+		return
+	}
+	out, err := s.getTranscoder(s.ctxOf(c).WorkDir).Transcode(media.Data, opts)
 	if err != nil {
-		c.Data(http.StatusOK, "audio/silk", data)
+		// 转码失败时返回原始 SILK 数据，内容与 ETag 对应的格式不一致，不能被缓存
+		c.Writer.Header().Del("ETag")
+		c.Header("Cache-Control", "no-store")
+		serveContent(c, silk.FormatRaw.ContentType(), time.Time{}, bytes.NewReader(media.Data))
 		return
 	}
-	c.Data(http.StatusOK, "audio/mp3", out)
+	serveContent(c, opts.Format.ContentType(), modTime, bytes.NewReader(out))
 }
@@ -3,12 +3,16 @@ package http
 import (
 	"context"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
 	"github.com/sjzar/chatlog/internal/chatlog/database"
 	"github.com/sjzar/chatlog/internal/chatlog/mcp"
 	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/export"
+	"github.com/sjzar/chatlog/pkg/util/silk"
+	"github.com/sjzar/chatlog/pkg/util/thumbnail"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog/log"
@@ -23,6 +27,15 @@ type Service struct {
 	db  *database.Service
 	mcp *mcp.Service
 
+	exportMutex sync.Mutex
+	exports     map[*database.Service]*export.JobManager
+
+	thumbMutex   sync.Mutex
+	thumbnailers map[string]*thumbnail.Thumbnailer // 缓存目录 -> 缩略图生成器
+
+	voiceMutex  sync.Mutex
+	transcoders map[string]*silk.Transcoder // 缓存目录 -> 语音转码器
+
 	router *gin.Engine
 	server *http.Server
 }
@@ -41,13 +54,17 @@ func NewService(ctx *ctx.Context, db *database.Service, mcp *mcp.Service) *Servi
 		errors.RecoveryMiddleware(),
 		errors.ErrorHandlerMiddleware(),
 		gin.LoggerWithWriter(log.Logger),
+		metricsMiddleware(),
 	)
 
 	s := &Service{
-		ctx:    ctx,
-		db:     db,
-		mcp:    mcp,
-		router: router,
+		ctx:          ctx,
+		db:           db,
+		mcp:          mcp,
+		exports:      make(map[*database.Service]*export.JobManager),
+		thumbnailers: make(map[string]*thumbnail.Thumbnailer),
+		transcoders:  make(map[string]*silk.Transcoder),
+		router:       router,
 	}
 
 	s.initRouter()
@@ -94,6 +111,8 @@ func (s *Service) ListenAndServe() error {
 
 func (s *Service) Stop() error {
 
+	s.closeExports()
+
 	if s.server == nil {
 		return nil
 	}
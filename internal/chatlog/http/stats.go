@@ -0,0 +1,103 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	DefaultStatsTime = "all"
+	DefaultStatsTop  = 10
+)
+
+// StatsDimensions 可单独查询的统计维度，对应 /api/v1/stats/<dimension>
+var StatsDimensions = []string{"talkers", "senders", "types", "days", "hours", "weekdays", "heatmap"}
+
+// GetStats 返回完整的消息统计
+func (s *Service) GetStats(c *gin.Context) {
+	s.getStats(c, "")
+}
+
+// GetStatsDimension 返回只包含单个维度的消息统计
+func (s *Service) GetStatsDimension(dimension string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.getStats(c, dimension)
+	}
+}
+
+func (s *Service) getStats(c *gin.Context, dimension string) {
+	q := struct {
+		Time   string `form:"time"`
+		Talker string `form:"talker"`
+		Top    *int   `form:"top"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	if q.Time == "" {
+		q.Time = DefaultStatsTime
+	}
+	start, end, ok := util.TimeRangeOf(q.Time)
+	if !ok {
+		errors.Err(c, errors.InvalidArg("time"))
+		return
+	}
+	top := DefaultStatsTop
+	if q.Top != nil {
+		top = *q.Top
+	}
+
+	stats, err := s.dbOf(c).GetMessageStats(start, end, q.Talker)
+	if err != nil {
+		errors.Err(c, err)
+		return
+	}
+	setAudit(c, stats)
+	stats = stats.Top(top)
+
+	if dimension == "" {
+		c.JSON(http.StatusOK, stats)
+		return
+	}
+
+	var ret interface{}
+	switch dimension {
+	case "talkers":
+		ret = stats.Talkers
+	case "senders":
+		ret = stats.Senders
+	case "types":
+		ret = stats.Types
+	case "days":
+		ret = stats.Days
+	case "hours":
+		ret = stats.Hours
+	case "weekdays":
+		ret = stats.Weekdays
+	case "heatmap":
+		ret = stats.Heatmap
+	default:
+		errors.Err(c, errors.InvalidArg("dimension"))
+		return
+	}
+	c.JSON(http.StatusOK, ret)
+}
+
+// statsResults 各统计维度的响应类型，用于生成 OpenAPI 文档
+var statsResults = map[string]interface{}{
+	"talkers":  []*model.StatsItem{},
+	"senders":  []*model.StatsItem{},
+	"types":    []*model.StatsItem{},
+	"days":     []*model.StatsItem{},
+	"hours":    [24]int{},
+	"weekdays": [7]int{},
+	"heatmap":  [7][24]int{},
+}
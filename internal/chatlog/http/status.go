@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+)
+
+// GetStatus 返回账号、数据目录、自动解密、消息分片与缓存的状态
+func (s *Service) GetStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.dbOf(c).Status())
+}
+
+// Healthz 存活检查，服务能够响应请求即返回 200
+func (s *Service) Healthz(c *gin.Context) {
+	c.String(http.StatusOK, "ok")
+}
+
+// Readyz 就绪检查，当前账号的数据库与缓存加载完成后返回 200，否则返回 503
+func (s *Service) Readyz(c *gin.Context) {
+	if s.db == nil || !s.db.Ready() {
+		c.String(http.StatusServiceUnavailable, "not ready")
+		return
+	}
+	c.String(http.StatusOK, "ok")
+}
+
+// ReadyMiddleware 数据库未就绪时直接返回 503，避免查询返回难以理解的错误
+func (s *Service) ReadyMiddleware(c *gin.Context) {
+	if db := s.dbOf(c); db == nil || !db.Ready() {
+		errors.Err(c, errors.ServiceNotReady())
+		c.Abort()
+		return
+	}
+	c.Next()
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+)
+
+func TestStatusNotReady(t *testing.T) {
+	c := &ctx.Context{Account: "alice", Platform: "windows", Version: 4}
+	s := NewService(c, database.NewService(c), nil)
+
+	for path, code := range map[string]int{
+		"/healthz":        http.StatusOK,
+		"/readyz":         http.StatusServiceUnavailable,
+		"/api/v1/session": http.StatusServiceUnavailable,
+		"/api/v1/status":  http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != code {
+			t.Errorf("%s: got status %d, want %d", path, w.Code, code)
+		}
+		if path != "/api/v1/status" {
+			continue
+		}
+		var status database.Status
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.Account != "alice" || status.Ready || status.Shards == nil {
+			t.Errorf("unexpected status: %+v", status)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/websocket"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+const (
+	StreamChanCap       = 100
+	StreamPingIntervalS = 30
+)
+
+// GetStream 推送新消息，请求头包含 "Upgrade: websocket" 时使用 WebSocket，否则使用 SSE
+// 与 MCP 相同校验 Origin，其他网站的页面不能读取新消息
+func (s *Service) GetStream(c *gin.Context) {
+	if !mcp.ValidOrigin(c.Request) {
+		errors.Err(c, errors.InvalidOrigin(c.GetHeader("Origin")))
+		return
+	}
+
+	q := struct {
+		Talker string `form:"talker"`
+		Sender string `form:"sender"`
+	}{}
+
+	if err := c.BindQuery(&q); err != nil {
+		errors.Err(c, err)
+		return
+	}
+
+	filter := database.NewMessageFilter(q.Talker, q.Sender)
+
+	// 订阅新消息，消费过慢时丢弃，避免阻塞新消息检查
+	ch := make(chan *model.Message, StreamChanCap)
+	unsubscribe := s.dbOf(c).Subscribe(func(messages []*model.Message) {
+		for _, msg := range messages {
+			if !filter.Match(msg) {
+				continue
+			}
+			select {
+			case ch <- msg:
+				setAudit(c, msg)
+			default:
+				log.Debug().Msgf("stream channel is full, drop message %d", msg.Seq)
+			}
+		}
+	})
+	defer unsubscribe()
+
+	if strings.EqualFold(c.GetHeader("Upgrade"), "websocket") {
+		s.streamWebSocket(c, ch)
+		return
+	}
+	s.streamSSE(c, ch)
+}
+
+// streamSSE 以 SSE 事件推送新消息
+// event: message
+// data: {"seq":1745000000000,"time":"2025-04-18T21:29:00+08:00",...}
+func (s *Service) streamSSE(c *gin.Context, ch <-chan *model.Message) {
+	c.Writer.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+	c.Writer.WriteHeader(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(StreamPingIntervalS * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			c.Writer.WriteString(fmt.Sprintf(": ping - %s\n\n", time.Now().Format(time.RFC3339)))
+			c.Writer.Flush()
+		case msg := <-ch:
+			b, err := json.Marshal(msg)
+			if err != nil {
+				log.Debug().Err(err).Msg("failed to marshal message")
+				continue
+			}
+			c.Writer.WriteString("event: message\n")
+			c.Writer.WriteString(fmt.Sprintf("data: %s\n\n", b))
+			c.Writer.Flush()
+		}
+	}
+}
+
+// streamWebSocket 以 WebSocket 文本帧推送新消息，每帧为一条 JSON 格式的消息
+func (s *Service) streamWebSocket(c *gin.Context, ch <-chan *model.Message) {
+	server := websocket.Server{
+		// Origin 已在 GetStream 中校验
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			defer ws.Close()
+
+			// 读取客户端数据以感知连接关闭
+			closed := make(chan struct{})
+			go func() {
+				defer close(closed)
+				var discard string
+				for {
+					if err := websocket.Message.Receive(ws, &discard); err != nil {
+						return
+					}
+				}
+			}()
+
+			for {
+				select {
+				case <-closed:
+					return
+				case <-c.Request.Context().Done():
+					return
+				case msg := <-ch:
+					if err := websocket.JSON.Send(ws, msg); err != nil {
+						log.Debug().Err(err).Msg("failed to send message over websocket")
+						return
+					}
+				}
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
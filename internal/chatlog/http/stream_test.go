@@ -0,0 +1,172 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// streamDB 在临时目录中创建 v4 格式的数据库，通过 wechatdb.New 加载，写入消息时同时更新会话的最后消息时间
+type streamDB struct {
+	t       *testing.T
+	dir     string
+	message *sql.DB
+	session *sql.DB
+}
+
+func newStreamDB(t *testing.T) *streamDB {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows 上读取的是数据库的临时拷贝，看不到之后写入的消息")
+	}
+	dir := t.TempDir()
+	open := func(name string, stmts ...string) *sql.DB {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		for _, stmt := range stmts {
+			if _, err := db.Exec(stmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return db
+	}
+	open("contact.db",
+		"CREATE TABLE contact (username TEXT, local_type INTEGER, alias TEXT, remark TEXT, nick_name TEXT)",
+		"CREATE TABLE chat_room (username TEXT, owner TEXT, ext_buffer BLOB)",
+		"INSERT INTO contact VALUES ('wxid_a', 1, '', '', 'A'), ('wxid_b', 1, '', '', 'B')",
+	)
+	return &streamDB{
+		t:   t,
+		dir: dir,
+		session: open("session.db",
+			"CREATE TABLE SessionTable (username TEXT PRIMARY KEY, summary TEXT, last_timestamp INTEGER, last_msg_sender TEXT, last_sender_display_name TEXT, sort_timestamp INTEGER)",
+		),
+		message: open("message_0.db",
+			"CREATE TABLE Timestamp (timestamp INTEGER)",
+			"INSERT INTO Timestamp VALUES (0)",
+			"CREATE TABLE Name2Id (user_name TEXT UNIQUE)",
+		),
+	}
+}
+
+func (f *streamDB) add(talker string, seq int64, t time.Time) {
+	sum := md5.Sum([]byte(talker))
+	table := "Msg_" + hex.EncodeToString(sum[:])
+	for _, stmt := range []struct {
+		db    *sql.DB
+		query string
+		args  []interface{}
+	}{
+		{f.message, "CREATE TABLE IF NOT EXISTS " + table + " (sort_seq INTEGER, server_id INTEGER, local_type INTEGER, real_sender_id INTEGER, create_time INTEGER, message_content BLOB, packed_info_data BLOB, status INTEGER)", nil},
+		{f.message, "INSERT OR IGNORE INTO Name2Id VALUES (?)", []interface{}{talker}},
+		{f.message, "INSERT INTO " + table + " SELECT ?, 0, 1, rowid, ?, 'hello', NULL, 0 FROM Name2Id WHERE user_name = ?", []interface{}{seq, t.Unix(), talker}},
+		{f.session, "INSERT OR REPLACE INTO SessionTable VALUES (?, 'hello', ?, ?, '', ?)", []interface{}{talker, t.Unix(), talker, t.Unix()}},
+	} {
+		if _, err := stmt.db.Exec(stmt.query, stmt.args...); err != nil {
+			f.t.Fatal(err)
+		}
+	}
+}
+
+func TestGetStream(t *testing.T) {
+	delay := database.NotifyDelay
+	database.NotifyDelay = 10 * time.Millisecond
+	defer func() { database.NotifyDelay = delay }()
+
+	ds := newStreamDB(t)
+	c := &ctx.Context{WorkDir: ds.dir, Platform: "windows", Version: 4}
+	dbs := database.NewService(c)
+	if err := dbs.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer dbs.Stop()
+	s := NewService(c, dbs, nil)
+
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	// 其他网站的页面不能通过 SSE 或 WebSocket 读取新消息
+	for _, upgrade := range []string{"", "websocket"} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/stream", nil)
+		req.Header.Set("Origin", "http://evil.example.com")
+		if upgrade != "" {
+			req.Header.Set("Connection", "Upgrade")
+			req.Header.Set("Upgrade", upgrade)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("cross-site %q stream: %d", upgrade, resp.StatusCode)
+		}
+	}
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(reqCtx, http.MethodGet, server.URL+"/api/v1/stream?talker=wxid_a", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") ||
+		resp.Header.Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("unexpected response: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	// 响应头返回时已完成订阅，只推送符合过滤条件的新消息
+	now := time.Now()
+	ds.add("wxid_b", 1, now)
+	ds.add("wxid_a", 2, now)
+	dbs.NotifyChanged()
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				t.Fatal("stream closed")
+			}
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			var msg model.Message
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatal(err)
+			}
+			if msg.Talker != "wxid_a" || msg.Seq != 2 {
+				t.Errorf("unexpected message: %s", data)
+			}
+			return
+		case <-timeout:
+			t.Fatal("no message received")
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util/thumbnail"
+)
+
+// ThumbnailDir 缩略图缓存目录，位于工作目录下
+const ThumbnailDir = "thumbnails"
+
+// thumbnailOptions 解析 w、h、fit 参数，未指定尺寸时 ok 为 false
+func thumbnailOptions(c *gin.Context) (opts thumbnail.Options, ok bool, err error) {
+	w, h := c.Query("w"), c.Query("h")
+	if w == "" && h == "" {
+		return opts, false, nil
+	}
+	if w != "" {
+		if opts.Width, err = strconv.Atoi(w); err != nil {
+			return opts, false, errors.InvalidArg("w")
+		}
+	}
+	if h != "" {
+		if opts.Height, err = strconv.Atoi(h); err != nil {
+			return opts, false, errors.InvalidArg("h")
+		}
+	}
+	if !opts.Valid() {
+		return opts, false, errors.InvalidArg("w/h")
+	}
+	fit, valid := thumbnail.ParseFit(c.Query("fit"))
+	if !valid {
+		return opts, false, errors.InvalidArg("fit")
+	}
+	opts.Fit = fit
+	return opts, true, nil
+}
+
+// getThumbnailer 返回工作目录对应的缩略图生成器，每个账号的工作目录使用独立的缓存
+func (s *Service) getThumbnailer(workDir string) *thumbnail.Thumbnailer {
+	dir := ""
+	if workDir != "" {
+		dir = filepath.Join(workDir, ThumbnailDir)
+	}
+
+	s.thumbMutex.Lock()
+	defer s.thumbMutex.Unlock()
+	t, ok := s.thumbnailers[dir]
+	if !ok {
+		t = thumbnail.NewThumbnailer(dir, thumbnail.DefaultCacheSize)
+		s.thumbnailers[dir] = t
+	}
+	return t
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/pkg/util/silk"
+)
+
+// VoiceDir 语音转码缓存目录，位于工作目录下
+const VoiceDir = "voices"
+
+// voiceOptions 解析 format、rate、bitrate 参数，默认转码为 24kHz 16kbps 的 MP3
+func voiceOptions(c *gin.Context) (silk.Options, error) {
+	var opts silk.Options
+	format, ok := silk.ParseFormat(c.Query("format"))
+	if !ok {
+		return opts, errors.InvalidArg("format")
+	}
+	opts.Format = format
+
+	var err error
+	if rate := c.Query("rate"); rate != "" {
+		if opts.SampleRate, err = strconv.Atoi(rate); err != nil {
+			return opts, errors.InvalidArg("rate")
+		}
+	}
+	if bitrate := c.Query("bitrate"); bitrate != "" {
+		if opts.Bitrate, err = strconv.Atoi(bitrate); err != nil {
+			return opts, errors.InvalidArg("bitrate")
+		}
+	}
+	if opts, err = opts.Normalize(); err != nil {
+		return opts, errors.InvalidArg(err.Error())
+	}
+	return opts, nil
+}
+
+// getTranscoder 返回工作目录对应的语音转码器，每个账号的工作目录使用独立的缓存
+func (s *Service) getTranscoder(workDir string) *silk.Transcoder {
+	dir := ""
+	if workDir != "" {
+		dir = filepath.Join(workDir, VoiceDir)
+	}
+
+	s.voiceMutex.Lock()
+	defer s.voiceMutex.Unlock()
+	t, ok := s.transcoders[dir]
+	if !ok {
+		t = silk.NewTranscoder(dir, 0)
+		s.transcoders[dir] = t
+	}
+	return t
+}
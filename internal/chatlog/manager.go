@@ -3,6 +3,7 @@ package chatlog
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/sjzar/chatlog/internal/chatlog/database"
 	"github.com/sjzar/chatlog/internal/chatlog/http"
 	"github.com/sjzar/chatlog/internal/chatlog/mcp"
+	"github.com/sjzar/chatlog/internal/chatlog/webhook"
 	"github.com/sjzar/chatlog/internal/chatlog/wechat"
 	iwechat "github.com/sjzar/chatlog/internal/wechat"
 	"github.com/sjzar/chatlog/pkg/util"
@@ -24,10 +26,11 @@ type Manager struct {
 	ctx  *ctx.Context
 
 	// Services
-	db     *database.Service
-	http   *http.Service
-	mcp    *mcp.Service
-	wechat *wechat.Service
+	db      *database.Service
+	http    *http.Service
+	mcp     *mcp.Service
+	wechat  *wechat.Service
+	webhook *webhook.Service
 
 	// Terminal UI
 	app *App
@@ -41,6 +44,9 @@ func New(configPath string) (*Manager, error) {
 		return nil, err
 	}
 
+	// 记录数据访问审计日志
+	openAudit(conf.GetConfig().ConfigDir)
+
 	// 创建应用上下文
 	ctx := ctx.New(conf)
 
@@ -48,17 +54,25 @@ func New(configPath string) (*Manager, error) {
 
 	db := database.NewService(ctx)
 
+	// 自动解密完成后检查新消息
+	wechat.AddDecryptCallback(func(dbFile string) {
+		db.NotifyChanged()
+	})
+
 	mcp := mcp.NewService(ctx, db)
 
 	http := http.NewService(ctx, db, mcp)
 
+	webhook := webhook.NewService(ctx, db)
+
 	return &Manager{
-		conf:   conf,
-		ctx:    ctx,
-		db:     db,
-		mcp:    mcp,
-		http:   http,
-		wechat: wechat,
+		conf:    conf,
+		ctx:     ctx,
+		db:      db,
+		mcp:     mcp,
+		http:    http,
+		wechat:  wechat,
+		webhook: webhook,
 	}, nil
 }
 
@@ -126,6 +140,13 @@ func (m *Manager) StartService() error {
 		return err
 	}
 
+	if err := m.webhook.Start(); err != nil {
+		m.http.Stop() // 回滚已启动的服务
+		m.mcp.Stop()
+		m.db.Stop()
+		return err
+	}
+
 	// 如果是 4.0 版本，更新下 xorkey
 	if m.ctx.Version == 4 {
 		go dat2img.ScanAndSetXorKey(m.ctx.DataDir)
@@ -152,6 +173,10 @@ func (m *Manager) stopService() error {
 	// 按依赖的反序停止服务
 	var errs []error
 
+	if err := m.webhook.Stop(); err != nil {
+		errs = append(errs, err)
+	}
+
 	if err := m.http.Stop(); err != nil {
 		errs = append(errs, err)
 	}
@@ -345,9 +370,59 @@ func (m *Manager) CommandHTTPServer(addr string, dataDir string, workDir string,
 		return err
 	}
 
+	if err := m.webhook.Start(); err != nil {
+		return err
+	}
+
 	return m.http.ListenAndServe()
 }
 
+// CommandMCPServer 通过标准输入输出提供 MCP 服务
+// 指定 workDir 时使用参数中的目录，否则使用历史配置中的 account，account 为空时使用上次使用的账号
+// token 用于选择访问控制规则，与 HTTP 请求头中的令牌相同
+func (m *Manager) CommandMCPServer(account string, dataDir string, workDir string, platform string, version int, token string) error {
+
+	if workDir != "" {
+		if platform == "" {
+			return fmt.Errorf("platform is required")
+		}
+		if version == 0 {
+			return fmt.Errorf("version is required")
+		}
+		m.ctx.DataDir = dataDir
+		m.ctx.WorkDir = workDir
+		m.ctx.Platform = platform
+		m.ctx.Version = version
+	} else {
+		if account != "" {
+			if _, ok := m.ctx.GetHistory(account); !ok {
+				return fmt.Errorf("account %s not found in history", account)
+			}
+			m.ctx.SwitchHistory(account)
+		}
+		if m.ctx.WorkDir == "" {
+			return fmt.Errorf("no account available, please decrypt data first or specify workDir")
+		}
+	}
+
+	// 如果是 4.0 版本，更新下 xorkey
+	if m.ctx.Version == 4 && m.ctx.DataDir != "" {
+		go dat2img.ScanAndSetXorKey(m.ctx.DataDir)
+	}
+
+	if err := m.db.Start(); err != nil {
+		return err
+	}
+	defer m.db.Stop()
+
+	if err := m.mcp.Start(); err != nil {
+		return err
+	}
+
+	// 输入结束后等待已接收的请求处理完成，避免响应未写出就退出
+	return m.mcp.ServeStdio(os.Stdin, os.Stdout, token)
+}
+
 // Context 返回 Manager 的上下文
 func (m *Manager) Context() *ctx.Context {
 	return m.ctx
@@ -0,0 +1,42 @@
+package mcp
+
+import (
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/pkg/audit"
+)
+
+// recordAudit 记录一次工具调用或资源读取的审计日志，result 为查询结果
+func (s *Service) recordAudit(session *mcp.Session, account, action string, params map[string]string, result interface{}, err error) {
+	if account == "" {
+		account = s.ctx.Account
+	}
+	e := audit.Entry{
+		Source:  "mcp",
+		Caller:  callerOf(session),
+		Account: account,
+		Action:  action,
+		Params:  params,
+	}
+	e.Count, e.Talkers = database.Tally(result)
+	if err != nil {
+		e.Error = err.Error()
+	}
+	if err := audit.Record(e); err != nil {
+		log.Debug().Err(err).Msg("failed to record audit log")
+	}
+}
+
+// callerOf 返回 MCP 客户端标识，格式为 name/version
+func callerOf(session *mcp.Session) string {
+	info := session.ClientInfo()
+	if info == nil || info.Name == "" {
+		return "unknown"
+	}
+	if info.Version == "" {
+		return info.Name
+	}
+	return info.Name + "/" + info.Version
+}
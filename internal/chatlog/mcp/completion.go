@@ -0,0 +1,131 @@
+package mcp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/wechatdb/repository"
+)
+
+// 补全的对象类型
+const (
+	completeNone = iota
+	completeTalker
+	completeContact
+	completeChatRoom
+	completeSender
+)
+
+// completionKind 根据引用与参数名确定补全的对象类型
+func completionKind(ref mcp.CompleteRef, argument string) int {
+	switch argument {
+	case "talker":
+		return completeTalker
+	case "sender":
+		return completeSender
+	case "chatroom":
+		return completeChatRoom
+	case "keyword":
+		// 只有联系人与群聊查询的 keyword 是名称，chatlog 工具的 keyword 为消息内容
+		if ref.Type == mcp.RefTypeTool {
+			switch ref.Name {
+			case ToolContact.Name:
+				return completeContact
+			case ToolChatRoom.Name:
+				return completeChatRoom
+			}
+		}
+	case "id":
+		if ref.Type == mcp.RefTypeResource {
+			switch ref.URI {
+			case ResourceTemplateContact.URITemplate:
+				return completeContact
+			case ResourceTemplateChatRoomMembers.URITemplate:
+				return completeChatRoom
+			}
+		}
+	}
+	return completeNone
+}
+
+// complete 处理 completion/complete，补全联系人、群聊与群成员名称，最近有会话的对象优先
+// 多个对象以 "," 分隔时只补全最后一个
+func (s *Service) complete(session *mcp.Session, req *mcp.Request) error {
+	completeReq, err := parseParams[mcp.CompleteRequest](req.Params)
+	if err != nil {
+		return fmt.Errorf("解析补全参数失败: %v", err)
+	}
+	result := mcp.CompleteResult{Values: []string{}}
+
+	kind := completionKind(completeReq.Ref, completeReq.Argument.Name)
+	args := completeReq.Context.Arguments
+	db, err := s.accountOf(session, args["account"])
+	if kind == completeNone || err != nil || !db.Ready() {
+		return session.WriteResponse(req, mcp.CompleteResponse{Completion: result})
+	}
+
+	prefix, key := "", completeReq.Argument.Value
+	if i := strings.LastIndex(key, ","); i >= 0 {
+		prefix, key = key[:i+1], key[i+1:]
+	}
+
+	var list []repository.Completion
+	switch kind {
+	case completeTalker:
+		list = db.CompleteTalkers(key, true, true)
+	case completeContact:
+		list = db.CompleteTalkers(key, true, false)
+	case completeChatRoom:
+		list = db.CompleteTalkers(key, false, true)
+	case completeSender:
+		// 指定了群聊时补全群成员，否则补全联系人
+		if talker := args["talker"]; talker != "" && !strings.Contains(talker, ",") {
+			list = db.CompleteChatRoomMembers(talker, key)
+		}
+		if len(list) == 0 {
+			list = db.CompleteTalkers(key, true, false)
+		}
+	}
+	rankByRecency(db, list)
+
+	result.Total = len(list)
+	if len(list) > mcp.CompletionMaxValues {
+		list = list[:mcp.CompletionMaxValues]
+		result.HasMore = true
+	}
+	for _, c := range list {
+		result.Values = append(result.Values, prefix+c.Value)
+	}
+	return session.WriteResponse(req, mcp.CompleteResponse{Completion: result})
+}
+
+// rankByRecency 匹配程度相同时，最近有会话的对象排在前面
+func rankByRecency(db *database.Service, list []repository.Completion) {
+	rank := make(map[string]int)
+	if data, err := db.GetSessions("", 0, 0); err != nil {
+		log.Debug().Err(err).Msg("failed to get sessions for completion")
+	} else {
+		for i, session := range data.Items {
+			if _, ok := rank[session.UserName]; !ok {
+				rank[session.UserName] = i
+			}
+		}
+	}
+	recency := func(userName string) int {
+		if i, ok := rank[userName]; ok {
+			return i
+		}
+		return len(rank)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Score != list[j].Score {
+			return list[i].Score < list[j].Score
+		}
+		return recency(list[i].UserName) < recency(list[j].UserName)
+	})
+}
@@ -0,0 +1,31 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+)
+
+func TestCompletionKind(t *testing.T) {
+	tests := []struct {
+		ref      mcp.CompleteRef
+		argument string
+		want     int
+	}{
+		{mcp.CompleteRef{Type: mcp.RefTypePrompt, Name: PromptPersonTopic.Name}, "talker", completeTalker},
+		{mcp.CompleteRef{Type: mcp.RefTypePrompt, Name: PromptPersonTopic.Name}, "sender", completeSender},
+		{mcp.CompleteRef{Type: mcp.RefTypePrompt, Name: PromptPersonTopic.Name}, "topic", completeNone},
+		{mcp.CompleteRef{Type: mcp.RefTypeResource, URI: ResourceTemplateContact.URITemplate}, "id", completeContact},
+		{mcp.CompleteRef{Type: mcp.RefTypeResource, URI: ResourceTemplateChatRoomMembers.URITemplate}, "id", completeChatRoom},
+		{mcp.CompleteRef{Type: mcp.RefTypeResource, URI: ResourceTemplateChat.URITemplate}, "talker", completeTalker},
+		{mcp.CompleteRef{Type: mcp.RefTypeTool, Name: ToolContact.Name}, "keyword", completeContact},
+		{mcp.CompleteRef{Type: mcp.RefTypeTool, Name: ToolChatRoom.Name}, "keyword", completeChatRoom},
+		{mcp.CompleteRef{Type: mcp.RefTypeTool, Name: ToolChatLog.Name}, "keyword", completeNone},
+		{mcp.CompleteRef{Type: mcp.RefTypeTool, Name: ToolChatRoomMembers.Name}, "chatroom", completeChatRoom},
+	}
+	for _, tt := range tests {
+		if got := completionKind(tt.ref, tt.argument); got != tt.want {
+			t.Errorf("completionKind(%v, %s) = %d, want %d", tt.ref, tt.argument, got, tt.want)
+		}
+	}
+}
@@ -4,11 +4,53 @@ import (
 	"github.com/sjzar/chatlog/internal/mcp"
 )
 
+// accountProperty 工具的账号参数，为空时使用当前账号
+var accountProperty = mcp.M{
+	"type":        "string",
+	"description": "要查询的微信账号，为空时使用当前账号。服务管理多个账号时可通过query_account获取账号列表",
+}
+
+// statsTimeProperty 统计工具的时间范围参数
+var statsTimeProperty = mcp.M{
+	"type":        "string",
+	"description": "统计的时间范围，格式与chatlog工具相同，如 today、last-7d、2025-04-01~2025-04-30、all，默认为 last-30d",
+}
+
+// statsEntrySchema 统计项，key 为发送者ID、日期（2006-01-02）或消息类型
+var statsEntrySchema = objectSchema(mcp.M{
+	"key":   stringType,
+	"name":  stringType,
+	"count": integerType,
+}, "name")
+
+// 提示词的公共参数
+var (
+	promptTalkerArgument = mcp.PromptArgument{
+		Name:        "talker",
+		Description: "联系人或群聊，可以是ID、备注名或昵称",
+		Required:    true,
+	}
+	promptTimeArgument = mcp.PromptArgument{
+		Name:        "time",
+		Description: "时间范围，格式与chatlog工具相同，如 today、last-7d、2025-04-01~2025-04-30，默认为 last-7d",
+	}
+	promptAccountArgument = mcp.PromptArgument{
+		Name:        "account",
+		Description: "微信账号，为空时使用当前账号",
+	}
+)
+
 // MCPTools 和资源定义
 var (
 	InitializeResponse = mcp.InitializeResponse{
 		ProtocolVersion: mcp.ProtocolVersion,
-		Capabilities:    mcp.DefaultCapabilities,
+		Capabilities: mcp.M{
+			"completions":  mcp.M{},
+			"experimental": mcp.M{},
+			"prompts":      mcp.M{"listChanged": false},
+			"resources":    mcp.M{"subscribe": true, "listChanged": false},
+			"tools":        mcp.M{"listChanged": false},
+		},
 		ServerInfo: mcp.ServerInfo{
 			Name:    "chatlog",
 			Version: "0.0.1",
@@ -25,9 +67,17 @@ var (
 					"type":        "string",
 					"description": "联系人的搜索关键词，可以是姓名、备注名或ID。",
 				},
+				"cursor":  cursorProperty,
+				"account": accountProperty,
 			},
 			Required: []string{"keyword"},
 		},
+		OutputSchema: listSchema("contacts", objectSchema(mcp.M{
+			"userName": stringType,
+			"alias":    stringType,
+			"remark":   stringType,
+			"nickName": stringType,
+		}), true),
 	}
 
 	ToolChatRoom = mcp.Tool{
@@ -40,18 +90,36 @@ var (
 					"type":        "string",
 					"description": "群聊的搜索关键词，可以是群名称、群ID或相关描述",
 				},
+				"cursor":  cursorProperty,
+				"account": accountProperty,
 			},
 			Required: []string{"keyword"},
 		},
+		OutputSchema: listSchema("chatRooms", objectSchema(mcp.M{
+			"name":      stringType,
+			"remark":    stringType,
+			"nickName":  stringType,
+			"owner":     stringType,
+			"userCount": integerType,
+		}), true),
 	}
 
 	ToolRecentChat = mcp.Tool{
 		Name:        "query_recent_chat",
 		Description: "查询最近会话列表，包括个人聊天和群聊。当用户想了解最近的聊天记录、查看最近联系过的人或群组时使用此工具。不需要参数，直接返回最近的会话列表。",
 		InputSchema: mcp.ToolSchema{
-			Type:       "object",
-			Properties: mcp.M{},
+			Type: "object",
+			Properties: mcp.M{
+				"cursor":  cursorProperty,
+				"account": accountProperty,
+			},
 		},
+		OutputSchema: listSchema("sessions", objectSchema(mcp.M{
+			"userName": stringType,
+			"nickName": stringType,
+			"content":  stringType,
+			"time":     stringType,
+		}), true),
 	}
 
 	ToolChatLog = mcp.Tool{
@@ -165,9 +233,37 @@ var (
   3. 错误示例：对所有找到的关键词消息一次性查询大范围上下文
   4. 正确示例：对每个时间点T分别执行查询"T前后15-30分钟"（不带keyword）`,
 				},
+				"cursor":  cursorProperty,
+				"account": accountProperty,
 			},
 			Required: []string{"time", "talker"},
 		},
+		OutputSchema: listSchema("messages", objectSchema(mcp.M{
+			"seq":        integerType,
+			"time":       stringType,
+			"talker":     stringType,
+			"talkerName": stringType,
+			"sender":     stringType,
+			"senderName": stringType,
+			"isSelf":     booleanType,
+			"type":       integerType,
+			"content":    stringType,
+		}, "talkerName", "senderName"), true),
+	}
+
+	ToolAccount = mcp.Tool{
+		Name:        "query_account",
+		Description: "查询可访问的微信账号列表，第一个为当前账号。当服务同时管理多个微信账号，用户询问其他账号的联系人、群聊或聊天记录时，先使用此工具获取账号名称，再在其他工具的account参数中指定。",
+		InputSchema: mcp.ToolSchema{
+			Type:       "object",
+			Properties: mcp.M{},
+		},
+		OutputSchema: listSchema("accounts", objectSchema(mcp.M{
+			"account":  stringType,
+			"platform": stringType,
+			"version":  integerType,
+			"current":  booleanType,
+		}), false),
 	}
 
 	ToolCurrentTime = mcp.Tool{
@@ -183,29 +279,189 @@ var (
 			Type:       "object",
 			Properties: mcp.M{},
 		},
+		OutputSchema: &mcp.ToolSchema{
+			Type:       "object",
+			Properties: mcp.M{"time": stringType},
+			Required:   []string{"time"},
+		},
+	}
+
+	ToolChatStats = mcp.Tool{
+		Name:        "chat_stats",
+		Description: "统计联系人或群聊在一段时间内的消息数量，按发送者、日期和消息类型分别计数。当用户询问\"群里谁最活跃\"、\"哪天聊得最多\"、\"发了多少图片\"等统计问题时使用此工具，无需逐页查询聊天记录。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "联系人或群聊，可以是ID、备注名或昵称",
+				},
+				"time": statsTimeProperty,
+				"top": mcp.M{
+					"type":        "integer",
+					"description": "发送者排行返回的数量，默认为20",
+				},
+				"account": accountProperty,
+			},
+			Required: []string{"talker"},
+		},
+		OutputSchema: &mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"talker":    stringType,
+				"total":     integerType,
+				"firstTime": stringType,
+				"lastTime":  stringType,
+				"senders":   mcp.M{"type": "array", "items": statsEntrySchema},
+				"days":      mcp.M{"type": "array", "items": statsEntrySchema},
+				"types":     mcp.M{"type": "array", "items": statsEntrySchema},
+			},
+			Required: []string{"days", "senders", "talker", "total", "types"},
+		},
+	}
+
+	ToolChatRoomMembers = mcp.Tool{
+		Name:        "chatroom_members",
+		Description: "查询群聊的成员列表，包括成员的群昵称以及一段时间内的发言数量，按发言数量降序排列。当用户询问\"群里有哪些人\"、\"某人在群里叫什么\"、\"谁从来不说话\"时使用此工具。自己发送的消息单独计入selfCount。",
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"chatroom": mcp.M{
+					"type":        "string",
+					"description": "群聊，可以是群ID、备注名或群名称",
+				},
+				"time":    statsTimeProperty,
+				"account": accountProperty,
+			},
+			Required: []string{"chatroom"},
+		},
+		OutputSchema: &mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"chatRoom":    stringType,
+				"name":        stringType,
+				"owner":       stringType,
+				"activeCount": integerType,
+				"selfCount":   integerType,
+				"members": mcp.M{"type": "array", "items": objectSchema(mcp.M{
+					"userName":     stringType,
+					"displayName":  stringType,
+					"messageCount": integerType,
+					"lastTime":     stringType,
+				}, "lastTime")},
+			},
+			Required: []string{"activeCount", "chatRoom", "members", "name", "owner", "selfCount"},
+		},
+	}
+
+	ToolMedia = mcp.Tool{
+		Name: "get_media",
+		Description: `获取聊天记录中的图片或语音内容。chatlog工具返回的图片、语音为链接，无法直接查看，需要了解图片内容（如截图、照片）或语音内容时使用此工具。
+- 图片以image内容返回，超过max_size时等比缩小
+- 语音转码为MP3，以audio内容返回
+可以通过talker和seq指定消息（seq见chatlog工具结构化结果中的seq字段），也可以直接使用图片链接中的md5`,
+		InputSchema: mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"talker": mcp.M{
+					"type":        "string",
+					"description": "消息所在的联系人或群聊，与seq一起使用",
+				},
+				"seq": mcp.M{
+					"type":        "integer",
+					"description": "消息序号，与talker一起使用",
+				},
+				"md5": mcp.M{
+					"type":        "string",
+					"description": "图片链接中的md5，或语音链接中的语音ID，指定seq时忽略",
+				},
+				"type": mcp.M{
+					"type":        "string",
+					"enum":        []string{"image", "voice"},
+					"description": "使用md5查询时的媒体类型，默认为image",
+				},
+				"max_size": mcp.M{
+					"type":        "integer",
+					"description": "图片的最大宽高，默认为1568",
+				},
+				"account": accountProperty,
+			},
+		},
+		OutputSchema: &mcp.ToolSchema{
+			Type: "object",
+			Properties: mcp.M{
+				"type":     stringType,
+				"key":      stringType,
+				"mimeType": stringType,
+				"size":     integerType,
+				"duration": mcp.M{"type": "number", "description": "语音时长，单位为秒"},
+			},
+			Required: []string{"key", "mimeType", "size", "type"},
+		},
+	}
+
+	PromptSummarize = mcp.Prompt{
+		Name:        "summarize_chat",
+		Description: "总结指定联系人或群聊在一段时间内的聊天内容",
+		Arguments:   []mcp.PromptArgument{promptTalkerArgument, promptTimeArgument, promptAccountArgument},
+	}
+
+	PromptPersonTopic = mcp.Prompt{
+		Name:        "person_topic",
+		Description: "查看某人在联系人或群聊中关于某个话题说了什么",
+		Arguments: []mcp.PromptArgument{
+			promptTalkerArgument,
+			{Name: "sender", Description: "发言人，可以是ID、备注名或昵称", Required: true},
+			{Name: "topic", Description: "话题", Required: true},
+			promptTimeArgument,
+			promptAccountArgument,
+		},
+	}
+
+	PromptActionItems = mcp.Prompt{
+		Name:        "action_items",
+		Description: "列出联系人或群聊中分配给我的待办事项",
+		Arguments:   []mcp.PromptArgument{promptTalkerArgument, promptTimeArgument, promptAccountArgument},
 	}
 
 	ResourceRecentChat = mcp.Resource{
 		Name:        "最近会话",
-		URI:         "session://recent",
+		URI:         "chatlog://session/recent",
 		Description: "获取最近的聊天会话列表",
+		MimeType:    "text/plain",
 	}
 
 	ResourceTemplateContact = mcp.ResourceTemplate{
 		Name:        "联系人信息",
-		URITemplate: "contact://{username}",
-		Description: "获取指定联系人的详细信息",
+		URITemplate: "chatlog://contact/{id}{?account}",
+		Description: "获取指定联系人的详细信息，id 可以是ID、备注名或昵称",
+		MimeType:    "application/json",
 	}
 
-	ResourceTemplateChatRoom = mcp.ResourceTemplate{
-		Name:        "群聊信息",
-		URITemplate: "chatroom://{roomid}",
-		Description: "获取指定群聊的详细信息",
+	ResourceTemplateChatRoomMembers = mcp.ResourceTemplate{
+		Name:        "群成员",
+		URITemplate: "chatlog://chatroom/{id}/members{?account}",
+		Description: "获取指定群聊的成员列表",
+		MimeType:    "text/csv",
 	}
 
-	ResourceTemplateChatlog = mcp.ResourceTemplate{
+	ResourceTemplateChat = mcp.ResourceTemplate{
 		Name:        "聊天记录",
-		URITemplate: "chatlog://{talker}/{timeframe}?limit,offset",
-		Description: "获取与特定联系人或群聊的聊天记录",
+		URITemplate: "chatlog://chat/{talker}/{timeRange}{?limit,offset,account}",
+		Description: "获取与特定联系人或群聊的聊天记录，timeRange 格式与chatlog工具相同，如 last-7d",
+		MimeType:    "text/plain",
 	}
 )
+
+// Tools 提供的工具，tools/list 按此顺序返回，工具调用的监控指标也以此为准
+var Tools = []mcp.Tool{
+	ToolContact,
+	ToolChatRoom,
+	ToolRecentChat,
+	ToolChatLog,
+	ToolChatStats,
+	ToolChatRoomMembers,
+	ToolMedia,
+	ToolAccount,
+	ToolCurrentTime,
+}
@@ -0,0 +1,227 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+	"github.com/sjzar/chatlog/pkg/util/dat2img"
+	"github.com/sjzar/chatlog/pkg/util/silk"
+	"github.com/sjzar/chatlog/pkg/util/thumbnail"
+)
+
+const (
+	// MediaMaxSize 图片默认的最大边长，超出时等比缩小，减少传给模型的数据量
+	MediaMaxSize = 1568
+
+	// MediaVoiceMimeType 语音转码为 MP3 后的类型
+	MediaVoiceMimeType = "audio/mpeg"
+)
+
+// MediaResult get_media 工具的结构化输出
+type MediaResult struct {
+	Type     string  `json:"type"`
+	Key      string  `json:"key"`
+	MimeType string  `json:"mimeType"`
+	Size     int     `json:"size"`
+	Duration float64 `json:"duration,omitempty"`
+}
+
+// getMedia 读取消息中的图片或语音，返回 image 或 audio 内容块
+// 指定 talker 与 seq 时从消息中获取媒体，否则按 md5 查询，result 用于审计记录
+func getMedia(ctx context.Context, db *database.Service, args mcp.M) (content mcp.Content, out *MediaResult, result interface{}, err error) {
+	_type, _ := args["type"].(string)
+	if _type == "" {
+		_type = "image"
+	}
+	var keys []string
+	if v, ok := args["seq"]; ok && v != nil {
+		talker, _ := args["talker"].(string)
+		if talker == "" {
+			return content, nil, nil, fmt.Errorf("按seq获取媒体时必须指定talker")
+		}
+		seq, err := seqArg(v)
+		if err != nil {
+			return content, nil, nil, err
+		}
+		msg, err := findMessage(ctx, db, talker, seq)
+		if err != nil {
+			return content, nil, nil, err
+		}
+		result = msg
+		if _type, keys, err = messageMediaKeys(msg); err != nil {
+			return content, nil, result, err
+		}
+	} else {
+		md5, _ := args["md5"].(string)
+		if md5 == "" {
+			return content, nil, nil, fmt.Errorf("必须指定md5，或同时指定talker与seq")
+		}
+		keys = util.Str2List(md5, ",")
+	}
+
+	maxSize := util.MustAnyToInt(args["max_size"])
+	if maxSize <= 0 {
+		maxSize = MediaMaxSize
+	}
+	if maxSize > thumbnail.MaxSize {
+		maxSize = thumbnail.MaxSize
+	}
+
+	var lastErr error
+	for _, key := range keys {
+		media, err := lookupMedia(db, _type, key)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if result == nil {
+			result = media
+		}
+		switch _type {
+		case "voice":
+			data, err := silk.Transcode(media.Data, silk.Options{Format: silk.FormatMP3})
+			if err != nil {
+				return content, nil, result, fmt.Errorf("语音转码失败: %v", err)
+			}
+			out = &MediaResult{Type: _type, Key: key, MimeType: MediaVoiceMimeType, Size: len(data), Duration: silk.Duration(media.Data).Seconds()}
+			return mcp.Content{Type: "audio", Data: base64.StdEncoding.EncodeToString(data), MimeType: out.MimeType}, out, result, nil
+		default:
+			data, mimeType, err := readImage(filepath.Join(db.GetContext().DataDir, media.Path), maxSize)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			out = &MediaResult{Type: _type, Key: key, MimeType: mimeType, Size: len(data)}
+			return mcp.Content{Type: "image", Data: base64.StdEncoding.EncodeToString(data), MimeType: mimeType}, out, result, nil
+		}
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("未找到媒体文件")
+	}
+	return content, nil, result, lastErr
+}
+
+// seqArg 解析消息序号，JSON 中的数字会被解析为 float64
+func seqArg(v interface{}) (int64, error) {
+	switch seq := v.(type) {
+	case float64:
+		return int64(seq), nil
+	case int64:
+		return seq, nil
+	case int:
+		return int64(seq), nil
+	case string:
+		n, err := strconv.ParseInt(seq, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的seq: %s", seq)
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("无效的seq: %v", v)
+}
+
+// findMessage 根据序号查找消息，序号的前 10 位为消息的时间戳
+func findMessage(ctx context.Context, db *database.Service, talker string, seq int64) (*model.Message, error) {
+	t := time.Unix(seq/1000, 0)
+	messages, err := db.GetMessagesContext(ctx, t.Add(-time.Second), t.Add(time.Second), talker, "", "", 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("无法获取聊天记录: %v", err)
+	}
+	for _, m := range messages {
+		if m.Seq == seq {
+			return m, nil
+		}
+	}
+	return nil, fmt.Errorf("未找到消息: %s %d", talker, seq)
+}
+
+// messageMediaKeys 返回消息中的媒体类型与媒体标识，图片依次尝试原图与缩略图
+func messageMediaKeys(m *model.Message) (string, []string, error) {
+	switch m.Type {
+	case 3:
+		keys := make([]string, 0, 3)
+		for _, k := range []string{"md5", "imgfile", "thumb"} {
+			if v, ok := m.Contents[k].(string); ok && v != "" {
+				keys = append(keys, v)
+			}
+		}
+		return "image", keys, nil
+	case 34:
+		if v, ok := m.Contents["voice"]; ok {
+			return "voice", []string{fmt.Sprint(v)}, nil
+		}
+	}
+	return "", nil, fmt.Errorf("消息不包含图片或语音: %d", m.Seq)
+}
+
+// lookupMedia 查询媒体，key 不是 MD5 时作为数据目录下的相对路径，不允许绝对路径或跳出数据目录
+func lookupMedia(db *database.Service, _type, key string) (*model.Media, error) {
+	if _type == "voice" || isMD5(key) {
+		return db.GetMedia(_type, key)
+	}
+	path := filepath.Clean(key)
+	if !filepath.IsLocal(path) {
+		return nil, fmt.Errorf("无效的媒体路径: %s", key)
+	}
+	if !db.AllowMediaPath(path) {
+		return nil, fmt.Errorf("无权访问: %s", key)
+	}
+	return &model.Media{Type: _type, Key: key, Path: path}, nil
+}
+
+func isMD5(key string) bool {
+	if len(key) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+// readImage 读取图片，解密 .dat 文件并将超过 maxSize 的图片等比缩小
+// 无法缩放时返回解密后的原图，GIF 缩放后只保留第一帧
+func readImage(path string, maxSize int) ([]byte, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("无法读取图片: %v", err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == ".dat" {
+		out, outExt, err := dat2img.Dat2Image(data)
+		if err != nil {
+			return nil, "", fmt.Errorf("无法解密图片: %v", err)
+		}
+		data, ext = out, "."+outExt
+	}
+
+	out, outExt, err := thumbnail.Make(data, thumbnail.Options{Width: maxSize, Height: maxSize, Fit: thumbnail.FitContain})
+	if err != nil {
+		log.Debug().Err(err).Msgf("failed to resize image: %s", path)
+	} else {
+		data, ext = out, "."+outExt
+	}
+
+	mimeType := mime.TypeByExtension(ext)
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType = http.DetectContentType(data)
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, "", fmt.Errorf("不支持的图片格式: %s", path)
+	}
+	return data, mimeType, nil
+}
@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+func TestSeqArg(t *testing.T) {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(`{"seq": 1713430140000, "str": "1713430140001"}`), &args); err != nil {
+		t.Fatal(err)
+	}
+	if seq, err := seqArg(args["seq"]); err != nil || seq != 1713430140000 {
+		t.Errorf("seqArg(number) = %d, %v", seq, err)
+	}
+	if seq, err := seqArg(args["str"]); err != nil || seq != 1713430140001 {
+		t.Errorf("seqArg(string) = %d, %v", seq, err)
+	}
+	if _, err := seqArg("abc"); err == nil {
+		t.Error("invalid seq should be rejected")
+	}
+}
+
+func TestMessageMediaKeys(t *testing.T) {
+	image := &model.Message{Type: 3, Contents: map[string]interface{}{"md5": "0123456789abcdef0123456789abcdef", "thumb": "FileStorage/Image/Thumb/a.dat"}}
+	_type, keys, err := messageMediaKeys(image)
+	if err != nil || _type != "image" || !reflect.DeepEqual(keys, []string{"0123456789abcdef0123456789abcdef", "FileStorage/Image/Thumb/a.dat"}) {
+		t.Errorf("messageMediaKeys(image) = %s, %v, %v", _type, keys, err)
+	}
+
+	voice := &model.Message{Type: 34, Contents: map[string]interface{}{"voice": int64(123)}}
+	_type, keys, err = messageMediaKeys(voice)
+	if err != nil || _type != "voice" || !reflect.DeepEqual(keys, []string{"123"}) {
+		t.Errorf("messageMediaKeys(voice) = %s, %v, %v", _type, keys, err)
+	}
+
+	if _, _, err := messageMediaKeys(&model.Message{Type: 1}); err == nil {
+		t.Error("text message should be rejected")
+	}
+}
+
+func TestLookupMediaPath(t *testing.T) {
+	db := database.NewService(&ctx.Context{})
+	for _, key := range []string{"../../home/x.png", "/etc/passwd", "a/../../x.png", ".."} {
+		if _, err := lookupMedia(db, "image", key); err == nil {
+			t.Errorf("lookupMedia(%q) should be rejected", key)
+		}
+	}
+	media, err := lookupMedia(db, "image", "FileStorage/Image/../Image/a.dat")
+	if err != nil || media.Path != filepath.Join("FileStorage", "Image", "a.dat") {
+		t.Errorf("lookupMedia(relative) = %+v, %v", media, err)
+	}
+}
@@ -0,0 +1,36 @@
+package mcp
+
+import (
+	"github.com/sjzar/chatlog/pkg/metrics"
+)
+
+var (
+	toolCalls  = metrics.NewCounter("chatlog_mcp_tool_calls_total", "Number of MCP tool calls.", "tool")
+	toolErrors = metrics.NewCounter("chatlog_mcp_tool_errors_total", "Number of failed MCP tool calls.", "tool")
+)
+
+// knownTools tools/list 返回的工具名称，作为监控指标的 tool 标签
+var knownTools = func() map[string]bool {
+	names := make(map[string]bool, len(Tools))
+	for _, t := range Tools {
+		names[t.Name] = true
+	}
+	return names
+}()
+
+// toolLabel 返回工具调用的监控标签，未知工具统一记为 unknown，避免标签数量不受控制
+func toolLabel(name string) string {
+	if knownTools[name] {
+		return name
+	}
+	return "unknown"
+}
+
+// countToolCall 记录工具调用
+func countToolCall(name string, err error) {
+	tool := toolLabel(name)
+	toolCalls.With(tool).Inc()
+	if err != nil {
+		toolErrors.With(tool).Inc()
+	}
+}
@@ -0,0 +1,21 @@
+package mcp
+
+import "testing"
+
+func TestToolLabel(t *testing.T) {
+	// tools/list 中的每个工具都有独立的监控标签
+	labels := make(map[string]bool)
+	for _, tool := range Tools {
+		label := toolLabel(tool.Name)
+		if label != tool.Name {
+			t.Errorf("toolLabel(%s) = %s", tool.Name, label)
+		}
+		if labels[label] {
+			t.Errorf("duplicate label: %s", label)
+		}
+		labels[label] = true
+	}
+	if got := toolLabel("no_such_tool"); got != "unknown" {
+		t.Errorf("toolLabel(no_such_tool) = %s", got)
+	}
+}
@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	// PromptDefaultTime 提示词未指定时间范围时使用的默认值
+	PromptDefaultTime = "last-7d"
+
+	// PromptMessageLimit 提示词中嵌入的最大消息数，超出时截断
+	PromptMessageLimit = 2000
+)
+
+// Prompts 提供的提示词
+var Prompts = []mcp.Prompt{
+	PromptSummarize,
+	PromptPersonTopic,
+	PromptActionItems,
+}
+
+// promptsGet 处理提示词获取，预先查询聊天记录并嵌入提示词，客户端无需再调用工具
+func (s *Service) promptsGet(ctx context.Context, session *mcp.Session, req *mcp.Request) (err error) {
+	getReq, err := parseParams[mcp.PromptsGetRequest](req.Params)
+	if err != nil {
+		return fmt.Errorf("解析提示词参数失败: %v", err)
+	}
+
+	var prompt *mcp.Prompt
+	for i := range Prompts {
+		if Prompts[i].Name == getReq.Name {
+			prompt = &Prompts[i]
+			break
+		}
+	}
+	if prompt == nil {
+		return fmt.Errorf("未支持的提示词: %s", getReq.Name)
+	}
+
+	args := make(map[string]string, len(getReq.Arguments))
+	for k, v := range getReq.Arguments {
+		if v != nil {
+			args[k] = strings.TrimSpace(fmt.Sprint(v))
+		}
+	}
+	for _, arg := range prompt.Arguments {
+		if arg.Required && args[arg.Name] == "" {
+			return fmt.Errorf("缺少参数: %s", arg.Name)
+		}
+	}
+
+	var messages []*model.Message
+	defer func() {
+		s.recordAudit(session, args["account"], prompt.Name, args, messages, err)
+	}()
+	db, err := s.accountOf(session, args["account"])
+	if err != nil {
+		return fmt.Errorf("无法加载账号 %s: %v", args["account"], err)
+	}
+	if !db.Ready() {
+		return fmt.Errorf("数据尚未加载完成，请稍后重试")
+	}
+
+	timeRange := args["time"]
+	if timeRange == "" {
+		timeRange = PromptDefaultTime
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return fmt.Errorf("无法解析时间范围")
+	}
+	talker := args["talker"]
+	sender := ""
+	if prompt.Name == PromptPersonTopic.Name {
+		sender = args["sender"]
+	}
+	messages, err = db.GetMessagesContext(ctx, start, end, talker, sender, "", PromptMessageLimit+1, 0)
+	if err != nil {
+		return fmt.Errorf("无法获取聊天记录: %v", err)
+	}
+	truncated := len(messages) > PromptMessageLimit
+	if truncated {
+		messages = messages[:PromptMessageLimit]
+	}
+
+	buf := &strings.Builder{}
+	switch prompt.Name {
+	case PromptSummarize.Name:
+		fmt.Fprintf(buf, "请总结「%s」在 %s 的聊天记录。按话题归纳主要讨论内容，列出达成的结论、仍未解决的问题和需要跟进的事项，涉及具体的人时注明是谁。", talker, timeRange)
+	case PromptPersonTopic.Name:
+		fmt.Fprintf(buf, "以下是「%s」在「%s」中 %s 的发言记录。请找出其中与「%s」相关的内容，概括其观点和态度，并引用关键原话及发言时间。如果没有相关发言，请直接说明。", args["sender"], talker, timeRange, args["topic"])
+	case PromptActionItems.Name:
+		fmt.Fprintf(buf, "请从「%s」在 %s 的聊天记录中找出分配给我或我答应要做的待办事项，发送者为「我」的消息是我发出的。每条待办列出内容、提出人、提出时间和截止时间（如有），按截止时间排序。没有待办事项时请直接说明。", talker, timeRange)
+	}
+
+	buf.WriteString("\n\n聊天记录：\n\n")
+	if len(messages) == 0 {
+		buf.WriteString("未找到符合查询条件的聊天记录\n")
+	}
+	for _, m := range messages {
+		buf.WriteString(m.PlainText(strings.Contains(talker, ","), util.PerfectTimeFormat(start, end), ""))
+		buf.WriteString("\n")
+	}
+	if truncated {
+		fmt.Fprintf(buf, "（聊天记录过多，仅包含最早的 %d 条，回答时请说明结果不完整）\n", PromptMessageLimit)
+	}
+
+	resp := mcp.PromptsGetResponse{
+		Description: prompt.Description,
+		Messages: []mcp.PromptMessage{
+			{Role: "user", Content: mcp.PromptContent{Type: "text", Text: buf.String()}},
+		},
+	}
+	return session.WriteResponse(req, resp)
+}
@@ -0,0 +1,181 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// 资源类型，对应 chatlog://{kind}/... 中的 kind
+const (
+	ResourceKindSession  = "session"
+	ResourceKindContact  = "contact"
+	ResourceKindChatRoom = "chatroom"
+	ResourceKindChat     = "chat"
+
+	// RecentChatResources resources/list 中列出的最近会话数量
+	RecentChatResources = 20
+
+	// RecentChatTimeRange resources/list 中最近会话聊天记录的时间范围
+	RecentChatTimeRange = "last-7d"
+)
+
+// isCurrentResource 判断 URI 是否为 chatlog://{kind}/... 格式的资源，其余 chatlog:// URI 按旧格式 chatlog://{talker}/{timeframe} 处理
+func isCurrentResource(u *url.URL) bool {
+	if u.Scheme != "chatlog" || u.User != nil {
+		return false
+	}
+	switch u.Host {
+	case ResourceKindSession, ResourceKindContact, ResourceKindChatRoom, ResourceKindChat:
+		return true
+	}
+	return false
+}
+
+// legacyTalker 返回旧格式 URI 中的聊天对象，群聊 ID 中的 @ 会被解析为 userinfo，需要拼接回来
+func legacyTalker(u *url.URL) string {
+	if u.User != nil {
+		return u.User.String() + "@" + u.Host
+	}
+	return u.Host
+}
+
+// resourcesList 处理资源列表，除最近会话列表外，还会列出最近会话的聊天记录，客户端可以直接作为上下文引用
+func (s *Service) resourcesList(session *mcp.Session, req *mcp.Request) error {
+	resources := []mcp.Resource{ResourceRecentChat}
+
+	db, err := s.dbOf(session)
+	if err != nil {
+		return err
+	}
+	if db.Ready() {
+		data, err := db.GetSessions("", RecentChatResources, 0)
+		if err != nil {
+			log.Debug().Err(err).Msg("failed to list recent sessions")
+		} else {
+			s.recordAudit(session, "", "resources/list", nil, data, nil)
+			for _, item := range data.Items {
+				name := item.NickName
+				if name == "" {
+					name = item.UserName
+				}
+				resources = append(resources, mcp.Resource{
+					URI:         fmt.Sprintf("chatlog://%s/%s/%s", ResourceKindChat, url.PathEscape(item.UserName), RecentChatTimeRange),
+					Name:        name,
+					Description: fmt.Sprintf("与 %s 最近 7 天的聊天记录", name),
+					MimeType:    "text/plain",
+				})
+			}
+		}
+	}
+
+	return s.sendCustomParams(session, req, mcp.M{"resources": resources})
+}
+
+// readResource 读取 chatlog://{kind}/... 格式的资源
+func readResource(ctx context.Context, db *database.Service, u *url.URL) (text string, mimeType string, result interface{}, err error) {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	id := segments[0]
+
+	switch u.Host {
+	case ResourceKindSession:
+		// chatlog://session/recent
+		data, err := db.GetSessions("", util.MustAnyToInt(u.Query().Get("limit")), util.MustAnyToInt(u.Query().Get("offset")))
+		if err != nil {
+			return "", "", nil, fmt.Errorf("无法获取会话列表: %v", err)
+		}
+		buf := &bytes.Buffer{}
+		for _, session := range data.Items {
+			buf.WriteString(session.PlainText(120))
+			buf.WriteString("\n")
+		}
+		return buf.String(), "text/plain", data, nil
+
+	case ResourceKindContact:
+		// chatlog://contact/{id}
+		if id == "" {
+			return "", "", nil, fmt.Errorf("缺少联系人ID")
+		}
+		list, err := db.GetContacts(id, 0, 0)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("无法获取联系人: %v", err)
+		}
+		if len(list.Items) == 0 {
+			return "", "", nil, fmt.Errorf("未找到联系人: %s", id)
+		}
+		contact := list.Items[0]
+		for _, c := range list.Items {
+			if c.UserName == id {
+				contact = c
+				break
+			}
+		}
+		b, err := json.Marshal(contact)
+		if err != nil {
+			return "", "", nil, err
+		}
+		return string(b), "application/json", contact, nil
+
+	case ResourceKindChatRoom:
+		// chatlog://chatroom/{id}/members
+		if id == "" || len(segments) != 2 || segments[1] != "members" {
+			return "", "", nil, fmt.Errorf("不支持的URI: %s", u.String())
+		}
+		list, err := db.GetChatRooms(id, 0, 0)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("无法获取群聊: %v", err)
+		}
+		if len(list.Items) == 0 {
+			return "", "", nil, fmt.Errorf("未找到群聊: %s", id)
+		}
+		chatRoom := list.Items[0]
+		for _, c := range list.Items {
+			if c.Name == id {
+				chatRoom = c
+				break
+			}
+		}
+		buf := &bytes.Buffer{}
+		buf.WriteString("UserName,DisplayName\n")
+		for _, user := range chatRoom.Users {
+			buf.WriteString(fmt.Sprintf("%s,%s\n", user.UserName, user.DisplayName))
+		}
+		return buf.String(), "text/csv", chatRoom, nil
+
+	case ResourceKindChat:
+		// chatlog://chat/{talker}/{timeRange}，时间范围中可能包含 /
+		if id == "" || len(segments) < 2 {
+			return "", "", nil, fmt.Errorf("不支持的URI: %s", u.String())
+		}
+		start, end, ok := util.TimeRangeOf(strings.Join(segments[1:], "/"))
+		if !ok {
+			return "", "", nil, fmt.Errorf("无法解析时间范围")
+		}
+		limit := util.MustAnyToInt(u.Query().Get("limit"))
+		offset := util.MustAnyToInt(u.Query().Get("offset"))
+		messages, err := db.GetMessagesContext(ctx, start, end, id, "", "", limit, offset)
+		if err != nil {
+			return "", "", nil, fmt.Errorf("无法获取聊天记录: %v", err)
+		}
+		buf := &bytes.Buffer{}
+		if len(messages) == 0 {
+			buf.WriteString("未找到符合查询条件的聊天记录")
+		}
+		for _, m := range messages {
+			buf.WriteString(m.PlainText(strings.Contains(id, ","), util.PerfectTimeFormat(start, end), ""))
+			buf.WriteString("\n")
+		}
+		return buf.String(), "text/plain", messages, nil
+	}
+
+	return "", "", nil, fmt.Errorf("不支持的URI: %s", u.String())
+}
@@ -2,16 +2,23 @@ package mcp
 
 import (
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/sjzar/chatlog/internal/chatlog/ctx"
 	"github.com/sjzar/chatlog/internal/chatlog/database"
 	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/pkg/metrics"
 	"github.com/sjzar/chatlog/pkg/util"
 
 	"github.com/gin-gonic/gin"
@@ -21,13 +28,21 @@ type Service struct {
 	ctx *ctx.Context
 	db  *database.Service
 
-	mcp *mcp.MCP
+	mcp      *mcp.MCP
+	wg       sync.WaitGroup
+	subs     subscriptions
+	requests requests
+
+	// pending 已从队列取出、等待空闲 worker 的请求数量
+	pending atomic.Int64
 }
 
 func NewService(ctx *ctx.Context, db *database.Service) *Service {
 	return &Service{
-		ctx: ctx,
-		db:  db,
+		ctx:      ctx,
+		db:       db,
+		subs:     subscriptions{sessions: make(map[string]map[string]func())},
+		requests: requests{cancels: make(map[string]context.CancelCauseFunc)},
 	}
 }
 
@@ -39,31 +54,24 @@ func (s *Service) GetMCP() *mcp.MCP {
 // Start 启动MCP服务
 func (s *Service) Start() error {
 	s.mcp = mcp.NewMCP()
+	queue := s.mcp.ProcessChan
+	metrics.NewGaugeFunc("chatlog_mcp_queue_depth", "Number of MCP requests waiting to be processed.", func() float64 {
+		return float64(len(queue)) + float64(s.pending.Load())
+	})
+	s.wg.Add(1)
 	go s.worker()
 	return nil
 }
 
-// Stop 停止MCP服务
+// Stop 停止MCP服务，等待队列中的请求处理完成
 func (s *Service) Stop() error {
 	if s.mcp != nil {
 		s.mcp.Close()
+		s.wg.Wait()
 	}
 	return nil
 }
 
-// worker 处理MCP请求
-func (s *Service) worker() {
-	for {
-		select {
-		case p, ok := <-s.mcp.ProcessChan:
-			if !ok {
-				return
-			}
-			s.processMCP(p.Session, p.Request)
-		}
-	}
-}
-
 func (s *Service) HandleSSE(c *gin.Context) {
 	s.mcp.HandleSSE(c)
 }
@@ -72,41 +80,77 @@ func (s *Service) HandleMessages(c *gin.Context) {
 	s.mcp.HandleMessages(c)
 }
 
+func (s *Service) HandleStreamable(c *gin.Context) {
+	s.mcp.HandleStreamable(c)
+}
+
+// ServeStdio 通过标准输入输出提供 MCP 服务，并在输入结束后停止服务
+// 输入结束不代表连接断开，等待已接收的请求处理完成、响应写出后再结束会话
+func (s *Service) ServeStdio(r io.Reader, w io.Writer, token string) error {
+	// stdio 会话的令牌来自启动参数，与 HTTP 请求头中的令牌相同，用于选择访问控制规则
+	var auth interface{}
+	if token != "" {
+		db, err := s.db.ForToken(token)
+		if err != nil {
+			return err
+		}
+		if a := db.Access(); a != nil {
+			auth = a
+		}
+	}
+	session := mcp.NewStdioSession(w, auth)
+	err := s.mcp.ServeStdio(r, session)
+	s.Stop()
+	session.Close()
+	return err
+}
+
 // processMCP 处理MCP请求
-func (s *Service) processMCP(session *mcp.Session, req *mcp.Request) {
+// ctx 在请求被取消或会话结束时取消，除被客户端取消的请求外，每个请求都会返回响应或错误
+func (s *Service) processMCP(ctx context.Context, session *mcp.Session, req *mcp.Request) {
 	var err error
 	switch req.Method {
 	case mcp.MethodInitialize:
 		err = s.initialize(session, req)
 	case mcp.MethodToolsList:
-		err = s.sendCustomParams(session, req, mcp.M{"tools": []mcp.Tool{
-			ToolContact,
-			ToolChatRoom,
-			ToolRecentChat,
-			ToolChatLog,
-			ToolCurrentTime,
-		}})
+		err = s.sendCustomParams(session, req, mcp.M{"tools": Tools})
 	case mcp.MethodToolsCall:
-		err = s.toolsCall(session, req)
+		err = s.toolsCall(ctx, session, req)
 	case mcp.MethodPromptsList:
-		err = s.sendCustomParams(session, req, mcp.M{"prompts": []mcp.Prompt{}})
+		err = s.sendCustomParams(session, req, mcp.M{"prompts": Prompts})
+	case mcp.MethodPromptsGet:
+		err = s.promptsGet(ctx, session, req)
 	case mcp.MethodResourcesList:
-		err = s.sendCustomParams(session, req, mcp.M{"resources": []mcp.Resource{
-			ResourceRecentChat,
-		}})
+		err = s.resourcesList(session, req)
 	case mcp.MethodResourcesTemplateList:
 		err = s.sendCustomParams(session, req, mcp.M{"resourceTemplates": []mcp.ResourceTemplate{
 			ResourceTemplateContact,
-			ResourceTemplateChatRoom,
-			ResourceTemplateChatlog,
+			ResourceTemplateChatRoomMembers,
+			ResourceTemplateChat,
 		}})
 	case mcp.MethodResourcesRead:
-		err = s.resourcesRead(session, req)
+		err = s.resourcesRead(ctx, session, req)
+	case mcp.MethodResourcesSubscribe:
+		err = s.resourcesSubscribe(session, req)
+	case mcp.MethodResourcesUnsubscribe:
+		err = s.resourcesUnsubscribe(session, req)
+	case mcp.MethodCompletionComplete:
+		err = s.complete(session, req)
 	case mcp.MethodPing:
 		err = s.sendCustomParams(session, req, struct{}{})
+	default:
+		// 通知不需要响应
+		if req.ID != nil {
+			err = session.WriteJsonRPCError(req, mcp.ErrMethodNotFound)
+		}
 	}
 
 	if err != nil {
+		// 被客户端取消的请求不需要响应
+		if requestCancelled(ctx) {
+			session.Cancelled(req)
+			return
+		}
 		session.WriteError(req, err)
 	}
 }
@@ -119,63 +163,128 @@ func (s *Service) initialize(session *mcp.Session, req *mcp.Request) error {
 	}
 	session.SaveClientInfo(initReq.ClientInfo)
 
-	return session.WriteResponse(req, InitializeResponse)
+	resp := InitializeResponse
+	resp.ProtocolVersion = mcp.NegotiateProtocolVersion(initReq.ProtocolVersion)
+	return session.WriteResponse(req, resp)
 }
 
 // toolsCall 处理工具调用
-func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
+func (s *Service) toolsCall(ctx context.Context, session *mcp.Session, req *mcp.Request) (err error) {
 	callReq, err := parseParams[mcp.ToolsCallRequest](req.Params)
 	if err != nil {
 		return fmt.Errorf("解析工具调用参数失败: %v", err)
 	}
+	defer func() { countToolCall(callReq.Name, err) }()
+
+	account := ""
+	if v, ok := callReq.Arguments["account"]; ok {
+		account, _ = v.(string)
+	}
+	var result interface{}
+	if callReq.Name != "current_time" {
+		defer func() {
+			params := make(map[string]string, len(callReq.Arguments))
+			for k, v := range callReq.Arguments {
+				params[k] = fmt.Sprint(v)
+			}
+			s.recordAudit(session, account, callReq.Name, params, result, err)
+		}()
+	}
+	db, err := s.accountOf(session, account)
+	if err != nil {
+		return fmt.Errorf("无法加载账号 %s: %v", account, err)
+	}
+	if callReq.Name != "current_time" && callReq.Name != "query_account" && !db.Ready() {
+		return fmt.Errorf("数据尚未加载完成，请稍后重试")
+	}
 
 	buf := &bytes.Buffer{}
+	var structured interface{}
+	// extra 为文本结果之后的图片、语音等内容块
+	var extra []mcp.Content
 	switch callReq.Name {
 	case "query_contact":
 		keyword := ""
 		if v, ok := callReq.Arguments["keyword"]; ok {
 			keyword = v.(string)
 		}
-		limit := util.MustAnyToInt(callReq.Arguments["limit"])
-		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		list, err := s.db.GetContacts(keyword, limit, offset)
+		limit, offset, err := toolPage(callReq.Arguments, ToolPageSize)
+		if err != nil {
+			return err
+		}
+		list, err := db.GetContacts(keyword, limit+1, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取联系人列表: %v", err)
 		}
-		buf.WriteString("UserName,Alias,Remark,NickName\n")
+		next := nextCursor(limit, offset, len(list.Items))
+		if next != "" {
+			list.Items = list.Items[:limit]
+		}
+		result = list
+		out := &ContactResult{Contacts: make([]ContactItem, 0, len(list.Items)), NextCursor: next}
+		w := csv.NewWriter(buf)
+		w.Write([]string{"UserName", "Alias", "Remark", "NickName"})
 		for _, contact := range list.Items {
-			buf.WriteString(fmt.Sprintf("%s,%s,%s,%s\n", contact.UserName, contact.Alias, contact.Remark, contact.NickName))
+			w.Write([]string{contact.UserName, contact.Alias, contact.Remark, contact.NickName})
+			out.Contacts = append(out.Contacts, ContactItem{UserName: contact.UserName, Alias: contact.Alias, Remark: contact.Remark, NickName: contact.NickName})
 		}
+		w.Flush()
+		writeNextCursor(buf, next)
+		structured = out
 	case "query_chat_room":
 		keyword := ""
 		if v, ok := callReq.Arguments["keyword"]; ok {
 			keyword = v.(string)
 		}
-		limit := util.MustAnyToInt(callReq.Arguments["limit"])
-		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		list, err := s.db.GetChatRooms(keyword, limit, offset)
+		limit, offset, err := toolPage(callReq.Arguments, ToolPageSize)
+		if err != nil {
+			return err
+		}
+		list, err := db.GetChatRooms(keyword, limit+1, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取群聊列表: %v", err)
 		}
-		buf.WriteString("Name,Remark,NickName,Owner,UserCount\n")
+		next := nextCursor(limit, offset, len(list.Items))
+		if next != "" {
+			list.Items = list.Items[:limit]
+		}
+		result = list
+		out := &ChatRoomResult{ChatRooms: make([]ChatRoomItem, 0, len(list.Items)), NextCursor: next}
+		w := csv.NewWriter(buf)
+		w.Write([]string{"Name", "Remark", "NickName", "Owner", "UserCount"})
 		for _, chatRoom := range list.Items {
-			buf.WriteString(fmt.Sprintf("%s,%s,%s,%s,%d\n", chatRoom.Name, chatRoom.Remark, chatRoom.NickName, chatRoom.Owner, len(chatRoom.Users)))
+			w.Write([]string{chatRoom.Name, chatRoom.Remark, chatRoom.NickName, chatRoom.Owner, strconv.Itoa(len(chatRoom.Users))})
+			out.ChatRooms = append(out.ChatRooms, ChatRoomItem{Name: chatRoom.Name, Remark: chatRoom.Remark, NickName: chatRoom.NickName, Owner: chatRoom.Owner, UserCount: len(chatRoom.Users)})
 		}
+		w.Flush()
+		writeNextCursor(buf, next)
+		structured = out
 	case "query_recent_chat":
 		keyword := ""
 		if v, ok := callReq.Arguments["keyword"]; ok {
 			keyword = v.(string)
 		}
-		limit := util.MustAnyToInt(callReq.Arguments["limit"])
-		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		data, err := s.db.GetSessions(keyword, limit, offset)
+		limit, offset, err := toolPage(callReq.Arguments, ToolPageSize)
+		if err != nil {
+			return err
+		}
+		data, err := db.GetSessions(keyword, limit+1, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取会话列表: %v", err)
 		}
+		next := nextCursor(limit, offset, len(data.Items))
+		if next != "" {
+			data.Items = data.Items[:limit]
+		}
+		result = data
+		out := &SessionResult{Sessions: make([]SessionItem, 0, len(data.Items)), NextCursor: next}
 		for _, session := range data.Items {
 			buf.WriteString(session.PlainText(120))
 			buf.WriteString("\n")
+			out.Sessions = append(out.Sessions, SessionItem{UserName: session.UserName, NickName: session.NickName, Content: session.Content, Time: session.NTime.Format(time.RFC3339)})
 		}
+		writeNextCursor(buf, next)
+		structured = out
 	case "chatlog":
 		if callReq.Arguments == nil {
 			return mcp.ErrInvalidParams
@@ -200,36 +309,94 @@ func (s *Service) toolsCall(session *mcp.Session, req *mcp.Request) error {
 		if v, ok := callReq.Arguments["keyword"]; ok {
 			keyword = v.(string)
 		}
-		limit := util.MustAnyToInt(callReq.Arguments["limit"])
-		offset := util.MustAnyToInt(callReq.Arguments["offset"])
-		messages, err := s.db.GetMessages(start, end, talker, sender, keyword, limit, offset)
+		limit, offset, err := toolPage(callReq.Arguments, ToolMessagePageSize)
+		if err != nil {
+			return err
+		}
+		messages, err := db.GetMessagesContext(ctx, start, end, talker, sender, keyword, limit+1, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取聊天记录: %v", err)
 		}
+		next := nextCursor(limit, offset, len(messages))
+		if next != "" {
+			messages = messages[:limit]
+		}
+		result = messages
+		out := &MessageResult{Messages: make([]MessageItem, 0, len(messages)), NextCursor: next}
 		if len(messages) == 0 {
 			buf.WriteString("未找到符合查询条件的聊天记录")
 		}
 		for _, m := range messages {
 			buf.WriteString(m.PlainText(strings.Contains(talker, ","), util.PerfectTimeFormat(start, end), ""))
 			buf.WriteString("\n")
+			out.Messages = append(out.Messages, newMessageItem(m))
 		}
+		writeNextCursor(buf, next)
+		structured = out
+	case "chat_stats":
+		text, out, stats, err := chatStats(ctx, db, callReq.Arguments)
+		if err != nil {
+			return err
+		}
+		result = stats
+		buf.WriteString(text)
+		structured = out
+	case "chatroom_members":
+		text, out, chatRoom, err := chatRoomMembers(ctx, db, callReq.Arguments)
+		if err != nil {
+			return err
+		}
+		result = chatRoom
+		buf.WriteString(text)
+		structured = out
+	case "get_media":
+		content, out, media, err := getMedia(ctx, db, callReq.Arguments)
+		result = media
+		if err != nil {
+			return err
+		}
+		buf.WriteString(fmt.Sprintf("%s %s (%s, %d bytes)", out.Type, out.Key, out.MimeType, out.Size))
+		extra = append(extra, content)
+		structured = out
+	case "query_account":
+		root, err := s.dbOf(session)
+		if err != nil {
+			return err
+		}
+		out := &AccountResult{Accounts: make([]AccountItem, 0)}
+		w := csv.NewWriter(buf)
+		w.Write([]string{"Account", "Platform", "Version", "Current"})
+		for _, info := range root.Accounts() {
+			w.Write([]string{info.Account, info.Platform, strconv.Itoa(info.Version), strconv.FormatBool(info.Current)})
+			out.Accounts = append(out.Accounts, AccountItem{Account: info.Account, Platform: info.Platform, Version: info.Version, Current: info.Current})
+		}
+		w.Flush()
+		structured = out
 	case "current_time":
-		buf.WriteString(time.Now().Local().Format(time.RFC3339))
+		now := time.Now().Local().Format(time.RFC3339)
+		buf.WriteString(now)
+		structured = &TimeResult{Time: now}
 	default:
 		return fmt.Errorf("未支持的工具: %s", callReq.Name)
 	}
 
 	resp := mcp.ToolsCallResponse{
-		Content: []mcp.Content{
-			{Type: "text", Text: buf.String()},
-		},
-		IsError: false,
+		Content:           append([]mcp.Content{{Type: "text", Text: buf.String()}}, extra...),
+		StructuredContent: structured,
+		IsError:           false,
 	}
 	return session.WriteResponse(req, resp)
 }
 
+// writeNextCursor 结果被截断时在文本结果末尾提示如何获取后续结果
+func writeNextCursor(buf *bytes.Buffer, next string) {
+	if next != "" {
+		buf.WriteString(fmt.Sprintf("\n结果已截断，使用相同参数并指定 cursor=\"%s\" 获取后续结果\n", next))
+	}
+}
+
 // resourcesRead 处理资源读取
-func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
+func (s *Service) resourcesRead(ctx context.Context, session *mcp.Session, req *mcp.Request) (err error) {
 	readReq, err := parseParams[mcp.ResourcesReadRequest](req.Params)
 	if err != nil {
 		return fmt.Errorf("解析资源读取参数失败: %v", err)
@@ -240,31 +407,65 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 		return fmt.Errorf("无法解析URI: %v", err)
 	}
 
+	// chatlog://{kind}/... 为当前格式，contact://、chatroom://、session:// 与 chatlog://{talker}/{timeframe} 为旧格式
+	current := isCurrentResource(u)
+	action := u.Scheme
+	if current {
+		action = u.Host
+	}
+
+	var result interface{}
+	defer func() {
+		s.recordAudit(session, u.Query().Get("account"), action, map[string]string{"uri": readReq.URI}, result, err)
+	}()
+	db, err := s.accountOf(session, u.Query().Get("account"))
+	if err != nil {
+		return fmt.Errorf("无法加载账号: %v", err)
+	}
+	if !db.Ready() {
+		return fmt.Errorf("数据尚未加载完成，请稍后重试")
+	}
+
+	if current {
+		var text, mimeType string
+		if text, mimeType, result, err = readResource(ctx, db, u); err != nil {
+			return err
+		}
+		return session.WriteResponse(req, mcp.ReadingResource{
+			Contents: []mcp.ReadingResourceContent{
+				{URI: readReq.URI, MimeType: mimeType, Text: text},
+			},
+		})
+	}
+
 	buf := &bytes.Buffer{}
 	switch u.Scheme {
 	case "contact":
-		list, err := s.db.GetContacts(u.Host, 0, 0)
+		list, err := db.GetContacts(legacyTalker(u), 0, 0)
 		if err != nil {
 			return fmt.Errorf("无法获取联系人列表: %v", err)
 		}
+		result = list
 		buf.WriteString("UserName,Alias,Remark,NickName\n")
 		for _, contact := range list.Items {
 			buf.WriteString(fmt.Sprintf("%s,%s,%s,%s\n", contact.UserName, contact.Alias, contact.Remark, contact.NickName))
 		}
 	case "chatroom":
-		list, err := s.db.GetChatRooms(u.Host, 0, 0)
+		list, err := db.GetChatRooms(legacyTalker(u), 0, 0)
 		if err != nil {
 			return fmt.Errorf("无法获取群聊列表: %v", err)
 		}
+		result = list
 		buf.WriteString("Name,Remark,NickName,Owner,UserCount\n")
 		for _, chatRoom := range list.Items {
 			buf.WriteString(fmt.Sprintf("%s,%s,%s,%s,%d\n", chatRoom.Name, chatRoom.Remark, chatRoom.NickName, chatRoom.Owner, len(chatRoom.Users)))
 		}
 	case "session":
-		data, err := s.db.GetSessions("", 0, 0)
+		data, err := db.GetSessions("", 0, 0)
 		if err != nil {
 			return fmt.Errorf("无法获取会话列表: %v", err)
 		}
+		result = data
 		for _, session := range data.Items {
 			buf.WriteString(session.PlainText(120))
 			buf.WriteString("\n")
@@ -276,15 +477,17 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 		}
 		limit := util.MustAnyToInt(u.Query().Get("limit"))
 		offset := util.MustAnyToInt(u.Query().Get("offset"))
-		messages, err := s.db.GetMessages(start, end, u.Host, "", "", limit, offset)
+		talker := legacyTalker(u)
+		messages, err := db.GetMessagesContext(ctx, start, end, talker, "", "", limit, offset)
 		if err != nil {
 			return fmt.Errorf("无法获取聊天记录: %v", err)
 		}
+		result = messages
 		if len(messages) == 0 {
 			buf.WriteString("未找到符合查询条件的聊天记录")
 		}
 		for _, m := range messages {
-			buf.WriteString(m.PlainText(strings.Contains(u.Host, ","), util.PerfectTimeFormat(start, end), ""))
+			buf.WriteString(m.PlainText(strings.Contains(talker, ","), util.PerfectTimeFormat(start, end), ""))
 			buf.WriteString("\n")
 		}
 	default:
@@ -293,12 +496,36 @@ func (s *Service) resourcesRead(session *mcp.Session, req *mcp.Request) error {
 
 	resp := mcp.ReadingResource{
 		Contents: []mcp.ReadingResourceContent{
-			{URI: readReq.URI, Text: buf.String()},
+			{URI: readReq.URI, MimeType: "text/plain", Text: buf.String()},
 		},
 	}
 	return session.WriteResponse(req, resp)
 }
 
+// dbOf 返回 MCP 会话对应访问控制规则的数据库服务
+// 规则由创建会话时的令牌决定，客户端名称只用于校验令牌是否允许该客户端使用
+func (s *Service) dbOf(session *mcp.Session) (*database.Service, error) {
+	a, _ := session.Auth().(*database.Access)
+	name := ""
+	if info := session.ClientInfo(); info != nil {
+		name = info.Name
+	}
+	db, err := s.db.ForClient(a, name)
+	if err != nil {
+		return nil, fmt.Errorf("客户端 %s 无权访问: %v", name, err)
+	}
+	return db, nil
+}
+
+// accountOf 返回 MCP 客户端可访问的指定账号的数据库服务
+func (s *Service) accountOf(session *mcp.Session, account string) (*database.Service, error) {
+	db, err := s.dbOf(session)
+	if err != nil {
+		return nil, err
+	}
+	return db.Account(account)
+}
+
 // sendCustomParams 发送自定义参数
 func (s *Service) sendCustomParams(session *mcp.Session, req *mcp.Request, params interface{}) error {
 	b, err := json.Marshal(mcp.NewResponse(req.ID, params))
@@ -0,0 +1,208 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	// StatsDefaultTime 统计工具默认的时间范围
+	StatsDefaultTime = "last-30d"
+
+	// StatsDefaultTop 发送者排行默认返回的数量
+	StatsDefaultTop = 20
+)
+
+// StatsResult chat_stats 工具的结构化输出
+type StatsResult struct {
+	Talker    string       `json:"talker"`
+	Total     int          `json:"total"`
+	FirstTime string       `json:"firstTime,omitempty"`
+	LastTime  string       `json:"lastTime,omitempty"`
+	Senders   []StatsEntry `json:"senders"`
+	Days      []StatsEntry `json:"days"`
+	Types     []StatsEntry `json:"types"`
+}
+
+type StatsEntry struct {
+	Key   string `json:"key"`
+	Name  string `json:"name,omitempty"`
+	Count int    `json:"count"`
+}
+
+// MembersResult chatroom_members 工具的结构化输出
+type MembersResult struct {
+	ChatRoom    string       `json:"chatRoom"`
+	Name        string       `json:"name"`
+	Owner       string       `json:"owner"`
+	ActiveCount int          `json:"activeCount"`
+	SelfCount   int          `json:"selfCount"`
+	Members     []MemberItem `json:"members"`
+}
+
+type MemberItem struct {
+	UserName     string `json:"userName"`
+	DisplayName  string `json:"displayName"`
+	MessageCount int    `json:"messageCount"`
+	LastTime     string `json:"lastTime,omitempty"`
+}
+
+// statsTimeRange 解析统计工具的时间范围参数
+func statsTimeRange(args mcp.M) (time.Time, time.Time, error) {
+	timeRange, _ := args["time"].(string)
+	if timeRange == "" {
+		timeRange = StatsDefaultTime
+	}
+	start, end, ok := util.TimeRangeOf(timeRange)
+	if !ok {
+		return start, end, fmt.Errorf("无法解析时间范围")
+	}
+	return start, end, nil
+}
+
+// chatStats 统计聊天对象在时间范围内按发送者、日期与消息类型的消息数量
+func chatStats(ctx context.Context, db *database.Service, args mcp.M) (string, *StatsResult, *model.MessageStats, error) {
+	talker, _ := args["talker"].(string)
+	if talker == "" {
+		return "", nil, nil, fmt.Errorf("缺少talker参数")
+	}
+	start, end, err := statsTimeRange(args)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	top := util.MustAnyToInt(args["top"])
+	if top <= 0 {
+		top = StatsDefaultTop
+	}
+
+	stats, err := db.GetMessageStatsContext(ctx, start, end, talker)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("无法获取消息统计: %v", err)
+	}
+	ranked := stats.Top(top)
+
+	out := &StatsResult{
+		Talker:  talker,
+		Total:   stats.Total,
+		Senders: statsEntries(ranked.Senders),
+		Days:    statsEntries(ranked.Days),
+		Types:   statsEntries(ranked.Types),
+	}
+	if stats.Total > 0 {
+		out.FirstTime = stats.FirstTime.Format(time.RFC3339)
+		out.LastTime = stats.LastTime.Format(time.RFC3339)
+	}
+
+	buf := &bytes.Buffer{}
+	if stats.Total == 0 {
+		buf.WriteString("未找到符合查询条件的聊天记录")
+		return buf.String(), out, stats, nil
+	}
+	fmt.Fprintf(buf, "%s 共 %d 条消息，%s ~ %s\n", talker, stats.Total, out.FirstTime, out.LastTime)
+	w := csv.NewWriter(buf)
+	writeStatsTable(w, buf, fmt.Sprintf("\n发送者（前 %d 名，self 为自己）\n", top), out.Senders)
+	writeStatsTable(w, buf, "\n按日期\n", out.Days)
+	writeStatsTable(w, buf, "\n按消息类型\n", out.Types)
+	return buf.String(), out, stats, nil
+}
+
+func statsEntries(items []*model.StatsItem) []StatsEntry {
+	entries := make([]StatsEntry, 0, len(items))
+	for _, item := range items {
+		entries = append(entries, StatsEntry{Key: item.Key, Name: item.Name, Count: item.Count})
+	}
+	return entries
+}
+
+func writeStatsTable(w *csv.Writer, buf *bytes.Buffer, title string, entries []StatsEntry) {
+	buf.WriteString(title)
+	w.Write([]string{"Key", "Name", "Count"})
+	for _, e := range entries {
+		w.Write([]string{e.Key, e.Name, strconv.Itoa(e.Count)})
+	}
+	w.Flush()
+}
+
+// chatRoomMembers 返回群成员及其群昵称，并统计时间范围内各成员的发言数量
+// 自己发送的消息无法对应到成员 ID，单独计入 SelfCount
+func chatRoomMembers(ctx context.Context, db *database.Service, args mcp.M) (string, *MembersResult, *model.ChatRoom, error) {
+	id, _ := args["chatroom"].(string)
+	if id == "" {
+		return "", nil, nil, fmt.Errorf("缺少chatroom参数")
+	}
+	start, end, err := statsTimeRange(args)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	list, err := db.GetChatRooms(id, 0, 0)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("无法获取群聊: %v", err)
+	}
+	if len(list.Items) == 0 {
+		return "", nil, nil, fmt.Errorf("未找到群聊: %s", id)
+	}
+	chatRoom := list.Items[0]
+	for _, c := range list.Items {
+		if c.Name == id {
+			chatRoom = c
+			break
+		}
+	}
+
+	stats, err := db.GetMessageStatsContext(ctx, start, end, chatRoom.Name)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("无法获取消息统计: %v", err)
+	}
+	senders := make(map[string]*model.StatsItem, len(stats.Senders))
+	for _, item := range stats.Senders {
+		senders[item.Key] = item
+	}
+
+	out := &MembersResult{
+		ChatRoom: chatRoom.Name,
+		Name:     chatRoom.DisplayName(),
+		Owner:    chatRoom.Owner,
+		Members:  make([]MemberItem, 0, len(chatRoom.Users)),
+	}
+	if self, ok := senders[model.StatsSelfKey]; ok {
+		out.SelfCount = self.Count
+	}
+	for _, user := range chatRoom.Users {
+		member := MemberItem{UserName: user.UserName, DisplayName: user.DisplayName}
+		if name := chatRoom.User2DisplayName[user.UserName]; name != "" {
+			member.DisplayName = name
+		}
+		if item, ok := senders[user.UserName]; ok {
+			member.MessageCount = item.Count
+			member.LastTime = item.LastTime.Format(time.RFC3339)
+			out.ActiveCount++
+		}
+		out.Members = append(out.Members, member)
+	}
+	// 按发言数量降序，数量相同时保持群成员顺序
+	sort.SliceStable(out.Members, func(i, j int) bool {
+		return out.Members[i].MessageCount > out.Members[j].MessageCount
+	})
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s(%s) 群主 %s，共 %d 名成员，%s ~ %s 期间 %d 名成员发言，自己发送 %d 条消息\n",
+		out.Name, out.ChatRoom, out.Owner, len(out.Members), start.Format(time.DateOnly), end.Format(time.DateOnly), out.ActiveCount, out.SelfCount)
+	w := csv.NewWriter(buf)
+	w.Write([]string{"UserName", "DisplayName", "MessageCount", "LastTime"})
+	for _, m := range out.Members {
+		w.Write([]string{m.UserName, m.DisplayName, strconv.Itoa(m.MessageCount), m.LastTime})
+	}
+	w.Flush()
+	return buf.String(), out, chatRoom, nil
+}
@@ -0,0 +1,189 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// 工具单次返回的最大条目数，超出时截断并返回 nextCursor
+const (
+	ToolPageSize        = 200
+	ToolMessagePageSize = 500
+)
+
+// 工具的结构化输出，与 outputSchema 对应
+
+type ContactResult struct {
+	Contacts   []ContactItem `json:"contacts"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+type ContactItem struct {
+	UserName string `json:"userName"`
+	Alias    string `json:"alias"`
+	Remark   string `json:"remark"`
+	NickName string `json:"nickName"`
+}
+
+type ChatRoomResult struct {
+	ChatRooms  []ChatRoomItem `json:"chatRooms"`
+	NextCursor string         `json:"nextCursor,omitempty"`
+}
+
+type ChatRoomItem struct {
+	Name      string `json:"name"`
+	Remark    string `json:"remark"`
+	NickName  string `json:"nickName"`
+	Owner     string `json:"owner"`
+	UserCount int    `json:"userCount"`
+}
+
+type SessionResult struct {
+	Sessions   []SessionItem `json:"sessions"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+type SessionItem struct {
+	UserName string `json:"userName"`
+	NickName string `json:"nickName"`
+	Content  string `json:"content"`
+	Time     string `json:"time"`
+}
+
+type MessageResult struct {
+	Messages   []MessageItem `json:"messages"`
+	NextCursor string        `json:"nextCursor,omitempty"`
+}
+
+type MessageItem struct {
+	Seq        int64  `json:"seq"`
+	Time       string `json:"time"`
+	Talker     string `json:"talker"`
+	TalkerName string `json:"talkerName,omitempty"`
+	Sender     string `json:"sender"`
+	SenderName string `json:"senderName,omitempty"`
+	IsSelf     bool   `json:"isSelf"`
+	Type       int64  `json:"type"`
+	Content    string `json:"content"`
+}
+
+type AccountResult struct {
+	Accounts []AccountItem `json:"accounts"`
+}
+
+type AccountItem struct {
+	Account  string `json:"account"`
+	Platform string `json:"platform"`
+	Version  int    `json:"version"`
+	Current  bool   `json:"current"`
+}
+
+type TimeResult struct {
+	Time string `json:"time"`
+}
+
+func newMessageItem(m *model.Message) MessageItem {
+	return MessageItem{
+		Seq:        m.Seq,
+		Time:       m.Time.Format(time.RFC3339),
+		Talker:     m.Talker,
+		TalkerName: m.TalkerName,
+		Sender:     m.Sender,
+		SenderName: m.SenderName,
+		IsSelf:     m.IsSelf,
+		Type:       m.Type,
+		Content:    m.PlainTextContent(),
+	}
+}
+
+// cursorProperty 分页参数
+var cursorProperty = mcp.M{
+	"type":        "string",
+	"description": "上一次调用返回的nextCursor，用于获取后续结果，其余参数需与上一次调用保持一致",
+}
+
+// listSchema 返回列表结果的输出 schema，key 为列表字段名
+func listSchema(key string, item mcp.M, paged bool) *mcp.ToolSchema {
+	properties := mcp.M{
+		key: mcp.M{"type": "array", "items": item},
+	}
+	if paged {
+		properties["nextCursor"] = mcp.M{
+			"type":        "string",
+			"description": "存在更多结果时返回，作为cursor参数再次调用以获取后续结果",
+		}
+	}
+	return &mcp.ToolSchema{
+		Type:       "object",
+		Properties: properties,
+		Required:   []string{key},
+	}
+}
+
+// objectSchema 返回字段均为必填的对象 schema
+func objectSchema(properties mcp.M, optional ...string) mcp.M {
+	skip := make(map[string]bool, len(optional))
+	for _, k := range optional {
+		skip[k] = true
+	}
+	required := make([]string, 0, len(properties))
+	for k := range properties {
+		if !skip[k] {
+			required = append(required, k)
+		}
+	}
+	sort.Strings(required)
+	return mcp.M{"type": "object", "properties": properties, "required": required}
+}
+
+var (
+	stringType  = mcp.M{"type": "string"}
+	integerType = mcp.M{"type": "integer"}
+	booleanType = mcp.M{"type": "boolean"}
+)
+
+// toolPage 解析工具的分页参数，cursor 优先于 offset，limit 不能超过 max
+func toolPage(args mcp.M, max int) (limit, offset int, err error) {
+	limit = util.MustAnyToInt(args["limit"])
+	if limit <= 0 || limit > max {
+		limit = max
+	}
+	offset = util.MustAnyToInt(args["offset"])
+	if v, ok := args["cursor"].(string); ok && v != "" {
+		if offset, err = decodeCursor(v); err != nil {
+			return 0, 0, err
+		}
+	}
+	return limit, offset, nil
+}
+
+// nextCursor 查询时多取一条用于判断是否还有后续结果，n 为实际取到的条数
+func nextCursor(limit, offset, n int) string {
+	if n <= limit {
+		return ""
+	}
+	return encodeCursor(offset + limit)
+}
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("无效的cursor: %s", cursor)
+	}
+	offset, err := strconv.Atoi(string(b))
+	if err != nil || offset < 0 {
+		return 0, fmt.Errorf("无效的cursor: %s", cursor)
+	}
+	return offset, nil
+}
@@ -0,0 +1,32 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+)
+
+func TestToolPage(t *testing.T) {
+	limit, offset, err := toolPage(mcp.M{}, 100)
+	if err != nil || limit != 100 || offset != 0 {
+		t.Fatalf("toolPage(empty) = %d, %d, %v", limit, offset, err)
+	}
+
+	// 101 条说明还有后续结果
+	next := nextCursor(limit, offset, 101)
+	if next == "" {
+		t.Fatal("nextCursor should not be empty")
+	}
+	if nextCursor(limit, offset, 100) != "" {
+		t.Error("nextCursor should be empty when all results are returned")
+	}
+
+	limit, offset, err = toolPage(mcp.M{"limit": 500, "offset": 3, "cursor": next}, 100)
+	if err != nil || limit != 100 || offset != 100 {
+		t.Errorf("toolPage(cursor) = %d, %d, %v", limit, offset, err)
+	}
+
+	if _, _, err := toolPage(mcp.M{"cursor": "!!"}, 100); err == nil {
+		t.Error("invalid cursor should be rejected")
+	}
+}
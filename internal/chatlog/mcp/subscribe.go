@@ -0,0 +1,148 @@
+package mcp
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+// subscriptions 各会话订阅的资源，key 为会话 ID 与资源 URI
+type subscriptions struct {
+	mu       sync.Mutex
+	sessions map[string]map[string]func()
+}
+
+// watchedResource 订阅的资源对应的聊天对象与时间范围
+type watchedResource struct {
+	// talkers 为空表示所有聊天对象，用于最近会话列表
+	talkers   []string
+	timeRange string
+}
+
+// parseWatchedResource 解析可订阅的资源 URI，只有会话列表与聊天记录会随新消息更新
+func parseWatchedResource(u *url.URL) (*watchedResource, error) {
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	current := isCurrentResource(u)
+	switch {
+	case current && u.Host == ResourceKindSession, u.Scheme == "session":
+		return &watchedResource{}, nil
+	case current && u.Host == ResourceKindChat:
+		// chatlog://chat/{talker}/{timeRange}
+		if segments[0] == "" || len(segments) < 2 {
+			break
+		}
+		return &watchedResource{talkers: util.Str2List(segments[0], ","), timeRange: strings.Join(segments[1:], "/")}, nil
+	case u.Scheme == "chatlog" && !current:
+		// 旧格式 chatlog://{talker}/{timeframe}
+		if u.Host == "" {
+			break
+		}
+		return &watchedResource{talkers: util.Str2List(legacyTalker(u), ","), timeRange: strings.Join(segments, "/")}, nil
+	}
+	return nil, fmt.Errorf("资源不支持订阅: %s", u.String())
+}
+
+// match 判断新消息是否会更新资源，聊天对象可以是ID或显示名称，时间范围在每次检查时重新计算，如 last-7d
+func (w *watchedResource) match(m *model.Message) bool {
+	if len(w.talkers) > 0 {
+		found := false
+		for _, talker := range w.talkers {
+			if talker == m.Talker || (m.TalkerName != "" && talker == m.TalkerName) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if w.timeRange != "" {
+		start, end, ok := util.TimeRangeOf(w.timeRange)
+		if !ok || m.Time.Before(start) || m.Time.After(end) {
+			return false
+		}
+	}
+	return true
+}
+
+// resourcesSubscribe 订阅资源，收到相关的新消息时发送 notifications/resources/updated
+func (s *Service) resourcesSubscribe(session *mcp.Session, req *mcp.Request) error {
+	subReq, err := parseParams[mcp.ResourcesSubscribeRequest](req.Params)
+	if err != nil {
+		return fmt.Errorf("解析订阅参数失败: %v", err)
+	}
+	u, err := url.Parse(subReq.URI)
+	if err != nil {
+		return fmt.Errorf("无法解析URI: %v", err)
+	}
+	watched, err := parseWatchedResource(u)
+	if err != nil {
+		return err
+	}
+	db, err := s.accountOf(session, u.Query().Get("account"))
+	if err != nil {
+		return fmt.Errorf("无法加载账号: %v", err)
+	}
+
+	uri := subReq.URI
+	s.subs.mu.Lock()
+	uris, ok := s.subs.sessions[session.ID()]
+	if !ok {
+		uris = make(map[string]func())
+		s.subs.sessions[session.ID()] = uris
+		// 会话结束时取消该会话的所有订阅
+		go func() {
+			<-session.Done()
+			s.unsubscribeAll(session.ID())
+		}()
+	}
+	if _, ok := uris[uri]; !ok {
+		uris[uri] = db.Subscribe(func(messages []*model.Message) {
+			for _, m := range messages {
+				if watched.match(m) {
+					if err := session.Notify(mcp.NofiticationResourcesUpdated, mcp.M{"uri": uri}); err != nil {
+						log.Debug().Err(err).Msgf("failed to notify resource update: %s", uri)
+					}
+					return
+				}
+			}
+		})
+	}
+	s.subs.mu.Unlock()
+
+	return s.sendCustomParams(session, req, struct{}{})
+}
+
+// resourcesUnsubscribe 取消订阅资源
+func (s *Service) resourcesUnsubscribe(session *mcp.Session, req *mcp.Request) error {
+	subReq, err := parseParams[mcp.ResourcesSubscribeRequest](req.Params)
+	if err != nil {
+		return fmt.Errorf("解析订阅参数失败: %v", err)
+	}
+
+	s.subs.mu.Lock()
+	if unsubscribe, ok := s.subs.sessions[session.ID()][subReq.URI]; ok {
+		unsubscribe()
+		delete(s.subs.sessions[session.ID()], subReq.URI)
+	}
+	s.subs.mu.Unlock()
+
+	return s.sendCustomParams(session, req, struct{}{})
+}
+
+// unsubscribeAll 取消会话的所有订阅
+func (s *Service) unsubscribeAll(id string) {
+	s.subs.mu.Lock()
+	defer s.subs.mu.Unlock()
+	for _, unsubscribe := range s.subs.sessions[id] {
+		unsubscribe()
+	}
+	delete(s.subs.sessions, id)
+}
@@ -0,0 +1,45 @@
+package mcp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+func TestWatchedResource(t *testing.T) {
+	now := &model.Message{Talker: "123@chatroom", TalkerName: "工作群", Time: time.Now()}
+	old := &model.Message{Talker: "123@chatroom", TalkerName: "工作群", Time: time.Now().AddDate(0, -1, 0)}
+	other := &model.Message{Talker: "wxid_a", Time: time.Now()}
+
+	tests := []struct {
+		uri  string
+		msgs map[*model.Message]bool
+	}{
+		{"chatlog://session/recent", map[*model.Message]bool{now: true, other: true}},
+		{"chatlog://chat/123@chatroom/last-7d", map[*model.Message]bool{now: true, old: false, other: false}},
+		{"chatlog://chat/工作群,wxid_a/last-7d", map[*model.Message]bool{now: true, other: true}},
+		{"chatlog://123@chatroom/last-7d", map[*model.Message]bool{now: true, other: false}},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.uri)
+		w, err := parseWatchedResource(u)
+		if err != nil {
+			t.Errorf("parseWatchedResource(%s): %v", tt.uri, err)
+			continue
+		}
+		for m, want := range tt.msgs {
+			if got := w.match(m); got != want {
+				t.Errorf("%s match(%s, %s) = %v, want %v", tt.uri, m.Talker, m.Time.Format(time.DateOnly), got, want)
+			}
+		}
+	}
+
+	for _, uri := range []string{"chatlog://contact/wxid_a", "chatlog://chatroom/123@chatroom/members", "chatlog://chat/wxid_a"} {
+		u, _ := url.Parse(uri)
+		if _, err := parseWatchedResource(u); err == nil {
+			t.Errorf("%s should not be subscribable", uri)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/wechatdb/datasource/dbm"
+)
+
+const (
+	// WorkerCount 同时处理的 MCP 请求数量，耗时的查询不会阻塞其他客户端的请求
+	WorkerCount = 4
+
+	// WorkerQueueSize 等待空闲 worker 的请求数量上限，超出时返回服务繁忙错误
+	WorkerQueueSize = 64
+)
+
+// errRequestCancelled 请求被客户端通过 notifications/cancelled 取消
+var errRequestCancelled = errors.New("request cancelled")
+
+// requests 等待或正在处理的请求，key 为会话 ID 与请求 ID，用于响应 notifications/cancelled
+type requests struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelCauseFunc
+}
+
+// requestCancelled 判断请求是否被客户端取消，被取消的请求不返回响应
+// 会话结束导致的取消不属于此类，此时响应无法送达，是否写出没有影响
+func requestCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errRequestCancelled)
+}
+
+func requestKey(session *mcp.Session, id interface{}) string {
+	return session.ID() + "/" + mcp.RequestKey(id)
+}
+
+// add 登记请求，返回的 context 在请求被取消或会话结束（连接断开）时取消，处理完成后需要调用 done
+func (r *requests) add(session *mcp.Session, req *mcp.Request) (ctx context.Context, done func()) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	go func() {
+		select {
+		case <-session.Done():
+			cancel(nil)
+		case <-ctx.Done():
+		}
+	}()
+	if req.ID == nil {
+		return ctx, func() { cancel(nil) }
+	}
+
+	key := requestKey(session, req.ID)
+	r.mu.Lock()
+	r.cancels[key] = cancel
+	r.mu.Unlock()
+	return ctx, func() {
+		r.mu.Lock()
+		delete(r.cancels, key)
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancel 取消请求，请求不存在（已处理完成）时忽略
+func (r *requests) cancel(session *mcp.Session, id interface{}) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cancel, ok := r.cancels[requestKey(session, id)]
+	if ok {
+		cancel(errRequestCancelled)
+	}
+	return ok
+}
+
+// job 等待 worker 处理的请求
+type job struct {
+	mcp.ProcessCtx
+	ctx  context.Context
+	done func()
+}
+
+// worker 从队列中读取请求，交给 WorkerCount 个固定的处理协程
+// 取消通知不进入等待，处理中的请求占满 worker 时也能及时取消；等待的请求过多时返回服务繁忙错误
+func (s *Service) worker() {
+	defer s.wg.Done()
+
+	jobs := make(chan job, WorkerQueueSize)
+	var workers sync.WaitGroup
+	for i := 0; i < WorkerCount; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for j := range jobs {
+				s.pending.Add(-1)
+				s.process(j)
+			}
+		}()
+	}
+	defer workers.Wait()
+	defer close(jobs)
+
+	for p := range s.mcp.ProcessChan {
+		if p.Request.Method == mcp.NotificationCancelled {
+			s.cancelRequest(p.Session, p.Request)
+			continue
+		}
+
+		ctx, done := s.requests.add(p.Session, p.Request)
+		s.pending.Add(1)
+		select {
+		case jobs <- job{ProcessCtx: p, ctx: ctx, done: done}:
+		default:
+			s.pending.Add(-1)
+			done()
+			log.Debug().Msgf("session: %s, server busy, drop request: %s", p.Session.ID(), p.Request.Method)
+			if p.Request.ID != nil {
+				p.Session.WriteJsonRPCError(p.Request, mcp.ErrServerBusy)
+			}
+		}
+	}
+}
+
+// process 处理一个请求，等待期间被取消或会话已结束的请求不再处理
+func (s *Service) process(j job) {
+	defer j.done()
+	if j.ctx.Err() != nil {
+		// 不会再有响应，通知等待响应的连接停止等待
+		j.Session.Cancelled(j.Request)
+		return
+	}
+	s.processMCP(progressContext(j.ctx, j.Session, j.Request), j.Session, j.Request)
+}
+
+// cancelRequest 处理 notifications/cancelled，被取消的请求不再返回响应
+func (s *Service) cancelRequest(session *mcp.Session, req *mcp.Request) {
+	n, err := parseParams[mcp.CancelledNotification](req.Params)
+	if err != nil || n.RequestID == nil {
+		return
+	}
+	if s.requests.cancel(session, n.RequestID) {
+		log.Debug().Msgf("session: %s, request %v cancelled: %s", session.ID(), n.RequestID, n.Reason)
+	}
+}
+
+// progressContext 请求携带 progressToken 时，返回在查询跨多个数据库分片时发送 notifications/progress 的 context
+// 一次请求可能依次执行多个查询，后续查询的进度在前一个查询的基础上累加，保证进度递增
+func progressContext(ctx context.Context, session *mcp.Session, req *mcp.Request) context.Context {
+	token := req.ProgressToken()
+	if token == nil {
+		return ctx
+	}
+	var base, lastDone, lastTotal, sent int
+	sent = -1
+	return dbm.WithProgress(ctx, func(done, total int) {
+		// 会话结束后连接可能已被回收，不再发送；请求被取消时仍发送最后的进度
+		select {
+		case <-session.Done():
+			return
+		default:
+		}
+		if done < lastDone {
+			base += lastTotal
+		}
+		lastDone, lastTotal = done, total
+		if base+done <= sent {
+			return
+		}
+		sent = base + done
+		if err := session.Progress(token, float64(sent), float64(base+total), ""); err != nil {
+			log.Debug().Err(err).Msg("failed to send progress notification")
+		}
+	})
+}
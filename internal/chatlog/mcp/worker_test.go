@@ -0,0 +1,182 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/mcp"
+	"github.com/sjzar/chatlog/internal/wechatdb/datasource/dbm"
+)
+
+func TestProgressContext(t *testing.T) {
+	buf := &bytes.Buffer{}
+	session := mcp.NewStdioSession(buf, nil)
+	req := &mcp.Request{ID: 1, Method: mcp.MethodToolsCall, Params: map[string]interface{}{
+		"_meta": map[string]interface{}{"progressToken": "p1"},
+	}}
+	ctx := progressContext(context.Background(), session, req)
+
+	// 两次查询，第二次查询的进度在第一次的基础上累加
+	for _, p := range [][2]int{{0, 3}, {1, 3}, {1, 3}, {2, 3}, {0, 2}, {1, 2}} {
+		dbm.ReportProgress(ctx, p[0], p[1])
+	}
+
+	var got [][2]float64
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var n struct {
+			Method string                   `json:"method"`
+			Params mcp.ProgressNotification `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &n); err != nil {
+			t.Fatal(err)
+		}
+		if n.Method != mcp.NotificationProgress || n.Params.ProgressToken != "p1" {
+			t.Fatalf("unexpected notification: %s", scanner.Text())
+		}
+		got = append(got, [2]float64{n.Params.Progress, n.Params.Total})
+	}
+	want := [][2]float64{{0, 3}, {1, 3}, {2, 3}, {3, 5}, {4, 5}}
+	if len(got) != len(want) {
+		t.Fatalf("progress = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("progress = %v, want %v", got, want)
+			break
+		}
+	}
+
+	// 未携带 progressToken 时不发送进度
+	buf.Reset()
+	ctx = progressContext(context.Background(), session, &mcp.Request{ID: 2, Method: mcp.MethodToolsCall})
+	dbm.ReportProgress(ctx, 0, 1)
+	if buf.Len() != 0 {
+		t.Errorf("unexpected progress: %s", buf.String())
+	}
+
+	// 请求被取消时仍发送最后的进度，会话结束后不再发送
+	cancelCtx, cancel := context.WithCancel(context.Background())
+	ctx = progressContext(cancelCtx, session, req)
+	cancel()
+	dbm.ReportProgress(ctx, 1, 1)
+	if !strings.Contains(buf.String(), `"progress":1,"total":1`) {
+		t.Errorf("final progress not sent: %s", buf.String())
+	}
+	buf.Reset()
+	session.Close()
+	dbm.ReportProgress(ctx, 1, 1)
+	if buf.Len() != 0 {
+		t.Errorf("progress sent after session closed: %s", buf.String())
+	}
+}
+
+func TestRequestsCancel(t *testing.T) {
+	r := requests{cancels: make(map[string]context.CancelCauseFunc)}
+	session := mcp.NewStdioSession(&bytes.Buffer{}, nil)
+
+	ctx, done := r.add(session, &mcp.Request{ID: float64(1), Method: mcp.MethodToolsCall})
+	// 字符串 ID 与数字 ID 不是同一个请求
+	if r.cancel(session, "1") {
+		t.Error("string id should not match number id")
+	}
+	if !r.cancel(session, float64(1)) {
+		t.Fatal("request should be cancelled")
+	}
+	if !requestCancelled(ctx) {
+		t.Error("context should be cancelled by client")
+	}
+	done()
+	if r.cancel(session, float64(1)) {
+		t.Error("finished request should be removed")
+	}
+
+	// 会话结束时取消该会话的请求
+	ctx, done = r.add(session, &mcp.Request{ID: float64(2), Method: mcp.MethodToolsCall})
+	defer done()
+	session.Close()
+	<-ctx.Done()
+	if requestCancelled(ctx) {
+		t.Error("session close is not a client cancellation")
+	}
+}
+
+func TestServeStdioDrain(t *testing.T) {
+	s := NewService(nil, nil)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 输入结束时已接收的请求都要返回响应
+	const n = 50
+	in := &bytes.Buffer{}
+	for i := 1; i <= n; i++ {
+		fmt.Fprintf(in, `{"jsonrpc":"2.0","id":%d,"method":"ping"}`+"\n", i)
+	}
+	out := &bytes.Buffer{}
+	if err := s.ServeStdio(in, out, ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(out.String(), `"result":{}`); got != n {
+		t.Errorf("responses = %d, want %d", got, n)
+	}
+}
+
+// blockingWriter 写入时阻塞，直到 release 被关闭
+type blockingWriter struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	w.entered <- struct{}{}
+	<-w.release
+	return len(p), nil
+}
+
+type chanWriter chan []byte
+
+func (w chanWriter) Write(p []byte) (int, error) {
+	w <- append([]byte(nil), p...)
+	return len(p), nil
+}
+
+func TestWorkerBusy(t *testing.T) {
+	s := NewService(nil, nil)
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	base := mcp.NewStdioSession(&bytes.Buffer{}, nil)
+	bw := &blockingWriter{entered: make(chan struct{}, WorkerCount+WorkerQueueSize), release: make(chan struct{})}
+	defer close(bw.release)
+	blocked := base.WithWriter(bw)
+	send := func(session *mcp.Session, id int) {
+		s.mcp.ProcessChan <- mcp.ProcessCtx{Session: session, Request: &mcp.Request{ID: float64(id), Method: mcp.MethodPing}}
+	}
+
+	// 占满所有 worker 与等待队列
+	for i := 0; i < WorkerCount; i++ {
+		send(blocked, i)
+	}
+	for i := 0; i < WorkerCount; i++ {
+		<-bw.entered
+	}
+	for i := 0; i < WorkerQueueSize; i++ {
+		send(blocked, WorkerCount+i)
+	}
+
+	// 队列已满时立即返回服务繁忙错误，而不是等待
+	rw := make(chanWriter, 1)
+	send(base.WithWriter(rw), -1)
+	b := <-rw
+	if !strings.Contains(string(b), `"code":-32000`) || !strings.Contains(string(b), `"id":-1`) {
+		t.Errorf("unexpected response: %s", b)
+	}
+}
@@ -0,0 +1,301 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/pkg/config"
+	"github.com/sjzar/chatlog/pkg/util"
+)
+
+const (
+	EventNewMessages = "messages.new"
+
+	HeaderEvent     = "X-Chatlog-Event"
+	HeaderDelivery  = "X-Chatlog-Delivery"
+	HeaderSignature = "X-Chatlog-Signature"
+
+	DeadLetterFile = "webhook_dead_letter.jsonl"
+	QueueCap       = 100
+)
+
+var (
+	// RetryBaseDelay 首次重试的等待时间，之后每次翻倍
+	RetryBaseDelay = 1 * time.Second
+	// RetryMaxDelay 单次重试的最长等待时间
+	RetryMaxDelay = 60 * time.Second
+)
+
+// Payload Webhook 推送内容
+type Payload struct {
+	Event    string           `json:"event"`
+	Time     time.Time        `json:"time"`
+	Messages []*model.Message `json:"messages"`
+}
+
+// DeadLetter 多次重试仍失败的推送记录，以 JSONL 格式追加到工作目录
+type DeadLetter struct {
+	URL      string          `json:"url"`
+	Delivery string          `json:"delivery"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Time     time.Time       `json:"time"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+type delivery struct {
+	id   string
+	body []byte
+}
+
+type hook struct {
+	conf   conf.WebhookConfig
+	filter *database.MessageFilter
+	queue  chan delivery
+}
+
+type Service struct {
+	ctx *ctx.Context
+	db  *database.Service
+
+	client      *http.Client
+	hooks       []*hook
+	unsubscribe func()
+	stopCh      chan struct{}
+	wg          sync.WaitGroup
+	deadMutex   sync.Mutex
+}
+
+func NewService(ctx *ctx.Context, db *database.Service) *Service {
+	return &Service{
+		ctx:    ctx,
+		db:     db,
+		client: &http.Client{},
+	}
+}
+
+// Start 加载 Webhook 配置并订阅新消息，未配置时不做任何处理
+func (s *Service) Start() error {
+	if len(s.ctx.Webhooks) == 0 {
+		return nil
+	}
+
+	hooks := make([]*hook, 0, len(s.ctx.Webhooks))
+	for _, c := range s.ctx.Webhooks {
+		if c.URL == "" {
+			continue
+		}
+		// 未经配置文件加载的配置同样使用默认值，显式配置的 0 保持不变
+		config.SetDefault(&c)
+		filter := database.NewMessageFilter(c.Talker, c.Sender)
+		filter.Types = c.Types
+		if c.Keyword != "" {
+			regex, err := regexp.Compile(c.Keyword)
+			if err != nil {
+				return fmt.Errorf("invalid webhook keyword %q: %w", c.Keyword, err)
+			}
+			filter.Keyword = regex
+		}
+		hooks = append(hooks, &hook{
+			conf:   c,
+			filter: filter,
+			queue:  make(chan delivery, QueueCap),
+		})
+	}
+
+	s.hooks = hooks
+	s.stopCh = make(chan struct{})
+	for _, h := range hooks {
+		s.wg.Add(1)
+		go s.worker(h)
+	}
+	s.unsubscribe = s.db.Subscribe(s.handleMessages)
+
+	log.Info().Msgf("webhook service started with %d hooks", len(hooks))
+	return nil
+}
+
+// Stop 取消订阅并等待正在进行的推送结束，未完成的推送会写入死信文件
+func (s *Service) Stop() error {
+	if s.stopCh == nil {
+		return nil
+	}
+	if s.unsubscribe != nil {
+		s.unsubscribe()
+		s.unsubscribe = nil
+	}
+	close(s.stopCh)
+	s.wg.Wait()
+	s.stopCh = nil
+	s.hooks = nil
+	return nil
+}
+
+// handleMessages 为每个 Webhook 筛选匹配的新消息并加入推送队列
+func (s *Service) handleMessages(messages []*model.Message) {
+	for _, h := range s.hooks {
+		matched := make([]*model.Message, 0)
+		for _, msg := range messages {
+			if h.filter.Match(msg) {
+				matched = append(matched, msg)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		body, err := json.Marshal(Payload{
+			Event:    EventNewMessages,
+			Time:     time.Now(),
+			Messages: matched,
+		})
+		if err != nil {
+			log.Err(err).Msg("failed to marshal webhook payload")
+			continue
+		}
+
+		d := delivery{id: uuid.New().String(), body: body}
+		select {
+		case h.queue <- d:
+		default:
+			s.writeDeadLetter(h, d, 0, fmt.Errorf("queue is full"))
+		}
+	}
+}
+
+// worker 按顺序推送单个 Webhook 的队列
+func (s *Service) worker(h *hook) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-s.stopCh:
+			// 服务停止时将队列中剩余的推送写入死信文件
+			for {
+				select {
+				case d := <-h.queue:
+					s.writeDeadLetter(h, d, 0, fmt.Errorf("service stopped"))
+				default:
+					return
+				}
+			}
+		case d := <-h.queue:
+			s.deliver(h, d)
+		}
+	}
+}
+
+// deliver 推送一次消息，失败时按指数退避重试
+func (s *Service) deliver(h *hook, d delivery) {
+	var err error
+	delay := RetryBaseDelay
+	attempts := 0
+	for {
+		attempts++
+		if err = s.post(h, d); err == nil {
+			return
+		}
+		log.Debug().Err(err).Msgf("webhook %s delivery %s attempt %d failed", h.conf.URL, d.id, attempts)
+
+		if attempts > *h.conf.MaxRetries {
+			break
+		}
+		select {
+		case <-time.After(delay):
+		case <-s.stopCh:
+			s.writeDeadLetter(h, d, attempts, fmt.Errorf("service stopped: %w", err))
+			return
+		}
+		delay *= 2
+		if delay > RetryMaxDelay {
+			delay = RetryMaxDelay
+		}
+	}
+
+	s.writeDeadLetter(h, d, attempts, err)
+}
+
+// post 发送请求，非 2xx 状态码视为失败
+func (s *Service) post(h *hook, d delivery) error {
+	req, err := http.NewRequest(http.MethodPost, h.conf.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "chatlog-webhook")
+	req.Header.Set(HeaderEvent, EventNewMessages)
+	req.Header.Set(HeaderDelivery, d.id)
+	if h.conf.Secret != "" {
+		req.Header.Set(HeaderSignature, Sign(h.conf.Secret, d.body))
+	}
+
+	client := *s.client
+	client.Timeout = time.Duration(*h.conf.Timeout) * time.Second
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// writeDeadLetter 将推送失败的内容追加到工作目录的死信文件
+func (s *Service) writeDeadLetter(h *hook, d delivery, attempts int, cause error) {
+	log.Error().Err(cause).Msgf("webhook %s delivery %s failed after %d attempts", h.conf.URL, d.id, attempts)
+
+	b, err := json.Marshal(DeadLetter{
+		URL:      h.conf.URL,
+		Delivery: d.id,
+		Attempts: attempts,
+		Error:    cause.Error(),
+		Time:     time.Now(),
+		Payload:  d.body,
+	})
+	if err != nil {
+		return
+	}
+
+	s.deadMutex.Lock()
+	defer s.deadMutex.Unlock()
+
+	if err := util.PrepareDir(s.ctx.WorkDir); err != nil {
+		log.Err(err).Msg("failed to prepare work dir for dead letters")
+		return
+	}
+	f, err := os.OpenFile(filepath.Join(s.ctx.WorkDir, DeadLetterFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		log.Err(err).Msg("failed to open dead letter file")
+		return
+	}
+	defer f.Close()
+	f.Write(append(b, '\n'))
+}
+
+// Sign 计算请求体的 HMAC-SHA256 签名，格式为 "sha256=<hex>"
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
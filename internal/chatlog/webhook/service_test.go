@@ -0,0 +1,115 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/chatlog/conf"
+	"github.com/sjzar/chatlog/internal/chatlog/ctx"
+	"github.com/sjzar/chatlog/internal/chatlog/database"
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+func TestDeliver(t *testing.T) {
+	RetryBaseDelay = 10 * time.Millisecond
+
+	var calls int32
+	received := make(chan *Payload, 1)
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 第一次请求失败，验证重试
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get(HeaderSignature), Sign("secret", body); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		var p Payload
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("unmarshal payload: %v", err)
+		}
+		received <- &p
+	}))
+	defer stub.Close()
+
+	s := NewService(&ctx.Context{
+		WorkDir: t.TempDir(),
+		Webhooks: []conf.WebhookConfig{
+			{URL: stub.URL, Secret: "secret", Talker: "group@chatroom", Keyword: "hello", MaxRetries: ptr(3), Timeout: ptr(1)},
+		},
+	}, database.NewService(nil))
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	s.handleMessages([]*model.Message{
+		{Seq: 1, Talker: "group@chatroom", Type: 1, Content: "hello world"},
+		{Seq: 2, Talker: "group@chatroom", Type: 1, Content: "bye"},
+		{Seq: 3, Talker: "other", Type: 1, Content: "hello"},
+	})
+
+	select {
+	case p := <-received:
+		if len(p.Messages) != 1 || p.Messages[0].Seq != 1 {
+			t.Errorf("unexpected messages: %+v", p.Messages)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+}
+
+func ptr(v int) *int {
+	return &v
+}
+
+func TestDeadLetter(t *testing.T) {
+	RetryBaseDelay = 10 * time.Millisecond
+
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer stub.Close()
+
+	// 显式配置的 0 表示不重试，不会被默认值覆盖
+	for retries, attempts := range map[int]int{1: 2, 0: 1} {
+		workDir := t.TempDir()
+		s := NewService(&ctx.Context{
+			WorkDir:  workDir,
+			Webhooks: []conf.WebhookConfig{{URL: stub.URL, MaxRetries: ptr(retries), Timeout: ptr(1)}},
+		}, database.NewService(nil))
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+
+		s.handleMessages([]*model.Message{{Seq: 1, Talker: "wxid_a", Type: 1, Content: "hi"}})
+
+		path := filepath.Join(workDir, DeadLetterFile)
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			if b, err := os.ReadFile(path); err == nil && len(b) > 0 {
+				var dl DeadLetter
+				if err := json.Unmarshal(b, &dl); err != nil {
+					t.Fatalf("unmarshal dead letter: %v", err)
+				}
+				if dl.Attempts != attempts || dl.URL != stub.URL {
+					t.Errorf("max_retries %d: unexpected dead letter: %+v", retries, dl)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("dead letter not written")
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		s.Stop()
+	}
+}
@@ -0,0 +1,22 @@
+package wechat
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/sjzar/chatlog/pkg/metrics"
+)
+
+var (
+	decryptRuns     = metrics.NewCounter("chatlog_decrypt_runs_total", "Number of automatic decryptions per database file.", "file")
+	decryptFailures = metrics.NewCounter("chatlog_decrypt_failures_total", "Number of failed automatic decryptions per database file.", "file")
+	decryptDuration = metrics.NewHistogram("chatlog_decrypt_duration_seconds", "Duration of automatic decryptions per database file.", nil, "file")
+)
+
+// metricsFile 返回数据库文件相对于数据目录的路径，用作指标标签
+func (s *Service) metricsFile(dbFile string) string {
+	if rel, err := filepath.Rel(s.ctx.DataDir, dbFile); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return filepath.Base(dbFile)
+}
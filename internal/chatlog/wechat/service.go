@@ -30,6 +30,7 @@ type Service struct {
 	pendingActions map[string]bool
 	mutex          sync.Mutex
 	fm             *filemonitor.FileMonitor
+	callbacks      []func(dbFile string)
 }
 
 func NewService(ctx *ctx.Context) *Service {
@@ -60,6 +61,13 @@ func (s *Service) GetDataKey(info *wechat.Account) (string, error) {
 	return key, nil
 }
 
+// AddDecryptCallback 注册自动解密完成后的回调，参数为已解密的原始数据库文件路径
+func (s *Service) AddDecryptCallback(callback func(dbFile string)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.callbacks = append(s.callbacks, callback)
+}
+
 func (s *Service) StartAutoDecrypt() error {
 	dbGroup, err := filemonitor.NewFileGroup("wechat", s.ctx.DataDir, `.*\.db$`, []string{"fts"})
 	if err != nil {
@@ -120,14 +128,31 @@ func (s *Service) waitAndProcess(dbFile string) {
 			s.mutex.Unlock()
 
 			log.Debug().Msgf("Processing file: %s", dbFile)
-			s.DecryptDBFile(dbFile)
+			file := s.metricsFile(dbFile)
+			decryptStart := time.Now()
+			err := s.DecryptDBFile(dbFile)
+			decryptRuns.With(file).Inc()
+			decryptDuration.With(file).Observe(time.Since(decryptStart).Seconds())
+			if err != nil {
+				decryptFailures.With(file).Inc()
+				return
+			}
+
+			s.mutex.Lock()
+			callbacks := make([]func(dbFile string), len(s.callbacks))
+			copy(callbacks, s.callbacks)
+			s.mutex.Unlock()
+			for _, callback := range callbacks {
+				callback(dbFile)
+			}
 			return
 		}
 		s.mutex.Unlock()
 	}
 }
 
-func (s *Service) DecryptDBFile(dbFile string) error {
+func (s *Service) DecryptDBFile(dbFile string) (err error) {
+	defer func() { s.ctx.RecordDecrypt(s.metricsFile(dbFile), err) }()
 
 	decryptor, err := decrypt.NewDecryptor(s.ctx.Platform, s.ctx.Version)
 	if err != nil {
@@ -9,3 +9,27 @@ func InvalidArg(arg string) error {
 func HTTPShutDown(cause error) error {
 	return Newf(cause, http.StatusInternalServerError, "http server shut down")
 }
+
+func ExportJobNotFound(id string) error {
+	return Newf(nil, http.StatusNotFound, "export job not found: %s", id)
+}
+
+func ExportJobNotReady(id string, status string) error {
+	return Newf(nil, http.StatusConflict, "export job %s is %s", id, status)
+}
+
+func ServiceNotReady() error {
+	return Newf(nil, http.StatusServiceUnavailable, "service not ready: database is not loaded")
+}
+
+func Unauthorized() error {
+	return Newf(nil, http.StatusUnauthorized, "invalid access token")
+}
+
+func AccessDenied(target string) error {
+	return Newf(nil, http.StatusForbidden, "access denied: %s", target)
+}
+
+func InvalidOrigin(origin string) error {
+	return Newf(nil, http.StatusForbidden, "invalid origin: %s", origin)
+}
@@ -63,3 +63,7 @@ func WeChatAccountNotOnline(name string) *Error {
 func RefreshProcessStatusFailed(cause error) *Error {
 	return New(cause, http.StatusInternalServerError, "failed to refresh process status").WithStack()
 }
+
+func AccountNotFound(name string) *Error {
+	return Newf(nil, http.StatusNotFound, "account not found or not decrypted: %s", name).WithStack()
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/sjzar/chatlog/pkg/util/dat2img"
+	"github.com/sjzar/chatlog/pkg/util/thumbnail"
 	"io"
 	"os"
 	"path/filepath"
@@ -407,7 +408,7 @@ func GetGroupMediaFiles(db interface {
 	case TypeVideo:
 		msgType = TypeVideo // 视频消息类型
 	default:
-		return nil, fmt.Errorf("unsupported media type: %s", mediaType)
+		return nil, fmt.Errorf("unsupported media type: %d", mediaType)
 	}
 
 	// 设置时间范围
@@ -483,6 +484,15 @@ func GetGroupMediaFiles(db interface {
 
 // MediaFilesExport 将媒体文件导出到指定目录
 func MediaFilesExport(mediaFiles []*MsgMediaExport, dataDir, outputDir, mediaType string, progress ProgressCallback) error {
+	return mediaFilesExport(mediaFiles, dataDir, outputDir, nil, progress)
+}
+
+// MediaFilesExportResized 将媒体文件导出到指定目录，图片按 resize 缩小后保存
+func MediaFilesExportResized(mediaFiles []*MsgMediaExport, dataDir, outputDir string, resize thumbnail.Options, progress ProgressCallback) error {
+	return mediaFilesExport(mediaFiles, dataDir, outputDir, &resize, progress)
+}
+
+func mediaFilesExport(mediaFiles []*MsgMediaExport, dataDir, outputDir string, resize *thumbnail.Options, progress ProgressCallback) error {
 	// 创建输出目录
 	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
 		return fmt.Errorf("创建输出目录失败: %w", err)
@@ -530,6 +540,12 @@ func MediaFilesExport(mediaFiles []*MsgMediaExport, dataDir, outputDir, mediaTyp
 			if err != nil {
 				return fmt.Errorf("无法转换文件: %s", absolutePath)
 			}
+			if resize != nil && (dat2Ext == "jpg" || dat2Ext == "png" || dat2Ext == "gif") {
+				// 缩放失败时保留原图
+				if out, ext, err := thumbnail.Make(dat2Out, *resize); err == nil {
+					dat2Out, dat2Ext = out, ext
+				}
+			}
 
 			// 构建目标路径
 			dstPath := filepath.Join(monthDir, fmt.Sprintf("%s.%s", mediaFile.Media.Key, dat2Ext))
@@ -538,6 +554,18 @@ func MediaFilesExport(mediaFiles []*MsgMediaExport, dataDir, outputDir, mediaTyp
 			if err = os.WriteFile(dstPath, dat2Out, os.ModePerm); err != nil {
 				return fmt.Errorf("无法保存文件 %s: %w", dstPath, err)
 			}
+		case resize != nil && (ext == ".jpg" || ext == ".jpeg" || ext == ".png" || ext == ".gif"):
+			b, err := os.ReadFile(absolutePath)
+			if err != nil {
+				return fmt.Errorf("无法读取文件: %s", absolutePath)
+			}
+			if out, outExt, err := thumbnail.Make(b, *resize); err == nil {
+				b, ext = out, "."+outExt
+			}
+			dstPath := filepath.Join(monthDir, fmt.Sprintf("%s%s", mediaFile.Media.Key, ext))
+			if err = os.WriteFile(dstPath, b, os.ModePerm); err != nil {
+				return fmt.Errorf("无法保存文件 %s: %w", dstPath, err)
+			}
 		default:
 			// 原始文件直接复制
 			srcPath := absolutePath
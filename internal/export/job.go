@@ -0,0 +1,559 @@
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+
+	"github.com/sjzar/chatlog/internal/errors"
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+	"github.com/sjzar/chatlog/pkg/audit"
+	"github.com/sjzar/chatlog/pkg/util"
+	"github.com/sjzar/chatlog/pkg/util/thumbnail"
+)
+
+var (
+	// JobTTL 已结束的导出任务及其压缩包的保留时间
+	JobTTL = 24 * time.Hour
+	// MaxRunningJobs 同时运行的导出任务数量
+	MaxRunningJobs = 2
+)
+
+// JobStatus 导出任务状态
+type JobStatus string
+
+const (
+	JobPending  JobStatus = "pending"
+	JobRunning  JobStatus = "running"
+	JobDone     JobStatus = "done"
+	JobFailed   JobStatus = "failed"
+	JobCanceled JobStatus = "canceled"
+)
+
+// 导出任务的执行阶段
+const (
+	StageMessages = "messages" // 读取聊天记录，进度为聊天对象数量
+	StageWrite    = "write"    // 写入聊天记录文件，进度为消息数量
+	StageMedia    = "media"    // 导出媒体文件，进度为文件数量
+	StageArchive  = "archive"  // 打包，进度为文件数量
+)
+
+// JobOptions 导出任务参数
+type JobOptions struct {
+	Format   string   `json:"format"`   // json 或 csv
+	Time     string   `json:"time"`     // 时间范围，格式同 util.TimeRangeOf，默认为 all
+	Talkers  []string `json:"talkers"`  // 聊天对象，为空时导出所有联系人
+	Media    bool     `json:"media"`    // 是否同时导出图片与视频
+	OnlySelf bool     `json:"onlySelf"` // 只导出自己发送的消息
+
+	// MaxImageSize 导出图片的最大宽高，超出时按比例缩小，0 表示保留原图
+	MaxImageSize int `json:"maxImageSize,omitempty"`
+
+	// 审计日志中记录的请求方与账号，不对外返回
+	Caller  string `json:"-"`
+	Account string `json:"-"`
+}
+
+// AuditParams 返回审计日志中记录的导出参数
+func (o JobOptions) AuditParams() map[string]string {
+	params := map[string]string{
+		"format": o.Format,
+		"time":   o.Time,
+	}
+	if len(o.Talkers) > 0 {
+		params["talkers"] = strings.Join(o.Talkers, ",")
+	}
+	if o.Media {
+		params["media"] = "true"
+	}
+	if o.OnlySelf {
+		params["onlySelf"] = "true"
+	}
+	return params
+}
+
+// Job 导出任务
+type Job struct {
+	ID         string     `json:"id"`
+	Status     JobStatus  `json:"status"`
+	Options    JobOptions `json:"options"`
+	Stage      string     `json:"stage,omitempty"`
+	Current    int        `json:"current"`
+	Total      int        `json:"total"`
+	Messages   int        `json:"messages"`
+	MediaFiles int        `json:"mediaFiles"`
+	Size       int64      `json:"size,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+}
+
+// Finished 任务是否已结束
+func (j *Job) Finished() bool {
+	return j.Status == JobDone || j.Status == JobFailed || j.Status == JobCanceled
+}
+
+// JobDB 导出任务依赖的数据接口
+type JobDB interface {
+	GetMessages(startTime, endTime time.Time, talker, sender, content string, limit, offset int) ([]*model.Message, error)
+	GetContacts(keyword string, limit, offset int) (*wechatdb.GetContactsResp, error)
+	GetChatRooms(keyword string, limit, offset int) (*wechatdb.GetChatRoomsResp, error)
+	GetMedia(_type string, key string) (*model.Media, error)
+}
+
+type jobState struct {
+	job     Job
+	dir     string // 临时目录
+	archive string // 压缩包路径
+	dataDir string
+	cancel  context.CancelFunc
+	talkers []string // 导出了消息的聊天对象
+}
+
+// JobManager 管理后台导出任务
+type JobManager struct {
+	db   JobDB
+	mu   sync.Mutex
+	jobs map[string]*jobState
+	sem  chan struct{}
+}
+
+func NewJobManager(db JobDB) *JobManager {
+	return &JobManager{
+		db:   db,
+		jobs: make(map[string]*jobState),
+		sem:  make(chan struct{}, MaxRunningJobs),
+	}
+}
+
+// Create 创建并在后台运行导出任务，压缩包写入 outputDir，媒体文件从 dataDir 读取
+func (m *JobManager) Create(opts JobOptions, outputDir, dataDir string) (*Job, error) {
+	opts.Format = strings.ToLower(opts.Format)
+	if opts.Format == "" {
+		opts.Format = "json"
+	}
+	if opts.Format != "json" && opts.Format != "csv" {
+		return nil, errors.InvalidArg("format")
+	}
+	if opts.Time == "" {
+		opts.Time = "all"
+	}
+	start, end, ok := util.TimeRangeOf(opts.Time)
+	if !ok {
+		return nil, errors.InvalidArg("time")
+	}
+	if opts.Media && dataDir == "" {
+		return nil, errors.InvalidArg("media")
+	}
+	if opts.MaxImageSize < 0 || opts.MaxImageSize > thumbnail.MaxSize {
+		return nil, errors.InvalidArg("maxImageSize")
+	}
+	if err := util.PrepareDir(outputDir); err != nil {
+		return nil, err
+	}
+
+	m.Cleanup()
+	m.cleanupDir(outputDir)
+
+	id := uuid.New().String()
+	ctx, cancel := context.WithCancel(context.Background())
+	st := &jobState{
+		job: Job{
+			ID:        id,
+			Status:    JobPending,
+			Options:   opts,
+			CreatedAt: time.Now(),
+		},
+		dir:     filepath.Join(outputDir, id),
+		archive: filepath.Join(outputDir, id+".zip"),
+		dataDir: dataDir,
+		cancel:  cancel,
+	}
+
+	m.mu.Lock()
+	m.jobs[id] = st
+	job := st.job
+	m.mu.Unlock()
+
+	go m.run(ctx, st, start, end)
+
+	return &job, nil
+}
+
+// Get 返回任务当前状态
+func (m *JobManager) Get(id string) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.jobs[id]
+	if !ok {
+		return nil, errors.ExportJobNotFound(id)
+	}
+	job := st.job
+	return &job, nil
+}
+
+// List 返回所有任务，按创建时间倒序
+func (m *JobManager) List() []*Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]*Job, 0, len(m.jobs))
+	for _, st := range m.jobs {
+		job := st.job
+		list = append(list, &job)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// Archive 返回已完成任务的压缩包路径
+func (m *JobManager) Archive(id string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.jobs[id]
+	if !ok {
+		return "", errors.ExportJobNotFound(id)
+	}
+	if st.job.Status != JobDone {
+		return "", errors.ExportJobNotReady(id, string(st.job.Status))
+	}
+	return st.archive, nil
+}
+
+// Cancel 取消运行中的任务；已结束的任务会被删除，同时删除压缩包
+func (m *JobManager) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	st, ok := m.jobs[id]
+	if !ok {
+		m.mu.Unlock()
+		return nil, errors.ExportJobNotFound(id)
+	}
+	finished := st.job.Finished()
+	if finished {
+		delete(m.jobs, id)
+	}
+	job := st.job
+	m.mu.Unlock()
+
+	st.cancel()
+	if finished {
+		os.Remove(st.archive)
+	}
+	return &job, nil
+}
+
+// Cleanup 删除超过 JobTTL 的已结束任务及其压缩包
+func (m *JobManager) Cleanup() {
+	m.mu.Lock()
+	expired := make([]*jobState, 0)
+	for id, st := range m.jobs {
+		if st.job.FinishedAt != nil && time.Since(*st.job.FinishedAt) > JobTTL {
+			expired = append(expired, st)
+			delete(m.jobs, id)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, st := range expired {
+		os.Remove(st.archive)
+	}
+}
+
+// cleanupDir 删除输出目录中不属于任何任务且超过 JobTTL 的文件，例如上次运行遗留的压缩包
+func (m *JobManager) cleanupDir(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".zip")
+		if _, ok := m.jobs[id]; ok {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || time.Since(info.ModTime()) <= JobTTL {
+			continue
+		}
+		os.RemoveAll(filepath.Join(dir, entry.Name()))
+	}
+}
+
+// Close 取消所有运行中的任务
+func (m *JobManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, st := range m.jobs {
+		st.cancel()
+	}
+}
+
+func (m *JobManager) update(st *jobState, fn func(job *Job)) {
+	m.mu.Lock()
+	fn(&st.job)
+	m.mu.Unlock()
+}
+
+func (m *JobManager) progress(st *jobState, stage string) ProgressCallback {
+	return func(current, total int) {
+		m.update(st, func(job *Job) {
+			job.Stage, job.Current, job.Total = stage, current, total
+		})
+	}
+}
+
+func (m *JobManager) run(ctx context.Context, st *jobState, start, end time.Time) {
+	defer os.RemoveAll(st.dir)
+
+	select {
+	case m.sem <- struct{}{}:
+		defer func() { <-m.sem }()
+	case <-ctx.Done():
+		m.finish(st, ctx.Err())
+		return
+	}
+
+	m.update(st, func(job *Job) { job.Status = JobRunning })
+	err := m.export(ctx, st, start, end)
+	if err != nil {
+		os.Remove(st.archive)
+	}
+	m.finish(st, err)
+}
+
+func (m *JobManager) finish(st *jobState, err error) {
+	now := time.Now()
+	m.update(st, func(job *Job) {
+		job.FinishedAt = &now
+		switch {
+		case err == nil:
+			job.Status = JobDone
+		case err == context.Canceled:
+			job.Status = JobCanceled
+		default:
+			job.Status = JobFailed
+			job.Error = err.Error()
+		}
+	})
+	if err != nil && err != context.Canceled {
+		log.Err(err).Msgf("export job %s failed", st.job.ID)
+	}
+
+	m.mu.Lock()
+	job := st.job
+	m.mu.Unlock()
+	params := job.Options.AuditParams()
+	params["id"] = job.ID
+	e := audit.Entry{
+		Source:  "export",
+		Caller:  job.Options.Caller,
+		Account: job.Options.Account,
+		Action:  "job",
+		Params:  params,
+		Talkers: st.talkers,
+		Count:   job.Messages,
+		Error:   job.Error,
+	}
+	if job.Status == JobCanceled {
+		e.Error = string(JobCanceled)
+	}
+	if err := audit.Record(e); err != nil {
+		log.Debug().Err(err).Msg("failed to record audit log")
+	}
+}
+
+func (m *JobManager) export(ctx context.Context, st *jobState, start, end time.Time) error {
+	opts := st.job.Options
+
+	talkers := opts.Talkers
+	if len(talkers) == 0 {
+		// 未指定聊天对象时导出所有联系人与群聊，群聊不一定出现在联系人中
+		contacts, err := m.db.GetContacts("", 0, 0)
+		if err != nil {
+			return err
+		}
+		chatRooms, err := m.db.GetChatRooms("", 0, 0)
+		if err != nil {
+			return err
+		}
+		seen := make(map[string]bool)
+		add := func(name string) {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				talkers = append(talkers, name)
+			}
+		}
+		for _, contact := range contacts.Items {
+			add(contact.UserName)
+		}
+		for _, chatRoom := range chatRooms.Items {
+			add(chatRoom.Name)
+		}
+	}
+
+	// 逐个聊天对象读取，便于报告进度与及时取消
+	progress := m.progress(st, StageMessages)
+	messages := make([]*model.Message, 0)
+	for i, talker := range talkers {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		msgs, err := GetMessagesForExport(m.db, start, end, talker, opts.OnlySelf, nil)
+		if err != nil {
+			log.Debug().Err(err).Msgf("export job %s: failed to get messages of %s", st.job.ID, talker)
+		}
+		if len(msgs) > 0 {
+			st.talkers = append(st.talkers, talker)
+		}
+		messages = append(messages, msgs...)
+		progress(i+1, len(talkers))
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("no messages found")
+	}
+	m.update(st, func(job *Job) { job.Messages = len(messages) })
+
+	if err := os.MkdirAll(st.dir, 0755); err != nil {
+		return err
+	}
+	if err := ExportMessages(messages, filepath.Join(st.dir, "messages."+opts.Format), opts.Format, m.progress(st, StageWrite)); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if opts.Media {
+		mediaFiles := m.mediaFiles(messages, st.dataDir)
+		if len(mediaFiles) > 0 {
+			dir := filepath.Join(st.dir, "media")
+			var err error
+			if opts.MaxImageSize > 0 {
+				resize := thumbnail.Options{Width: opts.MaxImageSize, Height: opts.MaxImageSize, Fit: thumbnail.FitContain}
+				err = MediaFilesExportResized(mediaFiles, st.dataDir, dir, resize, m.progress(st, StageMedia))
+			} else {
+				err = MediaFilesExport(mediaFiles, st.dataDir, dir, "", m.progress(st, StageMedia))
+			}
+			if err != nil {
+				return err
+			}
+		}
+		m.update(st, func(job *Job) { job.MediaFiles = len(mediaFiles) })
+	}
+
+	size, err := zipDir(ctx, st.dir, st.archive, m.progress(st, StageArchive))
+	if err != nil {
+		return err
+	}
+	m.update(st, func(job *Job) { job.Size = size })
+	return nil
+}
+
+// mediaFiles 查找消息中的图片与视频，跳过数据目录中不存在的文件
+func (m *JobManager) mediaFiles(messages []*model.Message, dataDir string) []*MsgMediaExport {
+	candidates := make([]*model.Message, 0)
+	for _, msg := range messages {
+		if msg.Type != TypeImage && msg.Type != TypeVideo {
+			continue
+		}
+		if md5, ok := msg.Contents["md5"].(string); ok && md5 != "" {
+			candidates = append(candidates, msg)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// 按每条消息自身的类型查询图片表或视频表，查不到的媒体跳过
+	mediaFiles := make([]*MsgMediaExport, 0, len(candidates))
+	for _, msg := range candidates {
+		files, err := GetMessageMedia(m.db, msg.Type, msg)
+		if err != nil {
+			continue
+		}
+		mediaFiles = append(mediaFiles, files...)
+	}
+	ret := make([]*MsgMediaExport, 0, len(mediaFiles))
+	for _, f := range mediaFiles {
+		if _, err := os.Stat(filepath.Join(dataDir, f.Media.Path)); err == nil {
+			ret = append(ret, f)
+		}
+	}
+	return ret
+}
+
+// zipDir 将目录打包为 zip 文件，返回压缩包大小
+func zipDir(ctx context.Context, dir, target string, progress ProgressCallback) (int64, error) {
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	f, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for i, path := range files {
+		if err := ctx.Err(); err != nil {
+			zw.Close()
+			return 0, err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return 0, err
+		}
+		if err := zipFile(zw, path, filepath.ToSlash(rel)); err != nil {
+			zw.Close()
+			return 0, err
+		}
+		progress(i+1, len(files))
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func zipFile(zw *zip.Writer, path, name string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, src)
+	return err
+}
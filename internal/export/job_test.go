@@ -0,0 +1,178 @@
+package export
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/sjzar/chatlog/internal/model"
+	"github.com/sjzar/chatlog/internal/wechatdb"
+)
+
+type fakeDB struct {
+	delay time.Duration
+
+	// messages 为空时每个聊天对象返回一条文本消息
+	messages []*model.Message
+	// media 按 "类型/MD5" 查询的媒体
+	media map[string]*model.Media
+}
+
+func (f *fakeDB) GetMessages(startTime, endTime time.Time, talker, sender, content string, limit, offset int) ([]*model.Message, error) {
+	time.Sleep(f.delay)
+	if f.messages != nil {
+		ret := make([]*model.Message, 0)
+		for _, m := range f.messages {
+			if m.Talker == talker {
+				ret = append(ret, m)
+			}
+		}
+		return ret, nil
+	}
+	return []*model.Message{
+		{Seq: 1, Time: time.Now(), Talker: talker, Sender: talker, Type: 1, Content: "hello"},
+	}, nil
+}
+
+func (f *fakeDB) GetContacts(keyword string, limit, offset int) (*wechatdb.GetContactsResp, error) {
+	return &wechatdb.GetContactsResp{Items: []*model.Contact{{UserName: "wxid_a"}, {UserName: "wxid_b"}, {UserName: "room@chatroom"}}}, nil
+}
+
+func (f *fakeDB) GetChatRooms(keyword string, limit, offset int) (*wechatdb.GetChatRoomsResp, error) {
+	return &wechatdb.GetChatRoomsResp{Items: []*model.ChatRoom{{Name: "room@chatroom"}, {Name: "other@chatroom"}}}, nil
+}
+
+func (f *fakeDB) GetMedia(_type string, key string) (*model.Media, error) {
+	if media, ok := f.media[_type+"/"+key]; ok {
+		return media, nil
+	}
+	return nil, fmt.Errorf("media not found: %s/%s", _type, key)
+}
+
+func waitJob(t *testing.T, m *JobManager, id string) *Job {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		job, err := m.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if job.Finished() {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("job not finished")
+	return nil
+}
+
+func TestJobManager(t *testing.T) {
+	m := NewJobManager(&fakeDB{})
+	job, err := m.Create(JobOptions{Format: "csv"}, t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 联系人与群聊各一条消息，同时出现在两者中的群聊只导出一次
+	job = waitJob(t, m, job.ID)
+	if job.Status != JobDone || job.Messages != 4 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	path, err := m.Archive(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != 1 || r.File[0].Name != "messages.csv" {
+		t.Errorf("unexpected archive content: %v", r.File)
+	}
+
+	if _, err := m.Create(JobOptions{Format: "xml"}, t.TempDir(), ""); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestJobManagerCancel(t *testing.T) {
+	m := NewJobManager(&fakeDB{delay: 200 * time.Millisecond})
+	job, err := m.Create(JobOptions{}, t.TempDir(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	job = waitJob(t, m, job.ID)
+	if job.Status != JobCanceled {
+		t.Fatalf("unexpected status: %s", job.Status)
+	}
+	if _, err := m.Archive(job.ID); err == nil {
+		t.Error("canceled job should not have an archive")
+	}
+
+	// 再次删除已结束的任务
+	if _, err := m.Cancel(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Get(job.ID); err == nil {
+		t.Error("job should be removed")
+	}
+}
+
+func TestJobManagerMedia(t *testing.T) {
+	dataDir := t.TempDir()
+	for _, name := range []string{"image.jpg", "video.mp4"} {
+		if err := os.WriteFile(filepath.Join(dataDir, name), []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgTime := time.Date(2025, 4, 1, 12, 0, 0, 0, time.Local)
+	db := &fakeDB{
+		messages: []*model.Message{
+			{Seq: 1, Time: msgTime, Talker: "wxid_a", Type: TypeImage, Contents: map[string]interface{}{"md5": "img"}},
+			{Seq: 2, Time: msgTime, Talker: "wxid_a", Type: TypeVideo, Contents: map[string]interface{}{"md5": "vid"}},
+		},
+		// 视频只能从视频表中查到
+		media: map[string]*model.Media{
+			"image/img": {Type: "image", Key: "img", Path: "image.jpg"},
+			"video/vid": {Type: "video", Key: "vid", Path: "video.mp4"},
+		},
+	}
+	m := NewJobManager(db)
+	job, err := m.Create(JobOptions{Talkers: []string{"wxid_a"}, Media: true}, t.TempDir(), dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job = waitJob(t, m, job.ID)
+	if job.Status != JobDone || job.MediaFiles != 2 {
+		t.Fatalf("unexpected job: %+v", job)
+	}
+
+	path, err := m.Archive(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var exts []string
+	for _, f := range r.File {
+		if ext := filepath.Ext(f.Name); ext != ".json" {
+			exts = append(exts, ext)
+		}
+	}
+	sort.Strings(exts)
+	if fmt.Sprint(exts) != "[.jpg .mp4]" {
+		t.Errorf("unexpected media files: %v", exts)
+	}
+}
@@ -0,0 +1,71 @@
+package mcp
+
+// Document: https://modelcontextprotocol.io/specification/2025-06-18/server/utilities/completion
+
+const (
+	// Client => Server
+	MethodCompletionComplete = "completion/complete"
+
+	RefTypePrompt   = "ref/prompt"
+	RefTypeResource = "ref/resource"
+
+	// RefTypeTool 工具参数补全，不在协议规范中，供支持的客户端使用
+	RefTypeTool = "ref/tool"
+
+	// CompletionMaxValues 单次补全最多返回的候选值数量
+	CompletionMaxValues = 100
+)
+
+// CompleteRequest
+//
+//	{
+//		ref: {
+//			type: "ref/prompt" | "ref/resource",
+//			name?: string,  // ref/prompt
+//			uri?: string    // ref/resource
+//		},
+//		argument: {
+//			name: string,
+//			value: string
+//		},
+//		context?: {
+//			arguments?: { [key: string]: string }
+//		}
+//	}
+type CompleteRequest struct {
+	Ref      CompleteRef      `json:"ref"`
+	Argument CompleteArgument `json:"argument"`
+	Context  struct {
+		Arguments map[string]string `json:"arguments,omitempty"`
+	} `json:"context,omitempty"`
+}
+
+type CompleteRef struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+	URI  string `json:"uri,omitempty"`
+}
+
+type CompleteArgument struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// CompleteResponse
+//
+//	{
+//		completion: {
+//			values: string[],  // 最多 100 个
+//			total?: number,
+//			hasMore?: boolean
+//		}
+//	}
+type CompleteResponse struct {
+	Completion CompleteResult `json:"completion"`
+}
+
+type CompleteResult struct {
+	Values  []string `json:"values"`
+	Total   int      `json:"total,omitempty"`
+	HasMore bool     `json:"hasMore,omitempty"`
+}
@@ -26,10 +26,12 @@ var (
 	ErrMethodNotFound = &Error{Code: -32601, Message: "Method not found"}
 	ErrInvalidParams  = &Error{Code: -32602, Message: "Invalid params"}
 	ErrInternalError  = &Error{Code: -32603, Message: "Internal error"}
+	ErrServerBusy     = &Error{Code: -32000, Message: "Server busy"}
 
 	ErrInvalidSessionID = &Error{Code: 400, Message: "Invalid session ID"}
 	ErrSessionNotFound  = &Error{Code: 404, Message: "Could not find session"}
 	ErrTooManyRequests  = &Error{Code: 429, Message: "Too many requests"}
+	ErrInvalidOrigin    = &Error{Code: 403, Message: "Invalid origin"}
 )
 
 func (e *Error) Error() string {
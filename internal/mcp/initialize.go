@@ -3,9 +3,22 @@ package mcp
 const (
 	MethodInitialize = "initialize"
 	MethodPing       = "ping"
-	ProtocolVersion  = "2024-11-05"
+	ProtocolVersion  = "2025-06-18"
 )
 
+// SupportedProtocolVersions 支持的协议版本，新版本在前
+var SupportedProtocolVersions = []string{ProtocolVersion, "2025-03-26", "2024-11-05"}
+
+// NegotiateProtocolVersion 客户端请求的版本受支持时使用该版本，否则返回最新版本
+func NegotiateProtocolVersion(version string) string {
+	for _, v := range SupportedProtocolVersions {
+		if v == version {
+			return v
+		}
+	}
+	return ProtocolVersion
+}
+
 //	{
 //		"method": "initialize",
 //		"params": {
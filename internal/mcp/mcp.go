@@ -1,7 +1,6 @@
 package mcp
 
 import (
-	"io"
 	"net/http"
 	"sync"
 
@@ -12,10 +11,14 @@ import (
 
 const (
 	ProcessChanCap = 1000
+
+	// MaxMessageSize 单条 JSON-RPC 消息（或批量消息）的最大长度
+	MaxMessageSize = 16 * 1024 * 1024
 )
 
 type MCP struct {
 	sessions  map[string]*Session
+	streams   map[string]*streamableSession
 	sessionMu sync.Mutex
 
 	ProcessChan chan ProcessCtx
@@ -24,24 +27,33 @@ type MCP struct {
 func NewMCP() *MCP {
 	return &MCP{
 		sessions:    make(map[string]*Session),
+		streams:     make(map[string]*streamableSession),
 		ProcessChan: make(chan ProcessCtx, ProcessChanCap),
 	}
 }
 
 func (m *MCP) HandleSSE(c *gin.Context) {
+	if !ValidOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, ErrInvalidOrigin.JsonRPC())
+		return
+	}
 	id := uuid.New().String()
+	session := NewSession(c, id)
 	m.sessionMu.Lock()
-	m.sessions[id] = NewSession(c, id)
+	m.sessions[id] = session
 	m.sessionMu.Unlock()
 
-	c.Stream(func(w io.Writer) bool {
-		<-c.Request.Context().Done()
-		return false
-	})
+	// 消息由 SSEWriter 加锁写入，这里只等待连接断开
+	<-c.Request.Context().Done()
 
 	m.sessionMu.Lock()
 	delete(m.sessions, id)
 	m.sessionMu.Unlock()
+	session.Close()
+	// handler 返回后不能再写入 Context，处理中的请求的响应与通知直接丢弃
+	if w, ok := session.w.(*SSEWriter); ok {
+		w.Close()
+	}
 }
 
 func (m *MCP) GetSession(id string) *Session {
@@ -58,6 +70,12 @@ func (m *MCP) HandleMessages(c *gin.Context) {
 	// 官方 SDK 是 session_id: https://github.com/modelcontextprotocol/python-sdk/blob/c897868/src/mcp/server/sse.py#L98
 	// 写的是 sessionId: https://github.com/modelcontextprotocol/inspector/blob/aeaf32f/server/src/index.ts#L157
 
+	if !ValidOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, ErrInvalidOrigin.JsonRPC())
+		c.Abort()
+		return
+	}
+
 	sessionID := c.Query("session_id")
 	if sessionID == "" {
 		sessionID = c.Query("sessionId")
@@ -72,7 +90,7 @@ func (m *MCP) HandleMessages(c *gin.Context) {
 	}
 
 	session := m.GetSession(sessionID)
-	if session == nil {
+	if session == nil || !session.authorized(c) {
 		c.JSON(http.StatusNotFound, ErrSessionNotFound.JsonRPC())
 		c.Abort()
 		return
@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Document: https://modelcontextprotocol.io/specification/2025-06-18/basic/utilities/cancellation
+// Document: https://modelcontextprotocol.io/specification/2025-06-18/basic/utilities/progress
+
+const (
+	NotificationCancelled = "notifications/cancelled"
+	NotificationProgress  = "notifications/progress"
+)
+
+// CancelledNotification 客户端取消正在处理的请求
+//
+//	{
+//		requestId: string | number,
+//		reason?: string
+//	}
+type CancelledNotification struct {
+	RequestID interface{} `json:"requestId"`
+	Reason    string      `json:"reason,omitempty"`
+}
+
+// requestCanceller 由等待请求响应的 writer 实现，例如 Streamable HTTP 的 POST 连接
+// 被取消的请求不会返回响应，writer 需要据此停止等待
+type requestCanceller interface {
+	CancelRequest(id interface{})
+}
+
+// Cancelled 标记请求已被客户端取消、不会返回响应
+func (s *Session) Cancelled(req *Request) {
+	if c, ok := s.w.(requestCanceller); ok {
+		c.CancelRequest(req.ID)
+	}
+}
+
+// ProgressNotification 长时间请求的处理进度，progressToken 来自请求的 _meta
+//
+//	{
+//		progressToken: string | number,
+//		progress: number,
+//		total?: number,
+//		message?: string
+//	}
+type ProgressNotification struct {
+	ProgressToken interface{} `json:"progressToken"`
+	Progress      float64     `json:"progress"`
+	Total         float64     `json:"total,omitempty"`
+	Message       string      `json:"message,omitempty"`
+}
+
+// RequestKey 返回请求在会话内的唯一标识，数字 ID 与字符串 ID 不会混淆，用于匹配取消通知
+func RequestKey(id interface{}) string {
+	b, err := json.Marshal(id)
+	if err != nil {
+		return fmt.Sprint(id)
+	}
+	return string(b)
+}
+
+// ProgressToken 返回请求 _meta 中的 progressToken，客户端未请求进度通知时为 nil
+func (r *Request) ProgressToken() interface{} {
+	params, ok := r.Params.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	meta, ok := params["_meta"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return meta["progressToken"]
+}
+
+// Progress 发送进度通知，与响应写入同一连接，Streamable HTTP 以 SSE 返回时客户端可以在响应之前收到
+func (s *Session) Progress(token interface{}, progress, total float64, message string) error {
+	b, err := json.Marshal(Notification{
+		JsonRPC: JsonRPCVersion,
+		Method:  NotificationProgress,
+		Params: ProgressNotification{
+			ProgressToken: token,
+			Progress:      progress,
+			Total:         total,
+			Message:       message,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.Write(b)
+	return err
+}
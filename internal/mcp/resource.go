@@ -66,6 +66,18 @@ type ResourcesReadRequest struct {
 	URI string `json:"uri"`
 }
 
+// Subscribe
+//
+//	{
+//		method: "resources/subscribe",
+//		params: {
+//			uri: string
+//		}
+//	}
+type ResourcesSubscribeRequest struct {
+	URI string `json:"uri"`
+}
+
 type ReadingResourceContent struct {
 	URI      string `json:"uri"`
 	MimeType string `json:"mimeType,omitempty"`
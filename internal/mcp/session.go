@@ -3,20 +3,78 @@ package mcp
 import (
 	"encoding/json"
 	"io"
+	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
 type Session struct {
-	id string
-	w  io.Writer
+	id   string
+	w    io.Writer
+	info *sessionInfo
+}
+
+// sessionInfo 会话状态，同一会话的所有请求共享
+type sessionInfo struct {
+	mu sync.RWMutex
 	c  *ClientInfo
+
+	// notify 会话本身的连接，用于发送服务端主动推送的通知
+	notify io.Writer
+
+	// auth 创建会话的 HTTP 请求或 stdio 启动参数中的认证信息，会话内的所有请求都必须使用相同的认证信息
+	auth interface{}
+
+	done chan struct{}
+	once sync.Once
 }
 
-func NewSession(c *gin.Context, id string) *Session {
+func newSession(id string, w io.Writer) *Session {
 	return &Session{
 		id: id,
-		w:  NewSSEWriter(c, id),
+		w:  w,
+		info: &sessionInfo{
+			notify: w,
+			done:   make(chan struct{}),
+		},
+	}
+}
+
+func NewSession(c *gin.Context, id string) *Session {
+	s := newSession(id, NewSSEWriter(c, id))
+	s.info.auth = authOf(c)
+	return s
+}
+
+// AuthContextKey HTTP 认证中间件保存认证信息（如访问控制规则）的键，创建会话时保存到会话中
+const AuthContextKey = "mcp.auth"
+
+func authOf(c *gin.Context) interface{} {
+	auth, _ := c.Get(AuthContextKey)
+	return auth
+}
+
+// Auth 返回创建会话时的认证信息，未经认证时为 nil
+func (s *Session) Auth() interface{} {
+	return s.info.auth
+}
+
+// authorized 判断后续请求的认证信息是否与创建会话时一致，避免使用其他令牌访问已有的会话
+func (s *Session) authorized(c *gin.Context) bool {
+	return authOf(c) == s.info.auth
+}
+
+// ID 返回会话 ID
+func (s *Session) ID() string {
+	return s.id
+}
+
+// WithWriter 返回共享会话状态、但将消息写入 w 的会话，用于把响应写回发起请求的连接
+func (s *Session) WithWriter(w io.Writer) *Session {
+	return &Session{
+		id:   s.id,
+		w:    w,
+		info: s.info,
 	}
 }
 
@@ -24,6 +82,36 @@ func (s *Session) Write(p []byte) (n int, err error) {
 	return s.w.Write(p)
 }
 
+// Notify 发送通知，通知写入会话本身的连接（SSE 连接、GET 打开的流或标准输出），而不是某次请求的响应
+// 会话结束后不再发送
+func (s *Session) Notify(method string, params interface{}) error {
+	select {
+	case <-s.info.done:
+		return io.ErrClosedPipe
+	default:
+	}
+	b, err := json.Marshal(Notification{
+		JsonRPC: JsonRPCVersion,
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.info.notify.Write(b)
+	return err
+}
+
+// Done 返回会话结束时关闭的 channel
+func (s *Session) Done() <-chan struct{} {
+	return s.info.done
+}
+
+// Close 结束会话，可重复调用
+func (s *Session) Close() {
+	s.info.once.Do(func() { close(s.info.done) })
+}
+
 func (s *Session) WriteError(req *Request, err error) {
 	resp := NewErrorResponse(req.ID, 500, err)
 	b, err := json.Marshal(resp)
@@ -33,6 +121,18 @@ func (s *Session) WriteError(req *Request, err error) {
 	s.Write(b)
 }
 
+// WriteJsonRPCError 返回标准的 JSON-RPC 错误
+func (s *Session) WriteJsonRPCError(req *Request, e *Error) error {
+	resp := e.JsonRPC()
+	resp.ID = req.ID
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	s.Write(b)
+	return nil
+}
+
 func (s *Session) WriteResponse(req *Request, data interface{}) error {
 	resp := NewResponse(req.ID, data)
 	b, err := json.Marshal(resp)
@@ -44,5 +144,14 @@ func (s *Session) WriteResponse(req *Request, data interface{}) error {
 }
 
 func (s *Session) SaveClientInfo(c *ClientInfo) {
-	s.c = c
+	s.info.mu.Lock()
+	defer s.info.mu.Unlock()
+	s.info.c = c
+}
+
+// ClientInfo 返回 initialize 请求中客户端的信息，尚未初始化时为 nil
+func (s *Session) ClientInfo() *ClientInfo {
+	s.info.mu.RLock()
+	defer s.info.mu.RUnlock()
+	return s.info.c
 }
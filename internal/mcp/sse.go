@@ -1,7 +1,10 @@
 package mcp
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -13,9 +16,15 @@ const (
 	SSEContentType    = "text/event-stream; charset=utf-8"
 )
 
+// SSEWriter 将消息写入 SSE 连接
+// 请求在 worker 中并发处理，handler 返回后 gin 会回收 Context，需要先 Close，之后的写入直接丢弃
 type SSEWriter struct {
-	id string
-	c  *gin.Context
+	id     string
+	c      *gin.Context
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+	once   sync.Once
 }
 
 func NewSSEWriter(c *gin.Context, id string) *SSEWriter {
@@ -26,35 +35,63 @@ func NewSSEWriter(c *gin.Context, id string) *SSEWriter {
 	c.Writer.Flush()
 
 	w := &SSEWriter{
-		id: id,
-		c:  c,
+		id:   id,
+		c:    c,
+		done: make(chan struct{}),
 	}
 	w.WriteEndpoing()
-	go w.ping()
+	go w.ping(c.Request.Context())
 	return w
 }
 
 func (w *SSEWriter) Write(p []byte) (n int, err error) {
-	w.WriteMessage(string(p))
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return 0, io.ErrClosedPipe
+	}
+	writeEvent(w.c.Writer, "message", string(p))
 	return len(p), nil
 }
 
+// Close 停止写入，正在进行的写入完成后返回
+func (w *SSEWriter) Close() {
+	w.mu.Lock()
+	w.closed = true
+	w.mu.Unlock()
+	w.once.Do(func() { close(w.done) })
+}
+
 func (w *SSEWriter) WriteMessage(data string) {
 	w.WriteEvent("message", data)
 }
 
 func (w *SSEWriter) WriteEvent(event string, data string) {
-	w.c.Writer.WriteString(fmt.Sprintf("event: %s\n", event))
-	w.c.Writer.WriteString(fmt.Sprintf("data: %s\n\n", data))
-	w.c.Writer.Flush()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	writeEvent(w.c.Writer, event, data)
 }
 
-func (w *SSEWriter) ping() {
+// writeEvent 写入一个 SSE 事件并立即发送
+func writeEvent(w gin.ResponseWriter, event string, data string) {
+	w.WriteString(fmt.Sprintf("event: %s\n", event))
+	w.WriteString(fmt.Sprintf("data: %s\n\n", data))
+	w.Flush()
+}
+
+func (w *SSEWriter) ping(ctx context.Context) {
+	ticker := time.NewTicker(time.Second * SSEPingIntervalS)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-time.After(time.Second * SSEPingIntervalS):
+		case <-ticker.C:
 			w.writePing()
-		case <-w.c.Request.Context().Done():
+		case <-ctx.Done():
+			return
+		case <-w.done:
 			return
 		}
 	}
@@ -72,6 +109,11 @@ func (w *SSEWriter) WriteEndpoing() {
 // WritePing
 // : ping - 2025-03-16 06:41:51.280928+00:00
 func (w *SSEWriter) writePing() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
 	w.c.Writer.WriteString(fmt.Sprintf(": ping - %s\n\n", time.Now().Format("2006-01-02 15:04:05.999999-07:00")))
 }
 
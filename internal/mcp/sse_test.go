@@ -0,0 +1,58 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSSEWriteAfterClose(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMCP()
+	router := gin.New()
+	router.GET("/sse", m.HandleSSE)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/sse", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var id string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: /message?sessionId="); ok {
+			id = data
+			break
+		}
+	}
+	session := m.GetSession(id)
+	if session == nil {
+		t.Fatalf("session %q not found", id)
+	}
+	if err := session.Notify("notifications/test", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 连接断开、handler 返回后，仍在处理的请求不能再写入已回收的 Context
+	cancel()
+	<-session.Done()
+	if err := session.WriteResponse(&Request{ID: 1}, M{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := session.Write([]byte("{}")); err == nil {
+		t.Error("write after close should fail")
+	}
+	if err := session.Notify("notifications/test", nil); err == nil {
+		t.Error("notify after close should fail")
+	}
+}
@@ -1 +1,68 @@
 package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// StdioWriter 按 MCP stdio 传输的约定输出消息，每条 JSON-RPC 消息占一行
+type StdioWriter struct {
+	w  io.Writer
+	mu sync.Mutex
+}
+
+func NewStdioWriter(w io.Writer) *StdioWriter {
+	return &StdioWriter{w: w}
+}
+
+func (w *StdioWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// 消息内不能包含换行，json.Marshal 的结果本身满足这一点
+	if n, err = w.w.Write(p); err != nil {
+		return n, err
+	}
+	if _, err = w.w.Write([]byte{'\n'}); err != nil {
+		return n, err
+	}
+	return len(p), nil
+}
+
+// NewStdioSession 创建 stdio 会话，整个进程只有这一个会话，auth 为启动时指定的认证信息
+func NewStdioSession(w io.Writer, auth interface{}) *Session {
+	s := newSession("stdio", NewStdioWriter(w))
+	s.info.auth = auth
+	return s
+}
+
+// ServeStdio 从 r 逐行读取 JSON-RPC 请求并交给 ProcessChan 处理，响应写入 session
+// r 读取结束（客户端关闭 stdin）时返回，此时标准输出仍可写，返回时不会结束会话：
+// 已接收的请求需要继续处理并写出响应，由调用方在处理完成后调用 session.Close
+func (m *MCP) ServeStdio(r io.Reader, session *Session) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), MaxMessageSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var req Request
+		if err := json.Unmarshal(line, &req); err != nil {
+			if b, err := json.Marshal(ErrParseError.JsonRPC()); err == nil {
+				session.Write(b)
+			}
+			continue
+		}
+
+		log.Debug().Msgf("session: stdio, request: %s", req)
+		// stdio 只有一个客户端，队列满时阻塞读取即可，不需要拒绝请求
+		m.ProcessChan <- ProcessCtx{Session: session, Request: &req}
+	}
+	return scanner.Err()
+}
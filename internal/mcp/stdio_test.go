@@ -0,0 +1,51 @@
+package mcp
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestServeStdio(t *testing.T) {
+	m := NewMCP()
+	in := strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"ping"}
+
+not json
+{"jsonrpc":"2.0","method":"notifications/initialized"}
+`)
+	var out bytes.Buffer
+	session := NewStdioSession(&out, nil)
+	if err := m.ServeStdio(in, session); err != nil {
+		t.Fatal(err)
+	}
+	m.Close()
+
+	// 输入结束后会话仍然有效，已接收的请求需要返回响应
+	select {
+	case <-session.Done():
+		t.Fatal("session should not be closed at EOF")
+	default:
+	}
+
+	var methods []string
+	for p := range m.ProcessChan {
+		methods = append(methods, p.Request.Method)
+		if err := p.Session.WriteResponse(p.Request, struct{}{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Join(methods, ","); got != "ping,notifications/initialized" {
+		t.Errorf("methods = %s", got)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("output = %q", out.String())
+	}
+	if !strings.Contains(lines[0], `"code":-32700`) {
+		t.Errorf("parse error response = %s", lines[0])
+	}
+	if lines[1] != `{"jsonrpc":"2.0","id":1,"result":{}}` {
+		t.Errorf("ping response = %s", lines[1])
+	}
+}
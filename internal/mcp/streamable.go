@@ -0,0 +1,419 @@
+package mcp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// Streamable HTTP 传输
+// Documents: https://modelcontextprotocol.io/specification/2025-03-26/basic/transports#streamable-http
+//
+// 所有消息通过同一个 endpoint 交互：
+//   POST   发送请求、通知或批量消息，响应以 JSON 或 SSE 流返回
+//   GET    打开 SSE 流，接收服务端主动推送的消息
+//   DELETE 结束会话
+
+const (
+	SessionIDHeader = "Mcp-Session-Id"
+
+	// StreamableSessionTTL 会话在没有请求、也没有打开 SSE 流时保留的时间
+	StreamableSessionTTL = time.Hour
+)
+
+// streamableSession Streamable HTTP 会话
+// 请求的响应写回发起请求的 POST 连接，Session 本身的消息写入 GET 打开的 SSE 流
+type streamableSession struct {
+	*Session
+	stream     *StreamWriter
+	lastActive time.Time
+}
+
+// StreamWriter 将消息写入当前打开的 SSE 流，没有打开的流时丢弃消息
+type StreamWriter struct {
+	mu sync.Mutex
+	w  gin.ResponseWriter
+}
+
+func (w *StreamWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.w != nil {
+		writeEvent(w.w, "message", string(p))
+	}
+	return len(p), nil
+}
+
+func (w *StreamWriter) attach(rw gin.ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.w = rw
+}
+
+func (w *StreamWriter) detach(rw gin.ResponseWriter) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.w == rw {
+		w.w = nil
+	}
+}
+
+func (w *StreamWriter) attached() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w != nil
+}
+
+func (w *StreamWriter) ping() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.w != nil {
+		w.w.WriteString(fmt.Sprintf(": ping - %s\n\n", time.Now().Format("2006-01-02 15:04:05.999999-07:00")))
+		w.w.Flush()
+	}
+}
+
+// responseCollector 收集一次 POST 中各个请求的响应，交给 HTTP handler 写出
+type responseCollector struct {
+	ch   chan []byte
+	done chan struct{}
+	once sync.Once
+}
+
+func newResponseCollector(n int) *responseCollector {
+	return &responseCollector{
+		ch:   make(chan []byte, n),
+		done: make(chan struct{}),
+	}
+}
+
+func (w *responseCollector) Write(p []byte) (n int, err error) {
+	b := append([]byte(nil), p...)
+	select {
+	case w.ch <- b:
+		return len(p), nil
+	case <-w.done:
+		// 客户端已断开
+		return 0, io.ErrClosedPipe
+	}
+}
+
+// CancelRequest 写入空消息，表示一个请求被取消、不会再有响应
+func (w *responseCollector) CancelRequest(id interface{}) {
+	w.Write(nil)
+}
+
+func (w *responseCollector) Close() {
+	w.once.Do(func() { close(w.done) })
+}
+
+// HandleStreamable 处理 Streamable HTTP endpoint 的请求
+func (m *MCP) HandleStreamable(c *gin.Context) {
+	if !ValidOrigin(c.Request) {
+		c.JSON(http.StatusForbidden, ErrInvalidOrigin.JsonRPC())
+		return
+	}
+	switch c.Request.Method {
+	case http.MethodPost:
+		m.handleStreamablePost(c)
+	case http.MethodGet:
+		m.handleStreamableGet(c)
+	case http.MethodDelete:
+		m.handleStreamableDelete(c)
+	default:
+		c.Status(http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *MCP) handleStreamablePost(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, MaxMessageSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrInvalidRequest.JsonRPC())
+		return
+	}
+	reqs, batch, err := ParseMessages(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrParseError.JsonRPC())
+		return
+	}
+
+	// initialize 请求创建新会话，其余请求需要携带会话 ID
+	var session *streamableSession
+	for _, req := range reqs {
+		if req.Method == MethodInitialize {
+			session = m.newStreamableSession(authOf(c))
+			break
+		}
+	}
+	if session == nil {
+		id := c.GetHeader(SessionIDHeader)
+		if id == "" {
+			c.JSON(http.StatusBadRequest, ErrInvalidSessionID.JsonRPC())
+			return
+		}
+		if session = m.getStreamableSession(id); session == nil || !session.authorized(c) {
+			c.JSON(http.StatusNotFound, ErrSessionNotFound.JsonRPC())
+			return
+		}
+	}
+	c.Header(SessionIDHeader, session.id)
+
+	// 客户端发来的响应（服务端目前不会向客户端发请求）直接忽略
+	pending := 0
+	for _, req := range reqs {
+		if req.ID != nil && req.Method != "" {
+			pending++
+		}
+	}
+	// 队列剩余容量不足以放下整批消息时整批拒绝，避免部分请求已处理却无法返回响应
+	messages := 0
+	for _, req := range reqs {
+		if req.Method != "" {
+			messages++
+		}
+	}
+	if len(m.ProcessChan)+messages > cap(m.ProcessChan) {
+		c.JSON(http.StatusTooManyRequests, ErrTooManyRequests.JsonRPC())
+		return
+	}
+
+	collector := newResponseCollector(pending)
+	defer collector.Close()
+	rs := session.WithWriter(collector)
+	for _, req := range reqs {
+		if req.Method == "" {
+			continue
+		}
+		log.Debug().Msgf("session: %s, request: %s", session.id, req)
+		select {
+		case m.ProcessChan <- ProcessCtx{Session: rs, Request: req}:
+		default:
+			// 其他连接同时入队时队列仍可能已满，已入队的请求照常返回响应，其余请求返回错误
+			if req.ID != nil {
+				rs.WriteJsonRPCError(req, ErrTooManyRequests)
+			}
+		}
+	}
+
+	// 只有通知时不需要等待处理结果
+	if pending == 0 {
+		c.Status(http.StatusAccepted)
+		return
+	}
+
+	// 客户端接受 SSE 时以流的形式逐条返回，处理过程中的通知也会一并发送
+	if strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		c.Header("Content-Type", SSEContentType)
+		c.Header("Cache-Control", "no-cache")
+		c.Status(http.StatusOK)
+		c.Writer.Flush()
+		for pending > 0 {
+			select {
+			case b := <-collector.ch:
+				if len(b) == 0 {
+					pending--
+					continue
+				}
+				writeEvent(c.Writer, "message", string(b))
+				if isResponse(b) {
+					pending--
+				}
+			case <-c.Request.Context().Done():
+				return
+			}
+		}
+		return
+	}
+
+	resps := make([][]byte, 0, pending)
+	for pending > 0 {
+		select {
+		case b := <-collector.ch:
+			if len(b) == 0 {
+				pending--
+			} else if isResponse(b) {
+				resps = append(resps, b)
+				pending--
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+	// 请求全部被取消时没有需要返回的响应
+	if len(resps) == 0 {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	data := resps[0]
+	if batch {
+		data = append(append([]byte{'['}, bytes.Join(resps, []byte{','})...), ']')
+	}
+	c.Data(http.StatusOK, "application/json", data)
+}
+
+func (m *MCP) handleStreamableGet(c *gin.Context) {
+	if !strings.Contains(c.GetHeader("Accept"), "text/event-stream") {
+		c.Status(http.StatusNotAcceptable)
+		return
+	}
+	id := c.GetHeader(SessionIDHeader)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, ErrInvalidSessionID.JsonRPC())
+		return
+	}
+	session := m.getStreamableSession(id)
+	if session == nil || !session.authorized(c) {
+		c.JSON(http.StatusNotFound, ErrSessionNotFound.JsonRPC())
+		return
+	}
+
+	c.Header("Content-Type", SSEContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header(SessionIDHeader, id)
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	session.stream.attach(c.Writer)
+	defer session.stream.detach(c.Writer)
+
+	ticker := time.NewTicker(time.Second * SSEPingIntervalS)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			session.stream.ping()
+		case <-c.Request.Context().Done():
+			m.touchStreamableSession(id)
+			return
+		}
+	}
+}
+
+func (m *MCP) handleStreamableDelete(c *gin.Context) {
+	id := c.GetHeader(SessionIDHeader)
+	if id == "" {
+		c.JSON(http.StatusBadRequest, ErrInvalidSessionID.JsonRPC())
+		return
+	}
+	m.sessionMu.Lock()
+	session, ok := m.streams[id]
+	if ok && session.authorized(c) {
+		delete(m.streams, id)
+	} else {
+		ok = false
+	}
+	m.sessionMu.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrSessionNotFound.JsonRPC())
+		return
+	}
+	session.Close()
+	c.Status(http.StatusOK)
+}
+
+// newStreamableSession 创建会话，同时清理长时间未使用的会话，auth 为创建会话的请求中的认证信息
+func (m *MCP) newStreamableSession(auth interface{}) *streamableSession {
+	stream := &StreamWriter{}
+	session := &streamableSession{
+		Session:    newSession(uuid.New().String(), stream),
+		stream:     stream,
+		lastActive: time.Now(),
+	}
+	session.info.auth = auth
+
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	for id, s := range m.streams {
+		if time.Since(s.lastActive) > StreamableSessionTTL && !s.stream.attached() {
+			delete(m.streams, id)
+			s.Close()
+		}
+	}
+	m.streams[session.id] = session
+	return session
+}
+
+func (m *MCP) getStreamableSession(id string) *streamableSession {
+	m.sessionMu.Lock()
+	defer m.sessionMu.Unlock()
+	session, ok := m.streams[id]
+	if !ok {
+		return nil
+	}
+	session.lastActive = time.Now()
+	return session
+}
+
+func (m *MCP) touchStreamableSession(id string) {
+	m.getStreamableSession(id)
+}
+
+// ValidOrigin 校验浏览器请求的 Origin，防止 DNS 重绑定与跨站读取
+// 非浏览器客户端不发送 Origin；浏览器页面只允许来自本机，或与请求地址一致且地址为 IP 的来源
+// 重绑定攻击中页面与请求的域名一致，因此不能只比较 Origin 与 Host
+func ValidOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	host := u.Hostname()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	return ip.IsLoopback() || u.Host == r.Host
+}
+
+// ParseMessages 解析单条或批量的 JSON-RPC 消息，batch 表示消息是否以数组形式发送
+func ParseMessages(data []byte) (reqs []*Request, batch bool, err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		if err := json.Unmarshal(data, &reqs); err != nil {
+			return nil, true, err
+		}
+		if len(reqs) == 0 {
+			return nil, true, fmt.Errorf("empty batch")
+		}
+		for _, req := range reqs {
+			if req == nil {
+				return nil, true, fmt.Errorf("invalid message in batch")
+			}
+		}
+		return reqs, true, nil
+	}
+
+	var req Request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, false, err
+	}
+	return []*Request{&req}, false, nil
+}
+
+// isResponse 判断服务端写出的消息是响应还是通知
+func isResponse(b []byte) bool {
+	var msg struct {
+		Method string `json:"method"`
+	}
+	if err := json.Unmarshal(b, &msg); err != nil {
+		return true
+	}
+	return msg.Method == ""
+}
@@ -0,0 +1,190 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestStreamable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMCP()
+	defer m.Close()
+	go func() {
+		for p := range m.ProcessChan {
+			// 模拟被客户端取消、不返回响应的请求
+			if p.Request.Method == "cancelled" {
+				p.Session.Cancelled(p.Request)
+				continue
+			}
+			if p.Request.ID != nil {
+				p.Session.WriteResponse(p.Request, M{"method": p.Request.Method})
+			}
+		}
+	}()
+	router := gin.New()
+	router.Any("/mcp", m.HandleStreamable)
+
+	post := func(session, accept, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set("Accept", accept)
+		if session != "" {
+			req.Header.Set(SessionIDHeader, session)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("", "application/json", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	session := w.Header().Get(SessionIDHeader)
+	if w.Code != http.StatusOK || session == "" {
+		t.Fatalf("initialize: %d %q", w.Code, w.Body.String())
+	}
+	if w.Body.String() != `{"jsonrpc":"2.0","id":1,"result":{"method":"initialize"}}` {
+		t.Errorf("initialize response = %s", w.Body.String())
+	}
+
+	if w := post("", "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("missing session id: %d", w.Code)
+	}
+	if w := post("unknown", "application/json", `{"jsonrpc":"2.0","id":2,"method":"ping"}`); w.Code != http.StatusNotFound {
+		t.Errorf("unknown session id: %d", w.Code)
+	}
+	if w := post(session, "application/json", `{"jsonrpc":"2.0","method":"notifications/initialized"}`); w.Code != http.StatusAccepted {
+		t.Errorf("notification: %d", w.Code)
+	}
+
+	w = post(session, "application/json", `[{"jsonrpc":"2.0","id":2,"method":"ping"},{"jsonrpc":"2.0","method":"notifications/x"},{"jsonrpc":"2.0","id":3,"method":"tools/list"}]`)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "[") || strings.Count(w.Body.String(), `"jsonrpc"`) != 2 {
+		t.Errorf("batch: %d %s", w.Code, w.Body.String())
+	}
+
+	w = post(session, "application/json, text/event-stream", `{"jsonrpc":"2.0","id":4,"method":"ping"}`)
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream") ||
+		w.Body.String() != "event: message\ndata: {\"jsonrpc\":\"2.0\",\"id\":4,\"result\":{\"method\":\"ping\"}}\n\n" {
+		t.Errorf("sse: %q", w.Body.String())
+	}
+
+	// 被取消的请求没有响应，POST 不再等待
+	if w := post(session, "application/json", `{"jsonrpc":"2.0","id":5,"method":"cancelled"}`); w.Code != http.StatusAccepted {
+		t.Errorf("cancelled: %d %s", w.Code, w.Body.String())
+	}
+	w = post(session, "application/json", `[{"jsonrpc":"2.0","id":6,"method":"cancelled"},{"jsonrpc":"2.0","id":7,"method":"ping"}]`)
+	if w.Body.String() != `[{"jsonrpc":"2.0","id":7,"result":{"method":"ping"}}]` {
+		t.Errorf("batch with cancelled: %d %s", w.Code, w.Body.String())
+	}
+	w = post(session, "text/event-stream", `{"jsonrpc":"2.0","id":8,"method":"cancelled"}`)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("sse cancelled: %d %q", w.Code, w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/mcp", nil)
+	req.Header.Set(SessionIDHeader, session)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("delete: %d", w.Code)
+	}
+	if w := post(session, "application/json", `{"jsonrpc":"2.0","id":9,"method":"ping"}`); w.Code != http.StatusNotFound {
+		t.Errorf("deleted session: %d", w.Code)
+	}
+}
+
+func TestStreamableAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMCP()
+	defer m.Close()
+	go func() {
+		for p := range m.ProcessChan {
+			if p.Request.ID != nil {
+				p.Session.WriteResponse(p.Request, M{"auth": p.Session.Auth()})
+			}
+		}
+	}()
+	router := gin.New()
+	router.Any("/mcp", func(c *gin.Context) {
+		if token := c.GetHeader("Authorization"); token != "" {
+			c.Set(AuthContextKey, token)
+		}
+	}, m.HandleStreamable)
+
+	post := func(session, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Authorization", token)
+		if session != "" {
+			req.Header.Set(SessionIDHeader, session)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("", "a", `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`)
+	session := w.Header().Get(SessionIDHeader)
+	if w.Body.String() != `{"jsonrpc":"2.0","id":1,"result":{"auth":"a"}}` {
+		t.Fatalf("initialize response = %s", w.Body.String())
+	}
+	if w := post(session, "a", `{"jsonrpc":"2.0","id":2,"method":"ping"}`); w.Code != http.StatusOK {
+		t.Errorf("same auth: %d", w.Code)
+	}
+	// 其他令牌或未携带令牌时不能使用已有的会话
+	if w := post(session, "b", `{"jsonrpc":"2.0","id":3,"method":"ping"}`); w.Code != http.StatusNotFound {
+		t.Errorf("other auth: %d", w.Code)
+	}
+	if w := post(session, "", `{"jsonrpc":"2.0","id":4,"method":"ping"}`); w.Code != http.StatusNotFound {
+		t.Errorf("no auth: %d", w.Code)
+	}
+}
+
+func TestStreamableBatchQueueFull(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := NewMCP()
+	m.ProcessChan = make(chan ProcessCtx, 2)
+	router := gin.New()
+	router.Any("/mcp", m.HandleStreamable)
+
+	session := m.newStreamableSession(nil)
+	m.ProcessChan <- ProcessCtx{Session: session.Session, Request: &Request{Method: "ping"}}
+
+	// 队列放不下整批消息时整批拒绝，不会有请求入队
+	req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`[{"jsonrpc":"2.0","id":1,"method":"ping"},{"jsonrpc":"2.0","id":2,"method":"ping"}]`))
+	req.Header.Set(SessionIDHeader, session.id)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("batch: %d %s", w.Code, w.Body.String())
+	}
+	if len(m.ProcessChan) != 1 {
+		t.Errorf("queue length = %d, want 1", len(m.ProcessChan))
+	}
+}
+
+func TestValidOrigin(t *testing.T) {
+	tests := []struct {
+		host, origin string
+		want         bool
+	}{
+		{"127.0.0.1:5030", "", true},
+		{"127.0.0.1:5030", "http://localhost:6274", true},
+		{"127.0.0.1:5030", "http://127.0.0.1:5030", true},
+		{"192.168.1.5:5030", "http://192.168.1.5:5030", true},
+		{"192.168.1.5:5030", "http://192.168.1.6:5030", false},
+		// DNS 重绑定：页面与请求使用同一个域名
+		{"evil.example.com:5030", "http://evil.example.com:5030", false},
+		{"127.0.0.1:5030", "null", false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://"+tt.host+"/mcp", nil)
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+		if got := ValidOrigin(req); got != tt.want {
+			t.Errorf("ValidOrigin(%s, %s) = %v, want %v", tt.host, tt.origin, got, tt.want)
+		}
+	}
+}
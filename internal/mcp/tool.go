@@ -1,5 +1,7 @@
 package mcp
 
+import "encoding/json"
+
 // Document: https://modelcontextprotocol.io/docs/concepts/tools
 
 const (
@@ -86,9 +88,10 @@ type M map[string]interface{}
 //		}
 //	  }
 type Tool struct {
-	Name        string     `json:"name"`
-	Description string     `json:"description,omitempty"`
-	InputSchema ToolSchema `json:"inputSchema"`
+	Name         string      `json:"name"`
+	Description  string      `json:"description,omitempty"`
+	InputSchema  ToolSchema  `json:"inputSchema"`
+	OutputSchema *ToolSchema `json:"outputSchema,omitempty"`
 }
 
 type ToolSchema struct {
@@ -133,11 +136,31 @@ type ToolsCallRequest struct {
 //		}
 //	  }
 type ToolsCallResponse struct {
-	Content []Content `json:"content"`
-	IsError bool      `json:"isError"`
+	Content           []Content   `json:"content"`
+	StructuredContent interface{} `json:"structuredContent,omitempty"`
+	IsError           bool        `json:"isError"`
 }
 
+// Content 工具结果中的内容块
+// text 类型使用 Text，image 与 audio 类型使用 base64 编码的 Data 与 MimeType
 type Content struct {
-	Type string `json:"type"`
-	Text string `json:"text"`
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Data     string `json:"data,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+}
+
+// MarshalJSON 只有 text 类型输出 text 字段
+func (c Content) MarshalJSON() ([]byte, error) {
+	if c.Type == "text" {
+		return json.Marshal(struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}{c.Type, c.Text})
+	}
+	return json.Marshal(struct {
+		Type     string `json:"type"`
+		Data     string `json:"data"`
+		MimeType string `json:"mimeType"`
+	}{c.Type, c.Data, c.MimeType})
 }
@@ -3,6 +3,8 @@ package model
 import (
 	"strings"
 	"time"
+
+	"github.com/sjzar/chatlog/pkg/util"
 )
 
 // CREATE TABLE Chat_md5(talker)(
@@ -55,3 +57,28 @@ func (m *MessageDarwinV3) Wrap(talker string) *Message {
 
 	return _m
 }
+
+// Meta 转换为仅包含元数据的消息，不解析消息内容，用于统计等批量场景
+func (m *MessageDarwinV3) Meta(talker string) *Message {
+	_m := &Message{
+		Time:       time.Unix(m.MsgCreateTime, 0),
+		Talker:     talker,
+		IsChatRoom: strings.HasSuffix(talker, "@chatroom"),
+		IsSelf:     m.MesDes == 0,
+		Version:    WeChatDarwinV3,
+	}
+	_m.Type, _m.SubType = util.SplitInt64ToTwoInt32(m.MessageType)
+
+	switch {
+	case _m.Type == 10000:
+		_m.Sender = "系统消息"
+	case _m.IsChatRoom:
+		if split := strings.SplitN(m.MsgContent, ":\n", 2); len(split) == 2 {
+			_m.Sender = split[0]
+		}
+	case !_m.IsSelf:
+		_m.Sender = talker
+	}
+
+	return _m
+}
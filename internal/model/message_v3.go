@@ -104,6 +104,33 @@ func (m *MessageV3) Wrap() *Message {
 	return _m
 }
 
+// Meta 转换为仅包含元数据的消息，不解析消息内容，用于统计等批量场景
+func (m *MessageV3) Meta() *Message {
+	_m := &Message{
+		Seq:        m.Sequence,
+		Time:       time.Unix(m.CreateTime, 0),
+		Talker:     m.StrTalker,
+		IsChatRoom: strings.HasSuffix(m.StrTalker, "@chatroom"),
+		IsSelf:     m.IsSender == 1,
+		Type:       m.Type,
+		SubType:    int64(m.SubType),
+		Version:    WeChatV3,
+	}
+
+	switch {
+	case _m.Type == 10000:
+		_m.Sender = "系统消息"
+	case _m.IsChatRoom:
+		if bytesExtra := ParseBytesExtra(m.BytesExtra); bytesExtra != nil {
+			_m.Sender = bytesExtra[1]
+		}
+	case !_m.IsSelf:
+		_m.Sender = m.StrTalker
+	}
+
+	return _m
+}
+
 // ParseBytesExtra 解析额外数据
 // 按需解析
 func ParseBytesExtra(b []byte) map[int]string {
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/sjzar/chatlog/internal/model/wxproto"
+	"github.com/sjzar/chatlog/pkg/util"
 	"github.com/sjzar/chatlog/pkg/util/zstd"
 	"google.golang.org/protobuf/proto"
 )
@@ -103,6 +104,24 @@ func (m *MessageV4) Wrap(talker string) *Message {
 	return _m
 }
 
+// Meta 转换为仅包含元数据的消息，不解析消息内容，用于统计等批量场景
+func (m *MessageV4) Meta(talker string) *Message {
+	_m := &Message{
+		Seq:        m.SortSeq,
+		Time:       time.Unix(m.CreateTime, 0),
+		Talker:     talker,
+		IsChatRoom: strings.HasSuffix(talker, "@chatroom"),
+		Sender:     m.UserName,
+		Version:    WeChatV4,
+	}
+	_m.Type, _m.SubType = util.SplitInt64ToTwoInt32(m.LocalType)
+	_m.IsSelf = m.Status == 2 || (!_m.IsChatRoom && talker != m.UserName)
+	if _m.Type == 10000 {
+		_m.Sender = "系统消息"
+	}
+	return _m
+}
+
 func ParsePackedInfo(b []byte) *wxproto.PackedInfo {
 	var pbMsg wxproto.PackedInfo
 	if err := proto.Unmarshal(b, &pbMsg); err != nil {
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// MessageShard 消息数据库分片
+// 按时间分片的数据库 StartTime 与 EndTime 为分片覆盖的时间范围，最后一个分片的 EndTime 为加载时刻；
+// 按聊天对象分片的数据库（macOS 3.x）时间为空，Talkers 为分片中的聊天对象数量
+type MessageShard struct {
+	File      string    `json:"file"` // 相对于工作目录的路径
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+	Talkers   int       `json:"talkers,omitempty"`
+}
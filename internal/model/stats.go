@@ -0,0 +1,176 @@
+package model
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+// StatsSelfKey 自己发送的消息在发送者统计中的 Key，不同版本的数据中自己的 ID 不一定可用
+const StatsSelfKey = "self"
+
+var messageTypeNames = map[int64]string{
+	1:     "文本",
+	3:     "图片",
+	34:    "语音",
+	42:    "名片",
+	43:    "视频",
+	47:    "动画表情",
+	48:    "位置",
+	49:    "分享",
+	50:    "语音通话",
+	10000: "系统消息",
+}
+
+// StatsItem 单个维度的统计结果
+type StatsItem struct {
+	Key       string    `json:"key"`
+	Name      string    `json:"name,omitempty"`
+	Count     int       `json:"count"`
+	FirstTime time.Time `json:"firstTime"`
+	LastTime  time.Time `json:"lastTime"`
+}
+
+func (i *StatsItem) add(t time.Time) {
+	i.Count++
+	if i.FirstTime.IsZero() || t.Before(i.FirstTime) {
+		i.FirstTime = t
+	}
+	if t.After(i.LastTime) {
+		i.LastTime = t
+	}
+}
+
+// MessageStats 消息统计结果
+type MessageStats struct {
+	StartTime time.Time    `json:"startTime"`
+	EndTime   time.Time    `json:"endTime"`
+	Total     int          `json:"total"`
+	FirstTime time.Time    `json:"firstTime"`
+	LastTime  time.Time    `json:"lastTime"`
+	Talkers   []*StatsItem `json:"talkers"`  // 按消息数量降序
+	Senders   []*StatsItem `json:"senders"`  // 按消息数量降序
+	Types     []*StatsItem `json:"types"`    // 按消息数量降序
+	Days      []*StatsItem `json:"days"`     // 按日期升序，Key 格式为 2006-01-02
+	Hours     [24]int      `json:"hours"`    // 每小时消息数量
+	Weekdays  [7]int       `json:"weekdays"` // 每周各天消息数量，0 为周日
+	Heatmap   [7][24]int   `json:"heatmap"`  // 周几 x 小时的消息数量
+}
+
+// Top 返回各排行列表只保留前 n 项的副本，n <= 0 时不做限制
+func (s *MessageStats) Top(n int) *MessageStats {
+	ret := *s
+	if n <= 0 {
+		return &ret
+	}
+	if len(ret.Talkers) > n {
+		ret.Talkers = ret.Talkers[:n]
+	}
+	if len(ret.Senders) > n {
+		ret.Senders = ret.Senders[:n]
+	}
+	return &ret
+}
+
+// MessageStatsCollector 逐条累计消息，生成 MessageStats
+type MessageStatsCollector struct {
+	stats   *MessageStats
+	talkers map[string]*StatsItem
+	senders map[string]*StatsItem
+	types   map[int64]*StatsItem
+	days    map[string]*StatsItem
+}
+
+func NewMessageStatsCollector(startTime, endTime time.Time) *MessageStatsCollector {
+	return &MessageStatsCollector{
+		stats:   &MessageStats{StartTime: startTime, EndTime: endTime},
+		talkers: make(map[string]*StatsItem),
+		senders: make(map[string]*StatsItem),
+		types:   make(map[int64]*StatsItem),
+		days:    make(map[string]*StatsItem),
+	}
+}
+
+// Add 累计一条消息，只使用消息的元数据
+func (c *MessageStatsCollector) Add(msg *Message) {
+	s := c.stats
+	t := msg.Time
+	s.Total++
+	if s.FirstTime.IsZero() || t.Before(s.FirstTime) {
+		s.FirstTime = t
+	}
+	if t.After(s.LastTime) {
+		s.LastTime = t
+	}
+
+	s.Hours[t.Hour()]++
+	s.Weekdays[t.Weekday()]++
+	s.Heatmap[t.Weekday()][t.Hour()]++
+
+	item(c.talkers, msg.Talker).add(t)
+
+	sender := msg.Sender
+	if msg.IsSelf {
+		sender = StatsSelfKey
+	}
+	item(c.senders, sender).add(t)
+
+	typeItem, ok := c.types[msg.Type]
+	if !ok {
+		typeItem = &StatsItem{Key: strconv.FormatInt(msg.Type, 10), Name: messageTypeNames[msg.Type]}
+		c.types[msg.Type] = typeItem
+	}
+	typeItem.add(t)
+
+	day := t.Format("2006-01-02")
+	item(c.days, day).add(t)
+}
+
+// Result 返回统计结果
+func (c *MessageStatsCollector) Result() *MessageStats {
+	s := c.stats
+	s.Talkers = sortByCount(c.talkers)
+	s.Senders = sortByCount(c.senders)
+
+	s.Types = make([]*StatsItem, 0, len(c.types))
+	for _, i := range c.types {
+		s.Types = append(s.Types, i)
+	}
+	sortItems(s.Types)
+
+	s.Days = make([]*StatsItem, 0, len(c.days))
+	for _, i := range c.days {
+		s.Days = append(s.Days, i)
+	}
+	sort.Slice(s.Days, func(i, j int) bool { return s.Days[i].Key < s.Days[j].Key })
+
+	return s
+}
+
+func item(m map[string]*StatsItem, key string) *StatsItem {
+	i, ok := m[key]
+	if !ok {
+		i = &StatsItem{Key: key}
+		m[key] = i
+	}
+	return i
+}
+
+func sortByCount(m map[string]*StatsItem) []*StatsItem {
+	list := make([]*StatsItem, 0, len(m))
+	for _, i := range m {
+		list = append(list, i)
+	}
+	sortItems(list)
+	return list
+}
+
+// sortItems 按消息数量降序，数量相同时按 Key 升序
+func sortItems(list []*StatsItem) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Count != list[j].Count {
+			return list[i].Count > list[j].Count
+		}
+		return list[i].Key < list[j].Key
+	})
+}
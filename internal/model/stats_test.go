@@ -0,0 +1,42 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMessageStatsCollector(t *testing.T) {
+	base := time.Date(2025, 4, 18, 21, 0, 0, 0, time.Local) // 周五
+	c := NewMessageStatsCollector(base, base.Add(48*time.Hour))
+	c.Add(&Message{Time: base, Talker: "group@chatroom", Sender: "wxid_a", Type: 1})
+	c.Add(&Message{Time: base.Add(time.Minute), Talker: "group@chatroom", Sender: "wxid_b", Type: 3})
+	c.Add(&Message{Time: base.Add(2 * time.Minute), Talker: "group@chatroom", Sender: "wxid_a", Type: 1})
+	c.Add(&Message{Time: base.Add(25 * time.Hour), Talker: "wxid_a", Sender: "wxid_me", IsSelf: true, Type: 1})
+
+	s := c.Result()
+	if s.Total != 4 || !s.FirstTime.Equal(base) || !s.LastTime.Equal(base.Add(25*time.Hour)) {
+		t.Fatalf("unexpected summary: total=%d first=%s last=%s", s.Total, s.FirstTime, s.LastTime)
+	}
+	if s.Talkers[0].Key != "group@chatroom" || s.Talkers[0].Count != 3 {
+		t.Errorf("unexpected top talker: %+v", s.Talkers[0])
+	}
+	if s.Senders[0].Key != "wxid_a" || s.Senders[0].Count != 2 {
+		t.Errorf("unexpected top sender: %+v", s.Senders[0])
+	}
+	if s.Senders[1].Key != StatsSelfKey || s.Senders[1].Count != 1 {
+		t.Errorf("self message not counted as %s: %+v", StatsSelfKey, s.Senders)
+	}
+	if s.Types[0].Key != "1" || s.Types[0].Name != "文本" || s.Types[0].Count != 3 {
+		t.Errorf("unexpected top type: %+v", s.Types[0])
+	}
+	if len(s.Days) != 2 || s.Days[0].Key != "2025-04-18" || s.Days[0].Count != 3 {
+		t.Errorf("unexpected days: %+v", s.Days)
+	}
+	if s.Hours[21] != 3 || s.Hours[22] != 1 || s.Heatmap[time.Friday][21] != 3 || s.Weekdays[time.Saturday] != 1 {
+		t.Errorf("unexpected heatmap: hours=%v weekdays=%v", s.Hours, s.Weekdays)
+	}
+
+	if top := s.Top(1); len(top.Talkers) != 1 || len(top.Senders) != 1 || len(s.Senders) != 3 {
+		t.Errorf("Top should truncate a copy: %d %d %d", len(top.Talkers), len(top.Senders), len(s.Senders))
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
@@ -60,7 +61,10 @@ type DataSource struct {
 	path string
 	dbm  *dbm.DBManager
 
+	// talkerMutex 保护 talkerDBMap，查询与数据库文件变化时都会重新加载
+	talkerMutex      sync.RWMutex
 	talkerDBMap      map[string]string
+	talkerScannedAt  time.Time
 	user2DisplayName map[string]string
 }
 
@@ -118,7 +122,9 @@ func (ds *DataSource) initMessageDbs() error {
 	dbPaths, err := ds.dbm.GetDBPath(Message)
 	if err != nil {
 		if strings.Contains(err.Error(), "db file not found") {
+			ds.talkerMutex.Lock()
 			ds.talkerDBMap = make(map[string]string)
+			ds.talkerMutex.Unlock()
 			return nil
 		}
 		return err
@@ -155,10 +161,49 @@ func (ds *DataSource) initMessageDbs() error {
 		}
 		rows.Close()
 	}
+	ds.talkerMutex.Lock()
 	ds.talkerDBMap = talkerDBMap
+	ds.talkerScannedAt = time.Now()
+	ds.talkerMutex.Unlock()
 	return nil
 }
 
+// talkerDB 返回聊天对象 ID 的 MD5 对应的消息数据库
+// 新的聊天对象的表可能在初始化之后才创建在已有的数据库中，找不到时重新扫描，每秒最多一次
+func (ds *DataSource) talkerDB(talkerMd5 string) (string, bool) {
+	ds.talkerMutex.RLock()
+	dbPath, ok := ds.talkerDBMap[talkerMd5]
+	scannedAt := ds.talkerScannedAt
+	ds.talkerMutex.RUnlock()
+	if ok || time.Since(scannedAt) < time.Second {
+		return dbPath, ok
+	}
+	if err := ds.initMessageDbs(); err != nil {
+		log.Debug().Err(err).Msg("failed to reinitialize message DBs")
+		return "", false
+	}
+	ds.talkerMutex.RLock()
+	defer ds.talkerMutex.RUnlock()
+	dbPath, ok = ds.talkerDBMap[talkerMd5]
+	return dbPath, ok
+}
+
+// GetMessageShards 返回已加载的消息数据库分片，消息按聊天对象分布在各个数据库中
+func (ds *DataSource) GetMessageShards() []*model.MessageShard {
+	talkers := make(map[string]int)
+	ds.talkerMutex.RLock()
+	for _, file := range ds.talkerDBMap {
+		talkers[file]++
+	}
+	ds.talkerMutex.RUnlock()
+	shards := make([]*model.MessageShard, 0, len(talkers))
+	for file, n := range talkers {
+		shards = append(shards, &model.MessageShard{File: ds.dbm.RelPath(file), Talkers: n})
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].File < shards[j].File })
+	return shards
+}
+
 func (ds *DataSource) initChatRoomDb() error {
 	db, err := ds.dbm.GetDB(ChatRoom)
 	if err != nil {
@@ -218,8 +263,12 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 	// 从每个相关数据库中查询消息，并在读取时进行过滤
 	filteredMessages := []*model.Message{}
 
+	// 查询结束时（包括提前返回、出错与取消）报告进度完成
+	defer dbm.ReportProgress(ctx, len(talkers), len(talkers))
+
 	// 对每个talker进行查询
-	for _, talkerItem := range talkers {
+	for i, talkerItem := range talkers {
+		dbm.ReportProgress(ctx, i, len(talkers))
 		// 检查上下文是否已取消
 		if err := ctx.Err(); err != nil {
 			return nil, err
@@ -228,7 +277,7 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 		// 在 darwinv3 中，需要先找到对应的数据库
 		_talkerMd5Bytes := md5.Sum([]byte(talkerItem))
 		talkerMd5 := hex.EncodeToString(_talkerMd5Bytes[:])
-		dbPath, ok := ds.talkerDBMap[talkerMd5]
+		dbPath, ok := ds.talkerDB(talkerMd5)
 		if !ok {
 			// 如果找不到对应的数据库，跳过此talker
 			continue
@@ -363,6 +412,98 @@ func extractTalkerFromTableName(tableName string) string {
 	return strings.TrimPrefix(tableName, "Chat_")
 }
 
+// ScanMessages 遍历时间范围内的消息元数据，不解析消息内容，talker 为空时遍历所有聊天对象
+func (ds *DataSource) ScanMessages(ctx context.Context, startTime, endTime time.Time, talker string, fn func(msg *model.Message) error) error {
+	talkers := util.Str2List(talker, ",")
+	if len(talkers) == 0 {
+		// 消息表名为聊天对象的 md5，通过联系人与群聊还原聊天对象
+		var err error
+		if talkers, err = ds.allTalkers(ctx); err != nil {
+			return err
+		}
+	}
+
+	// 查询结束时（包括提前返回、出错与取消）报告进度完成
+	defer dbm.ReportProgress(ctx, len(talkers), len(talkers))
+	for i, talkerItem := range talkers {
+		dbm.ReportProgress(ctx, i, len(talkers))
+		// 检查上下文是否已取消
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		_talkerMd5Bytes := md5.Sum([]byte(talkerItem))
+		talkerMd5 := hex.EncodeToString(_talkerMd5Bytes[:])
+		dbPath, ok := ds.talkerDB(talkerMd5)
+		if !ok {
+			continue
+		}
+
+		db, err := ds.dbm.OpenDB(dbPath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbPath)
+			continue
+		}
+
+		query := fmt.Sprintf(`
+			SELECT msgCreateTime, msgContent, messageType, mesDes
+			FROM Chat_%s
+			WHERE msgCreateTime >= ? AND msgCreateTime <= ?
+		`, talkerMd5)
+
+		rows, err := db.QueryContext(ctx, query, startTime.Unix(), endTime.Unix())
+		if err != nil {
+			if strings.Contains(err.Error(), "no such table") {
+				continue
+			}
+			log.Err(err).Msgf("从数据库 %s 查询消息失败", dbPath)
+			continue
+		}
+
+		for rows.Next() {
+			var msg model.MessageDarwinV3
+			if err := rows.Scan(&msg.MsgCreateTime, &msg.MsgContent, &msg.MessageType, &msg.MesDes); err != nil {
+				rows.Close()
+				return errors.ScanRowFailed(err)
+			}
+			if err := fn(msg.Meta(talkerItem)); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// allTalkers 返回所有联系人与群聊的 ID
+func (ds *DataSource) allTalkers(ctx context.Context) ([]string, error) {
+	contacts, err := ds.GetContacts(ctx, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	chatRooms, err := ds.GetChatRooms(ctx, "", 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	talkers := make([]string, 0, len(contacts)+len(chatRooms))
+	for _, contact := range contacts {
+		if !seen[contact.UserName] {
+			seen[contact.UserName] = true
+			talkers = append(talkers, contact.UserName)
+		}
+	}
+	for _, chatRoom := range chatRooms {
+		if !seen[chatRoom.Name] {
+			seen[chatRoom.Name] = true
+			talkers = append(talkers, chatRoom.Name)
+		}
+	}
+	return talkers, nil
+}
+
 // GetContacts 实现获取联系人信息的方法
 func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error) {
 	var query string
@@ -18,6 +18,9 @@ type DataSource interface {
 	// 消息
 	GetMessages(ctx context.Context, startTime, endTime time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error)
 
+	// 遍历消息元数据，不解析消息内容，talker 为空时遍历所有聊天对象
+	ScanMessages(ctx context.Context, startTime, endTime time.Time, talker string, fn func(msg *model.Message) error) error
+
 	// 联系人
 	GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error)
 
@@ -30,6 +33,9 @@ type DataSource interface {
 	// 媒体
 	GetMedia(ctx context.Context, _type string, key string) (*model.Media, error)
 
+	// 已加载的消息数据库分片
+	GetMessageShards() []*model.MessageShard
+
 	// 设置回调函数
 	SetCallback(name string, callback func(event fsnotify.Event) error) error
 
@@ -2,6 +2,7 @@ package dbm
 
 import (
 	"database/sql"
+	"path/filepath"
 	"runtime"
 	"sync"
 	"time"
@@ -108,6 +109,14 @@ func (d *DBManager) GetDBPath(name string) ([]string, error) {
 	return dbPaths, nil
 }
 
+// RelPath 返回数据库文件相对于工作目录的路径
+func (d *DBManager) RelPath(path string) string {
+	if rel, err := filepath.Rel(d.path, path); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
 func (d *DBManager) OpenDB(path string) (*sql.DB, error) {
 	d.mutex.RLock()
 	db, ok := d.dbs[path]
@@ -0,0 +1,21 @@
+package dbm
+
+import "context"
+
+// ProgressFunc 查询进度回调，done 为已处理完成的数据库（或消息表）数量，total 为总数
+// 查询开始时报告 done 为 0，查询结束（包括获取到足够的消息提前结束、出错与取消）时报告 done 等于 total
+type ProgressFunc func(done, total int)
+
+type progressKey struct{}
+
+// WithProgress 返回携带进度回调的 context，跨多个数据库分片的查询会通过 ReportProgress 报告进度
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// ReportProgress 报告查询进度，context 未携带回调时忽略
+func ReportProgress(ctx context.Context, done, total int) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(done, total)
+	}
+}
@@ -166,11 +166,25 @@ func (ds *DataSource) initMessageDbs() error {
 	return nil
 }
 
+// GetMessageShards 返回已加载的消息数据库分片
+func (ds *DataSource) GetMessageShards() []*model.MessageShard {
+	shards := make([]*model.MessageShard, 0, len(ds.messageInfos))
+	for _, info := range ds.messageInfos {
+		shards = append(shards, &model.MessageShard{
+			File:      ds.dbm.RelPath(info.FilePath),
+			StartTime: info.StartTime,
+			EndTime:   info.EndTime,
+		})
+	}
+	return shards
+}
+
 // getDBInfosForTimeRange 获取时间范围内的数据库信息
 func (ds *DataSource) getDBInfosForTimeRange(startTime, endTime time.Time) []MessageDBInfo {
 	var dbs []MessageDBInfo
-	for _, info := range ds.messageInfos {
-		if info.StartTime.Before(endTime) && info.EndTime.After(startTime) {
+	for i, info := range ds.messageInfos {
+		// 最后一个数据库的结束时间是初始化时刻，之后仍会写入新消息
+		if info.StartTime.Before(endTime) && (i == len(ds.messageInfos)-1 || info.EndTime.After(startTime)) {
 			dbs = append(dbs, info)
 		}
 	}
@@ -210,7 +224,10 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 	// 从每个相关数据库中查询消息，并在读取时进行过滤
 	filteredMessages := []*model.Message{}
 
-	for _, dbInfo := range dbInfos {
+	// 查询结束时（包括提前返回、出错与取消）报告进度完成
+	defer dbm.ReportProgress(ctx, len(dbInfos), len(dbInfos))
+	for i, dbInfo := range dbInfos {
+		dbm.ReportProgress(ctx, i, len(dbInfos))
 		// 检查上下文是否已取消
 		if err := ctx.Err(); err != nil {
 			return nil, err
@@ -359,6 +376,112 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 	return filteredMessages, nil
 }
 
+// ScanMessages 遍历时间范围内的消息元数据，不解析消息内容，talker 为空时遍历所有聊天对象
+func (ds *DataSource) ScanMessages(ctx context.Context, startTime, endTime time.Time, talker string, fn func(msg *model.Message) error) error {
+	// 找到时间范围内的数据库文件
+	dbInfos := ds.getDBInfosForTimeRange(startTime, endTime)
+	if len(dbInfos) == 0 {
+		return errors.TimeRangeNotFound(startTime, endTime)
+	}
+
+	talkers := util.Str2List(talker, ",")
+
+	// 查询结束时（包括提前返回、出错与取消）报告进度完成
+	defer dbm.ReportProgress(ctx, len(dbInfos), len(dbInfos))
+	for i, dbInfo := range dbInfos {
+		dbm.ReportProgress(ctx, i, len(dbInfos))
+		// 检查上下文是否已取消
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+
+		tables, err := messageTables(ctx, db, talkers)
+		if err != nil {
+			log.Err(err).Msgf("获取数据库 %s 的消息表失败", dbInfo.FilePath)
+			continue
+		}
+
+		for tableName, talkerItem := range tables {
+			query := fmt.Sprintf(`
+				SELECT m.sort_seq, m.local_type, IFNULL(n.user_name, ''), m.create_time, m.status
+				FROM %s m
+				LEFT JOIN Name2Id n ON m.real_sender_id = n.rowid
+				WHERE m.create_time >= ? AND m.create_time <= ?
+			`, tableName)
+
+			rows, err := db.QueryContext(ctx, query, startTime.Unix(), endTime.Unix())
+			if err != nil {
+				log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
+				continue
+			}
+
+			for rows.Next() {
+				var msg model.MessageV4
+				if err := rows.Scan(&msg.SortSeq, &msg.LocalType, &msg.UserName, &msg.CreateTime, &msg.Status); err != nil {
+					rows.Close()
+					return errors.ScanRowFailed(err)
+				}
+				if err := fn(msg.Meta(talkerItem)); err != nil {
+					rows.Close()
+					return err
+				}
+			}
+			rows.Close()
+		}
+	}
+	return nil
+}
+
+// messageTables 返回数据库中存在的消息表及其聊天对象，talkers 为空时通过 Name2Id 表还原所有消息表对应的聊天对象
+func messageTables(ctx context.Context, db *sql.DB, talkers []string) (map[string]string, error) {
+	if len(talkers) == 0 {
+		rows, err := db.QueryContext(ctx, "SELECT user_name FROM Name2Id")
+		if err != nil {
+			return nil, errors.QueryFailed("", err)
+		}
+		for rows.Next() {
+			var userName string
+			if err := rows.Scan(&userName); err != nil {
+				rows.Close()
+				return nil, errors.ScanRowFailed(err)
+			}
+			talkers = append(talkers, userName)
+		}
+		rows.Close()
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type='table' AND name LIKE 'Msg_%'")
+	if err != nil {
+		return nil, errors.QueryFailed("", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, errors.ScanRowFailed(err)
+		}
+		existing[name] = true
+	}
+	rows.Close()
+
+	tables := make(map[string]string)
+	for _, talker := range talkers {
+		_talkerMd5Bytes := md5.Sum([]byte(talker))
+		tableName := "Msg_" + hex.EncodeToString(_talkerMd5Bytes[:])
+		if existing[tableName] {
+			tables[tableName] = talker
+		}
+	}
+	return tables, nil
+}
+
 // 联系人
 func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error) {
 	var query string
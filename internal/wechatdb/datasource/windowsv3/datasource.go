@@ -211,11 +211,26 @@ func (ds *DataSource) initMessageDbs() error {
 	return nil
 }
 
+// GetMessageShards 返回已加载的消息数据库分片
+func (ds *DataSource) GetMessageShards() []*model.MessageShard {
+	shards := make([]*model.MessageShard, 0, len(ds.messageInfos))
+	for _, info := range ds.messageInfos {
+		shards = append(shards, &model.MessageShard{
+			File:      ds.dbm.RelPath(info.FilePath),
+			StartTime: info.StartTime,
+			EndTime:   info.EndTime,
+			Talkers:   len(info.TalkerMap),
+		})
+	}
+	return shards
+}
+
 // getDBInfosForTimeRange 获取时间范围内的数据库信息
 func (ds *DataSource) getDBInfosForTimeRange(startTime, endTime time.Time) []MessageDBInfo {
 	var dbs []MessageDBInfo
-	for _, info := range ds.messageInfos {
-		if info.StartTime.Before(endTime) && info.EndTime.After(startTime) {
+	for i, info := range ds.messageInfos {
+		// 最后一个数据库的结束时间是初始化时刻，之后仍会写入新消息
+		if info.StartTime.Before(endTime) && (i == len(ds.messageInfos)-1 || info.EndTime.After(startTime)) {
 			dbs = append(dbs, info)
 		}
 	}
@@ -255,7 +270,10 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 	// 从每个相关数据库中查询消息
 	filteredMessages := []*model.Message{}
 
-	for _, dbInfo := range dbInfos {
+	// 查询结束时（包括提前返回、出错与取消）报告进度完成
+	defer dbm.ReportProgress(ctx, len(dbInfos), len(dbInfos))
+	for i, dbInfo := range dbInfos {
+		dbm.ReportProgress(ctx, i, len(dbInfos))
 		// 检查上下文是否已取消
 		if err := ctx.Err(); err != nil {
 			return nil, err
@@ -400,6 +418,77 @@ func (ds *DataSource) GetMessages(ctx context.Context, startTime, endTime time.T
 	return filteredMessages, nil
 }
 
+// ScanMessages 遍历时间范围内的消息元数据，不解析消息内容，talker 为空时遍历所有聊天对象
+func (ds *DataSource) ScanMessages(ctx context.Context, startTime, endTime time.Time, talker string, fn func(msg *model.Message) error) error {
+	// 找到时间范围内的数据库文件
+	dbInfos := ds.getDBInfosForTimeRange(startTime, endTime)
+	if len(dbInfos) == 0 {
+		return errors.TimeRangeNotFound(startTime, endTime)
+	}
+
+	talkers := util.Str2List(talker, ",")
+
+	// 查询结束时（包括提前返回、出错与取消）报告进度完成
+	defer dbm.ReportProgress(ctx, len(dbInfos), len(dbInfos))
+	for i, dbInfo := range dbInfos {
+		dbm.ReportProgress(ctx, i, len(dbInfos))
+		// 检查上下文是否已取消
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		db, err := ds.dbm.OpenDB(dbInfo.FilePath)
+		if err != nil {
+			log.Error().Msgf("数据库 %s 未打开", dbInfo.FilePath)
+			continue
+		}
+
+		conditions := []string{"Sequence >= ? AND Sequence <= ?"}
+		args := []interface{}{startTime.Unix() * 1000, endTime.Unix() * 1000}
+		if len(talkers) > 0 {
+			talkerConditions := make([]string, 0, len(talkers))
+			for _, talkerItem := range talkers {
+				if talkerID, ok := dbInfo.TalkerMap[talkerItem]; ok {
+					talkerConditions = append(talkerConditions, "TalkerId = ?")
+					args = append(args, talkerID)
+				} else {
+					talkerConditions = append(talkerConditions, "StrTalker = ?")
+					args = append(args, talkerItem)
+				}
+			}
+			conditions = append(conditions, "("+strings.Join(talkerConditions, " OR ")+")")
+		}
+
+		query := fmt.Sprintf(`
+			SELECT Sequence, CreateTime, StrTalker, IsSender, Type, SubType, BytesExtra
+			FROM MSG
+			WHERE %s
+		`, strings.Join(conditions, " AND "))
+
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			log.Err(err).Msgf("从数据库 %s 查询消息失败", dbInfo.FilePath)
+			continue
+		}
+
+		for rows.Next() {
+			var msg model.MessageV3
+			var bytesExtra []byte
+			if err := rows.Scan(&msg.Sequence, &msg.CreateTime, &msg.StrTalker, &msg.IsSender, &msg.Type, &msg.SubType, &bytesExtra); err != nil {
+				rows.Close()
+				return errors.ScanRowFailed(err)
+			}
+			msg.BytesExtra = bytesExtra
+			if err := fn(msg.Meta()); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		rows.Close()
+	}
+	return nil
+}
+
 // GetContacts 实现获取联系人信息的方法
 func (ds *DataSource) GetContacts(ctx context.Context, key string, limit, offset int) ([]*model.Contact, error) {
 	var query string
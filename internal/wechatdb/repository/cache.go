@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"sort"
+	"time"
+)
+
+// CacheStatus 缓存的加载状态
+type CacheStatus struct {
+	Name      string    `json:"name"`
+	Ready     bool      `json:"ready"`   // 是否已完成首次加载
+	Loading   bool      `json:"loading"` // 是否正在加载或重建
+	Entries   int       `json:"entries"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// cacheLoading 标记缓存开始加载，返回的函数在加载结束后调用
+func (r *Repository) cacheLoading(name string) (done func()) {
+	r.cacheMutex.Lock()
+	r.cacheStatus(name).Loading = true
+	r.cacheMutex.Unlock()
+
+	return func() {
+		r.cacheMutex.Lock()
+		r.cacheStatus(name).Loading = false
+		r.cacheMutex.Unlock()
+	}
+}
+
+// cacheLoaded 记录缓存加载完成后的条目数量
+func (r *Repository) cacheLoaded(name string, entries int) {
+	r.cacheMutex.Lock()
+	status := r.cacheStatus(name)
+	status.Ready = true
+	status.Entries = entries
+	status.UpdatedAt = time.Now()
+	r.cacheMutex.Unlock()
+
+	cacheEntries.With(name).Set(float64(entries))
+}
+
+// cacheStatus 返回缓存状态，调用前需持有 cacheMutex
+func (r *Repository) cacheStatus(name string) *CacheStatus {
+	if r.caches == nil {
+		r.caches = make(map[string]*CacheStatus)
+	}
+	status, ok := r.caches[name]
+	if !ok {
+		status = &CacheStatus{Name: name}
+		r.caches[name] = status
+	}
+	return status
+}
+
+// CacheStatus 返回各缓存的加载状态，按名称排序
+func (r *Repository) CacheStatus() []CacheStatus {
+	r.cacheMutex.Lock()
+	defer r.cacheMutex.Unlock()
+	list := make([]CacheStatus, 0, len(r.caches))
+	for _, status := range r.caches {
+		list = append(list, *status)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// Ready 联系人与群聊缓存是否均已完成首次加载
+func (r *Repository) Ready() bool {
+	r.cacheMutex.Lock()
+	defer r.cacheMutex.Unlock()
+	for _, name := range []string{"contact", "chatroom"} {
+		if status, ok := r.caches[name]; !ok || !status.Ready {
+			return false
+		}
+	}
+	return true
+}
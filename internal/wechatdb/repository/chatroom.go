@@ -11,6 +11,8 @@ import (
 
 // initChatRoomCache 初始化群聊缓存
 func (r *Repository) initChatRoomCache(ctx context.Context) error {
+	defer r.cacheLoading("chatroom")()
+
 	// 加载所有群聊到缓存
 	chatRooms, err := r.ds.GetChatRooms(ctx, "", 0, 0)
 	if err != nil {
@@ -89,6 +91,9 @@ func (r *Repository) initChatRoomCache(ctx context.Context) error {
 	r.chatRoomRemark = chatRoomRemark
 	r.chatRoomNickName = chatRoomNickName
 
+	r.resetTalkerCache()
+
+	r.cacheLoaded("chatroom", len(chatRoomMap))
 	return nil
 }
 
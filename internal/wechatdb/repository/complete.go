@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// 补全候选项的匹配程度，数值越小越优先
+const (
+	CompletionExact = iota
+	CompletionPrefix
+	CompletionContains
+)
+
+// Completion 参数补全的候选项
+type Completion struct {
+	// UserName 联系人、群聊或群成员的 ID
+	UserName string
+	// Value 补全值，名称能唯一确定 UserName 时使用名称，否则使用 ID，避免同名时查询到错误的对象
+	Value string
+	// Score 匹配程度，CompletionExact、CompletionPrefix 或 CompletionContains
+	Score int
+}
+
+// completions 按 UserName 去重，保留匹配程度最高的候选项
+type completions map[string]Completion
+
+// add 添加匹配的候选项，unique 判断名称能否唯一确定 userName，只在名称匹配时调用
+func (c completions) add(key, userName, name string, unique func() bool) {
+	score, ok := matchScore(key, name)
+	if !ok {
+		return
+	}
+	// 匹配程度相同时，名称优先于 ID
+	old, exists := c[userName]
+	if exists && (old.Score < score || old.Score == score && old.Value != userName) {
+		return
+	}
+	value := userName
+	if unique != nil && unique() {
+		value = name
+	}
+	if exists && old.Score == score && value == userName {
+		return
+	}
+	c[userName] = Completion{UserName: userName, Value: value, Score: score}
+}
+
+func always() bool { return true }
+
+// list 按匹配程度与补全值排序
+func (c completions) list() []Completion {
+	ret := make([]Completion, 0, len(c))
+	for _, item := range c {
+		ret = append(ret, item)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Score != ret[j].Score {
+			return ret[i].Score < ret[j].Score
+		}
+		return ret[i].Value < ret[j].Value
+	})
+	return ret
+}
+
+// matchScore 忽略大小写匹配，key 为空时匹配所有名称
+func matchScore(key, name string) (int, bool) {
+	if name == "" {
+		return 0, false
+	}
+	key, name = strings.ToLower(key), strings.ToLower(name)
+	switch {
+	case key == name:
+		return CompletionExact, true
+	case strings.HasPrefix(name, key):
+		return CompletionPrefix, true
+	case strings.Contains(name, key):
+		return CompletionContains, true
+	}
+	return 0, false
+}
+
+// resolveTalker 与查询消息时解析 talker 的顺序一致，先查找联系人再查找群聊
+func (r *Repository) resolveTalker(key string) string {
+	if contact := r.findContact(key); contact != nil {
+		return contact.UserName
+	}
+	if chatRoom := r.findChatRoom(key); chatRoom != nil {
+		return chatRoom.Name
+	}
+	return ""
+}
+
+// CompleteTalkers 在联系人与群聊的 ID、微信号、备注名与昵称中查找包含 key 的对象
+// contacts 与 chatRooms 分别控制是否包含联系人与群聊
+func (r *Repository) CompleteTalkers(key string, contacts, chatRooms bool) []Completion {
+	c := make(completions)
+	if contacts {
+		addContacts := func(names []string, index map[string][]*model.Contact) {
+			for _, name := range names {
+				for _, contact := range index[name] {
+					if strings.HasSuffix(contact.UserName, "@chatroom") {
+						continue
+					}
+					c.add(key, contact.UserName, name, func() bool { return r.resolveTalker(name) == contact.UserName })
+				}
+			}
+		}
+		addContacts(r.remarkList, r.remarkToContact)
+		addContacts(r.nickNameList, r.nickNameToContact)
+		addContacts(r.aliasList, r.aliasToContact)
+		for _, userName := range r.contactList {
+			if !strings.HasSuffix(userName, "@chatroom") {
+				c.add(key, userName, userName, always)
+			}
+		}
+	}
+	if chatRooms {
+		addChatRooms := func(names []string, index map[string][]*model.ChatRoom) {
+			for _, name := range names {
+				for _, chatRoom := range index[name] {
+					c.add(key, chatRoom.Name, name, func() bool { return r.resolveTalker(name) == chatRoom.Name })
+				}
+			}
+		}
+		addChatRooms(r.chatRoomRemark, r.remarkToChatRoom)
+		addChatRooms(r.chatRoomNickName, r.nickNameToChatRoom)
+		for _, name := range r.chatRoomList {
+			c.add(key, name, name, always)
+		}
+	}
+	return c.list()
+}
+
+// CompleteChatRoomMembers 在群成员的 ID、群昵称与联系人名称中查找包含 key 的成员
+// 群昵称在群内唯一时使用群昵称作为补全值，查询消息时 sender 可以直接使用群昵称
+func (r *Repository) CompleteChatRoomMembers(chatRoom, key string) []Completion {
+	room := r.findChatRoom(chatRoom)
+	if room == nil {
+		return []Completion{}
+	}
+
+	displayNames := make(map[string]int)
+	for _, user := range room.Users {
+		if name := room.User2DisplayName[user.UserName]; name != "" {
+			displayNames[name]++
+		}
+	}
+
+	c := make(completions)
+	for _, user := range room.Users {
+		c.add(key, user.UserName, user.UserName, always)
+		if name := room.User2DisplayName[user.UserName]; name != "" {
+			c.add(key, user.UserName, name, func() bool { return displayNames[name] == 1 })
+		}
+		if contact := r.getFullContact(user.UserName); contact != nil {
+			// 联系人名称在群内可能重名，只用于匹配，补全值使用 ID
+			c.add(key, user.UserName, contact.Remark, nil)
+			c.add(key, user.UserName, contact.NickName, nil)
+		}
+	}
+	return c.list()
+}
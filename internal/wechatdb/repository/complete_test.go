@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+func TestCompleteTalkers(t *testing.T) {
+	zhang := &model.Contact{UserName: "wxid_zhang", Remark: "张三", NickName: "Zhang"}
+	zhang2 := &model.Contact{UserName: "wxid_zhang2", NickName: "Zhang"}
+	li := &model.Contact{UserName: "wxid_li", NickName: "李四"}
+	room := &model.ChatRoom{
+		Name:     "123@chatroom",
+		NickName: "张家群",
+		Users:    []model.ChatRoomUser{{UserName: "wxid_zhang"}, {UserName: "wxid_zhang2"}, {UserName: "wxid_li"}},
+		User2DisplayName: map[string]string{
+			"wxid_zhang":  "老张",
+			"wxid_zhang2": "老张",
+			"wxid_li":     "小李",
+		},
+	}
+
+	r := &Repository{
+		contactCache:       map[string]*model.Contact{zhang.UserName: zhang, zhang2.UserName: zhang2, li.UserName: li},
+		remarkToContact:    map[string][]*model.Contact{"张三": {zhang}},
+		nickNameToContact:  map[string][]*model.Contact{"Zhang": {zhang, zhang2}, "李四": {li}},
+		aliasToContact:     map[string][]*model.Contact{},
+		contactList:        []string{"wxid_li", "wxid_zhang", "wxid_zhang2"},
+		remarkList:         []string{"张三"},
+		nickNameList:       []string{"Zhang", "李四"},
+		chatRoomCache:      map[string]*model.ChatRoom{room.Name: room},
+		remarkToChatRoom:   map[string][]*model.ChatRoom{},
+		nickNameToChatRoom: map[string][]*model.ChatRoom{"张家群": {room}},
+		chatRoomList:       []string{room.Name},
+		chatRoomNickName:   []string{"张家群"},
+		chatRoomUserToInfo: map[string]*model.Contact{},
+	}
+
+	values := func(list []Completion) []string {
+		ret := make([]string, 0, len(list))
+		for _, c := range list {
+			ret = append(ret, c.Value)
+		}
+		return ret
+	}
+
+	// 重名的昵称不能唯一确定联系人，使用 ID
+	if got, want := values(r.CompleteTalkers("zh", true, false)), []string{"Zhang", "wxid_zhang2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompleteTalkers(zh) = %v, want %v", got, want)
+	}
+	if got, want := values(r.CompleteTalkers("张", true, true)), []string{"张三", "张家群"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompleteTalkers(张) = %v, want %v", got, want)
+	}
+	if got, want := values(r.CompleteTalkers("张", false, true)), []string{"张家群"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompleteTalkers(张, chatRooms) = %v, want %v", got, want)
+	}
+
+	// 群昵称重名时使用 ID
+	if got, want := values(r.CompleteChatRoomMembers("张家群", "")), []string{"wxid_zhang", "wxid_zhang2", "小李"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("CompleteChatRoomMembers = %v, want %v", got, want)
+	}
+	if got := r.CompleteChatRoomMembers("不存在", ""); len(got) != 0 {
+		t.Errorf("CompleteChatRoomMembers(unknown) = %v", got)
+	}
+}
@@ -11,6 +11,8 @@ import (
 
 // initContactCache 初始化联系人缓存
 func (r *Repository) initContactCache(ctx context.Context) error {
+	defer r.cacheLoading("contact")()
+
 	// 加载所有联系人到缓存
 	contacts, err := r.ds.GetContacts(ctx, "", 0, 0)
 	if err != nil {
@@ -86,6 +88,10 @@ func (r *Repository) initContactCache(ctx context.Context) error {
 	r.aliasList = aliasList
 	r.remarkList = remarkList
 	r.nickNameList = nickNameList
+
+	r.resetTalkerCache()
+
+	r.cacheLoaded("contact", len(contactMap))
 	return nil
 }
 
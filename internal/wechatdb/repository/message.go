@@ -60,7 +60,56 @@ func (r *Repository) enrichMessage(msg *model.Message) {
 	}
 }
 
+// TalkerCacheSize 聊天对象与发送者解析结果缓存的最大数量，超出时清空
+const TalkerCacheSize = 1024
+
+// talkerKey 聊天对象与发送者参数，同时用作解析结果
+type talkerKey struct {
+	talker string
+	sender string
+}
+
+// parseTalkerAndSender 将聊天对象与发送者的名称解析为 ID
+// 结果按参数缓存，联系人或群聊缓存重建时失效
 func (r *Repository) parseTalkerAndSender(ctx context.Context, talker, sender string) (string, string) {
+	if talker == "" && sender == "" {
+		return "", ""
+	}
+	key := talkerKey{talker: talker, sender: sender}
+
+	r.talkerMutex.Lock()
+	v, ok := r.talkerCache[key]
+	gen := r.talkerGen
+	r.talkerMutex.Unlock()
+	if ok {
+		return v.talker, v.sender
+	}
+
+	v.talker, v.sender = r.resolveTalkerAndSender(ctx, talker, sender)
+
+	r.talkerMutex.Lock()
+	// 解析期间缓存已重建时结果可能已过期，不保存
+	if gen == r.talkerGen {
+		if len(r.talkerCache) >= TalkerCacheSize {
+			r.talkerCache = make(map[talkerKey]talkerKey)
+		}
+		r.talkerCache[key] = v
+		cacheEntries.With("talker").Set(float64(len(r.talkerCache)))
+	}
+	r.talkerMutex.Unlock()
+	return v.talker, v.sender
+}
+
+// resetTalkerCache 清空聊天对象与发送者的解析结果
+func (r *Repository) resetTalkerCache() {
+	r.talkerMutex.Lock()
+	r.talkerCache = make(map[talkerKey]talkerKey)
+	r.talkerGen++
+	cacheEntries.With("talker").Set(0)
+	r.talkerMutex.Unlock()
+}
+
+func (r *Repository) resolveTalkerAndSender(ctx context.Context, talker, sender string) (string, string) {
 	displayName2User := make(map[string]string)
 	users := make(map[string]bool)
 
@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+func TestParseTalkerAndSenderCache(t *testing.T) {
+	zhang := &model.Contact{UserName: "wxid_zhang", Remark: "张三"}
+	li := &model.Contact{UserName: "wxid_li", Remark: "张三"}
+	r := &Repository{
+		contactCache:       map[string]*model.Contact{zhang.UserName: zhang, li.UserName: li},
+		remarkToContact:    map[string][]*model.Contact{"张三": {zhang}},
+		nickNameToContact:  map[string][]*model.Contact{},
+		aliasToContact:     map[string][]*model.Contact{},
+		chatRoomCache:      map[string]*model.ChatRoom{},
+		remarkToChatRoom:   map[string][]*model.ChatRoom{},
+		nickNameToChatRoom: map[string][]*model.ChatRoom{},
+		chatRoomUserToInfo: map[string]*model.Contact{},
+		talkerCache:        make(map[talkerKey]talkerKey),
+	}
+	ctx := context.Background()
+
+	if talker, _ := r.parseTalkerAndSender(ctx, "张三", ""); talker != "wxid_zhang" {
+		t.Fatalf("talker = %s, want wxid_zhang", talker)
+	}
+
+	// 备注名修改后，联系人缓存重建之前使用缓存的解析结果
+	r.remarkToContact["张三"] = []*model.Contact{li}
+	if talker, _ := r.parseTalkerAndSender(ctx, "张三", ""); talker != "wxid_zhang" {
+		t.Errorf("cached talker = %s, want wxid_zhang", talker)
+	}
+	r.resetTalkerCache()
+	if talker, _ := r.parseTalkerAndSender(ctx, "张三", ""); talker != "wxid_li" {
+		t.Errorf("talker after reset = %s, want wxid_li", talker)
+	}
+}
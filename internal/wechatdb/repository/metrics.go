@@ -0,0 +1,8 @@
+package repository
+
+import (
+	"github.com/sjzar/chatlog/pkg/metrics"
+)
+
+// cacheEntries 各缓存的条目数量，缓存重建时更新
+var cacheEntries = metrics.NewGauge("chatlog_repository_cache_entries", "Number of entries in the repository caches.", "cache")
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"sync"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/rs/zerolog/log"
@@ -36,6 +37,19 @@ type Repository struct {
 
 	// 快速查找索引
 	chatRoomUserToInfo map[string]*model.Contact
+
+	// Cache for message stats
+	statsCache map[statsKey]*model.MessageStats
+	statsMutex sync.Mutex
+
+	// 聊天对象与发送者的解析结果，talkerGen 在联系人或群聊缓存重建时递增
+	talkerCache map[talkerKey]talkerKey
+	talkerGen   int
+	talkerMutex sync.Mutex
+
+	// 缓存加载状态
+	caches     map[string]*CacheStatus
+	cacheMutex sync.Mutex
 }
 
 // New 创建一个新的 Repository
@@ -57,6 +71,8 @@ func New(ds datasource.DataSource) (*Repository, error) {
 		chatRoomList:       make([]string, 0),
 		chatRoomRemark:     make([]string, 0),
 		chatRoomNickName:   make([]string, 0),
+		statsCache:         make(map[statsKey]*model.MessageStats),
+		talkerCache:        make(map[talkerKey]talkerKey),
 	}
 
 	// 初始化缓存
@@ -66,6 +82,7 @@ func New(ds datasource.DataSource) (*Repository, error) {
 
 	ds.SetCallback("contact", r.contactCallback)
 	ds.SetCallback("chatroom", r.chatroomCallback)
+	ds.SetCallback("message", r.messageCallback)
 
 	return r, nil
 }
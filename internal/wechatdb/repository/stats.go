@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/sjzar/chatlog/internal/model"
+)
+
+// StatsCacheSize 统计结果缓存的最大数量，超出时清空
+const StatsCacheSize = 64
+
+type statsKey struct {
+	start  int64
+	end    int64
+	talker string
+}
+
+// GetMessageStats 统计时间范围内的消息，talker 为空时统计所有聊天对象
+// 结果按时间范围与聊天对象缓存，消息数据库变更时失效
+func (r *Repository) GetMessageStats(ctx context.Context, startTime, endTime time.Time, talker string) (*model.MessageStats, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	key := statsKey{start: startTime.Unix(), end: endTime.Unix(), talker: talker}
+
+	r.statsMutex.Lock()
+	stats, ok := r.statsCache[key]
+	r.statsMutex.Unlock()
+	if ok {
+		return stats, nil
+	}
+
+	collector := model.NewMessageStatsCollector(startTime, endTime)
+	err := r.ds.ScanMessages(ctx, startTime, endTime, talker, func(msg *model.Message) error {
+		collector.Add(msg)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats = collector.Result()
+	r.enrichStats(stats)
+
+	r.statsMutex.Lock()
+	if len(r.statsCache) >= StatsCacheSize {
+		r.statsCache = make(map[statsKey]*model.MessageStats)
+	}
+	r.statsCache[key] = stats
+	cacheEntries.With("stats").Set(float64(len(r.statsCache)))
+	r.statsMutex.Unlock()
+
+	return stats, nil
+}
+
+// GetMessageStatsFunc 统计时间范围内 match 返回 true 的消息，结果不缓存
+func (r *Repository) GetMessageStatsFunc(ctx context.Context, startTime, endTime time.Time, talker string, match func(msg *model.Message) bool) (*model.MessageStats, error) {
+	talker, _ = r.parseTalkerAndSender(ctx, talker, "")
+	collector := model.NewMessageStatsCollector(startTime, endTime)
+	err := r.ds.ScanMessages(ctx, startTime, endTime, talker, func(msg *model.Message) error {
+		if match(msg) {
+			collector.Add(msg)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	stats := collector.Result()
+	r.enrichStats(stats)
+	return stats, nil
+}
+
+// enrichStats 补充聊天对象与发送者的显示名称
+func (r *Repository) enrichStats(stats *model.MessageStats) {
+	for _, item := range stats.Talkers {
+		if chatRoom, ok := r.chatRoomCache[item.Key]; ok {
+			item.Name = chatRoom.DisplayName()
+		} else if contact := r.getFullContact(item.Key); contact != nil {
+			item.Name = contact.DisplayName()
+		}
+	}
+
+	// 只统计一个群聊时优先使用群昵称
+	var chatRoom *model.ChatRoom
+	if len(stats.Talkers) == 1 {
+		chatRoom = r.chatRoomCache[stats.Talkers[0].Key]
+	}
+	for _, item := range stats.Senders {
+		if chatRoom != nil {
+			if displayName, ok := chatRoom.User2DisplayName[item.Key]; ok && displayName != "" {
+				item.Name = displayName
+				continue
+			}
+		}
+		if contact := r.getFullContact(item.Key); contact != nil {
+			item.Name = contact.DisplayName()
+		}
+	}
+}
+
+func (r *Repository) messageCallback(event fsnotify.Event) error {
+	if !event.Op.Has(fsnotify.Create) {
+		return nil
+	}
+	r.statsMutex.Lock()
+	r.statsCache = make(map[statsKey]*model.MessageStats)
+	cacheEntries.With("stats").Set(0)
+	r.statsMutex.Unlock()
+	return nil
+}
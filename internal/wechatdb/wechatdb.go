@@ -4,6 +4,8 @@ import (
 	"context"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
+
 	"github.com/sjzar/chatlog/internal/model"
 	"github.com/sjzar/chatlog/internal/wechatdb/datasource"
 	"github.com/sjzar/chatlog/internal/wechatdb/repository"
@@ -57,9 +59,32 @@ func (w *DB) Initialize() error {
 	return nil
 }
 
+// GetMessageShards 返回已加载的消息数据库分片
+func (w *DB) GetMessageShards() []*model.MessageShard {
+	return w.ds.GetMessageShards()
+}
+
+// GetCacheStatus 返回联系人与群聊等缓存的加载状态
+func (w *DB) GetCacheStatus() []repository.CacheStatus {
+	return w.repo.CacheStatus()
+}
+
+// Ready 缓存是否已完成首次加载
+func (w *DB) Ready() bool {
+	return w.repo != nil && w.repo.Ready()
+}
+
+// SetCallback 注册数据库文件变更回调，name 为数据源中的文件分组名称
+func (w *DB) SetCallback(name string, callback func(event fsnotify.Event) error) error {
+	return w.ds.SetCallback(name, callback)
+}
+
 func (w *DB) GetMessages(start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
-	ctx := context.Background()
+	return w.GetMessagesContext(context.Background(), start, end, talker, sender, keyword, limit, offset)
+}
 
+// GetMessagesContext 查询消息，ctx 取消时中止查询，可通过 dbm.WithProgress 获取查询进度
+func (w *DB) GetMessagesContext(ctx context.Context, start, end time.Time, talker string, sender string, keyword string, limit, offset int) ([]*model.Message, error) {
 	// 使用 repository 获取消息
 	messages, err := w.repo.GetMessages(ctx, start, end, talker, sender, keyword, limit, offset)
 	if err != nil {
@@ -69,6 +94,21 @@ func (w *DB) GetMessages(start, end time.Time, talker string, sender string, key
 	return messages, nil
 }
 
+// GetMessageStats 统计时间范围内的消息，talker 为空时统计所有聊天对象
+func (w *DB) GetMessageStats(start, end time.Time, talker string) (*model.MessageStats, error) {
+	return w.GetMessageStatsContext(context.Background(), start, end, talker)
+}
+
+// GetMessageStatsContext 统计时间范围内的消息，ctx 取消时中止统计
+func (w *DB) GetMessageStatsContext(ctx context.Context, start, end time.Time, talker string) (*model.MessageStats, error) {
+	return w.repo.GetMessageStats(ctx, start, end, talker)
+}
+
+// GetMessageStatsFunc 统计时间范围内 match 返回 true 的消息，用于访问控制等需要过滤聊天对象的场景
+func (w *DB) GetMessageStatsFunc(ctx context.Context, start, end time.Time, talker string, match func(msg *model.Message) bool) (*model.MessageStats, error) {
+	return w.repo.GetMessageStatsFunc(ctx, start, end, talker, match)
+}
+
 type GetContactsResp struct {
 	Items []*model.Contact `json:"items"`
 }
@@ -124,3 +164,13 @@ func (w *DB) GetSessions(key string, limit, offset int) (*GetSessionsResp, error
 func (w *DB) GetMedia(_type string, key string) (*model.Media, error) {
 	return w.repo.GetMedia(context.Background(), _type, key)
 }
+
+// CompleteTalkers 补全联系人与群聊，用于 MCP 参数补全
+func (w *DB) CompleteTalkers(key string, contacts, chatRooms bool) []repository.Completion {
+	return w.repo.CompleteTalkers(key, contacts, chatRooms)
+}
+
+// CompleteChatRoomMembers 补全群成员
+func (w *DB) CompleteChatRoomMembers(chatRoom, key string) []repository.Completion {
+	return w.repo.CompleteChatRoomMembers(chatRoom, key)
+}
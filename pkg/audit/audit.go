@@ -0,0 +1,164 @@
+// Package audit records data accesses in an append-only JSON Lines file
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Entry is one data access
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Source  string            `json:"source"`            // http, mcp, export
+	Caller  string            `json:"caller"`            // token, client address or MCP client
+	Account string            `json:"account,omitempty"` // WeChat account the data belongs to
+	Action  string            `json:"action"`            // route, tool or resource
+	Params  map[string]string `json:"params,omitempty"`
+	Talkers []string          `json:"talkers,omitempty"` // talkers whose data was returned
+	Count   int               `json:"count"`             // number of returned items
+	Status  int               `json:"status,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+// Logger appends entries to a file, a nil Logger discards them
+type Logger struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// Open opens the log file for appending, creating it if needed
+func Open(path string) (*Logger, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	// terminate a partial line left by a crash so that it does not swallow the next entry
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			f.Write([]byte{'\n'})
+		}
+	}
+	return &Logger{f: f}, nil
+}
+
+// Record appends an entry, Time defaults to now
+func (l *Logger) Record(e Entry) error {
+	if l == nil {
+		return nil
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return os.ErrClosed
+	}
+	// one write per line so that concurrent processes do not interleave entries
+	_, err = l.f.Write(append(b, '\n'))
+	return err
+}
+
+func (l *Logger) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+var (
+	defaultMu sync.RWMutex
+	defaultL  *Logger
+)
+
+// SetDefault sets the logger used by Record, nil disables recording
+func SetDefault(l *Logger) {
+	defaultMu.Lock()
+	defaultL = l
+	defaultMu.Unlock()
+}
+
+// Record appends an entry to the default logger
+func Record(e Entry) error {
+	defaultMu.RLock()
+	l := defaultL
+	defaultMu.RUnlock()
+	return l.Record(e)
+}
+
+// Filter selects entries, zero fields match everything
+type Filter struct {
+	Start, End time.Time
+	Source     string
+	Caller     string
+	Account    string
+	Action     string
+	Talker     string
+}
+
+// Match reports whether the entry satisfies the filter
+func (f *Filter) Match(e *Entry) bool {
+	switch {
+	case !f.Start.IsZero() && e.Time.Before(f.Start),
+		!f.End.IsZero() && e.Time.After(f.End),
+		f.Source != "" && e.Source != f.Source,
+		f.Caller != "" && e.Caller != f.Caller,
+		f.Account != "" && e.Account != f.Account,
+		f.Action != "" && e.Action != f.Action:
+		return false
+	}
+	if f.Talker == "" {
+		return true
+	}
+	for _, t := range e.Talkers {
+		if t == f.Talker {
+			return true
+		}
+	}
+	return false
+}
+
+// Read calls fn for every entry of the log file that matches the filter, in file order.
+// Malformed lines, such as a partial line left by a crash, are skipped
+func Read(path string, f *Filter, fn func(e *Entry) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			continue
+		}
+		if f != nil && !f.Match(&e) {
+			continue
+		}
+		if err := fn(&e); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit", "audit.jsonl")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	entries := []Entry{
+		{Time: now.Add(-time.Hour), Source: "http", Caller: "a", Action: "/api/v1/chatlog", Talkers: []string{"wxid_1"}, Count: 3},
+		{Time: now, Source: "mcp", Caller: "b", Action: "chatlog", Talkers: []string{"wxid_1", "wxid_2"}, Count: 5},
+		{Source: "export", Caller: "c", Action: "export"},
+	}
+	for _, e := range entries {
+		if err := l.Record(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// a partial line left by a crash
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	f.WriteString(`{"time":"2025`)
+	f.Close()
+
+	// reopening must not append to the partial line
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Record(Entry{Source: "http", Caller: "d"})
+	l.Close()
+
+	for _, tc := range []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{}, []string{"a", "b", "c", "d"}},
+		{Filter{Talker: "wxid_1"}, []string{"a", "b"}},
+		{Filter{Source: "mcp"}, []string{"b"}},
+		{Filter{Source: "http"}, []string{"a", "d"}},
+		{Filter{Start: now.Add(-time.Minute), Talker: "wxid_1"}, []string{"b"}},
+	} {
+		var got []string
+		err := Read(path, &tc.filter, func(e *Entry) error {
+			got = append(got, e.Caller)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%+v: got %v, want %v", tc.filter, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%+v: got %v, want %v", tc.filter, got, tc.want)
+			}
+		}
+	}
+
+	var nilLogger *Logger
+	if err := nilLogger.Record(Entry{}); err != nil {
+		t.Errorf("nil logger: %v", err)
+	}
+}
@@ -360,6 +360,7 @@ func (fm *FileMonitor) watchLoop() {
 				// Channel closed, exit loop
 				return
 			}
+			countEvent(event)
 
 			// Handle directory creation events to add new watches
 			info, err := os.Stat(event.Name)
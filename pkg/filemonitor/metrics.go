@@ -0,0 +1,22 @@
+package filemonitor
+
+import (
+	"strings"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/sjzar/chatlog/pkg/metrics"
+)
+
+var eventsTotal = metrics.NewCounter("chatlog_filemonitor_events_total", "Number of file system events received by the file monitor.", "op")
+
+var eventOps = []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod}
+
+// countEvent records every operation contained in the event
+func countEvent(event fsnotify.Event) {
+	for _, op := range eventOps {
+		if event.Op.Has(op) {
+			eventsTotal.With(strings.ToLower(op.String())).Inc()
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Package metrics declares counters, gauges and histograms on a Prometheus
+// registry and serves them in the text exposition format
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// DefBuckets are the default histogram buckets, in seconds
+var DefBuckets = prometheus.DefBuckets
+
+// Default is the registry used by the package level constructors, it also
+// exports the Go runtime and process metrics
+var Default = NewRegistry()
+
+func init() {
+	Default.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+// Registry wraps a Prometheus registry so that packages can declare the
+// same metric more than once, e.g. when a service is started again
+type Registry struct {
+	*prometheus.Registry
+
+	mu         sync.Mutex
+	collectors map[string]prometheus.Collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		Registry:   prometheus.NewRegistry(),
+		collectors: make(map[string]prometheus.Collector),
+	}
+}
+
+// register creates and registers a metric, an existing metric with the same name is returned instead
+func (r *Registry) register(name string, create func() prometheus.Collector) prometheus.Collector {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c, ok := r.collectors[name]; ok {
+		return c
+	}
+	c := create()
+	r.MustRegister(c)
+	r.collectors[name] = c
+	return c
+}
+
+// Handler serves the registry over HTTP
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r, promhttp.HandlerOpts{})
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct{ vec *prometheus.CounterVec }
+
+// NewCounter registers a counter in the default registry
+func NewCounter(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounter(name, help, labels...)
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *CounterVec {
+	return r.register(name, func() prometheus.Collector {
+		return &CounterVec{prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)}
+	}).(*CounterVec)
+}
+
+// With returns the counter for the label values
+func (c *CounterVec) With(values ...string) prometheus.Counter {
+	return c.vec.WithLabelValues(values...)
+}
+
+func (c *CounterVec) Describe(ch chan<- *prometheus.Desc) { c.vec.Describe(ch) }
+func (c *CounterVec) Collect(ch chan<- prometheus.Metric) { c.vec.Collect(ch) }
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct{ vec *prometheus.GaugeVec }
+
+// NewGauge registers a gauge in the default registry
+func NewGauge(name, help string, labels ...string) *GaugeVec {
+	return Default.NewGauge(name, help, labels...)
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *GaugeVec {
+	return r.register(name, func() prometheus.Collector {
+		return &GaugeVec{prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)}
+	}).(*GaugeVec)
+}
+
+// With returns the gauge for the label values
+func (g *GaugeVec) With(values ...string) prometheus.Gauge {
+	return g.vec.WithLabelValues(values...)
+}
+
+// Delete removes the series of the label values
+func (g *GaugeVec) Delete(values ...string) {
+	g.vec.DeleteLabelValues(values...)
+}
+
+func (g *GaugeVec) Describe(ch chan<- *prometheus.Desc) { g.vec.Describe(ch) }
+func (g *GaugeVec) Collect(ch chan<- prometheus.Metric) { g.vec.Collect(ch) }
+
+// GaugeFunc is a gauge whose value is read when the metrics are collected
+type GaugeFunc struct {
+	prometheus.GaugeFunc
+	fn atomic.Pointer[func() float64]
+}
+
+// NewGaugeFunc registers a gauge func in the default registry
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return Default.NewGaugeFunc(name, help, fn)
+}
+
+// NewGaugeFunc registers a gauge func, registering the same name again replaces the function
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := r.register(name, func() prometheus.Collector {
+		g := &GaugeFunc{}
+		g.GaugeFunc = prometheus.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, func() float64 {
+			return (*g.fn.Load())()
+		})
+		return g
+	}).(*GaugeFunc)
+	g.fn.Store(&fn)
+	return g
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct{ vec *prometheus.HistogramVec }
+
+// NewHistogram registers a histogram in the default registry, nil buckets means DefBuckets
+func NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogram(name, help, buckets, labels...)
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return r.register(name, func() prometheus.Collector {
+		return &HistogramVec{prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets}, labels)}
+	}).(*HistogramVec)
+}
+
+// With returns the histogram for the label values
+func (h *HistogramVec) With(values ...string) prometheus.Observer {
+	return h.vec.WithLabelValues(values...)
+}
+
+func (h *HistogramVec) Describe(ch chan<- *prometheus.Desc) { h.vec.Describe(ch) }
+func (h *HistogramVec) Collect(ch chan<- prometheus.Metric) { h.vec.Collect(ch) }
@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestRegistryHandler(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("http_requests_total", "Total requests.", "route", "status")
+	requests.With("/api", "200").Inc()
+	requests.With("/api", "200").Add(2)
+	requests.With(`/a"b`+"\n", "500").Inc()
+	// declaring the same metric again returns the existing one
+	r.NewCounter("http_requests_total", "Total requests.", "route", "status").With("/api", "200").Inc()
+
+	r.NewGauge("queue_depth", "Queue depth.").With().Set(3)
+	r.NewGaugeFunc("cache_size", "Cache size.", func() float64 { return 1 })
+	// registering a gauge func again replaces the function
+	r.NewGaugeFunc("cache_size", "Cache size.", func() float64 { return 1.5 })
+
+	latency := r.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	latency.With("/api").Observe(0.05)
+	latency.With("/api").Observe(0.5)
+	latency.With("/api").Observe(2)
+
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if got := expfmt.ResponseFormat(w.Header()); got.FormatType() != expfmt.TypeTextPlain {
+		t.Fatalf("unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+
+	// 按文本格式规范解析输出
+	families, err := new(expfmt.TextParser).TextToMetricFamilies(w.Body)
+	if err != nil {
+		t.Fatalf("invalid text format: %v\n%s", err, w.Body.String())
+	}
+
+	value := func(name string, typ dto.MetricType, labels ...string) *dto.Metric {
+		t.Helper()
+		family, ok := families[name]
+		if !ok || family.GetType() != typ {
+			t.Fatalf("missing %s %s", typ, name)
+		}
+	next:
+		for _, m := range family.GetMetric() {
+			if len(m.GetLabel()) != len(labels)/2 {
+				continue
+			}
+			for i, l := range m.GetLabel() {
+				if l.GetName() != labels[2*i] || l.GetValue() != labels[2*i+1] {
+					continue next
+				}
+			}
+			return m
+		}
+		t.Fatalf("missing %s%v", name, labels)
+		return nil
+	}
+
+	if v := value("http_requests_total", dto.MetricType_COUNTER, "route", "/api", "status", "200").GetCounter().GetValue(); v != 4 {
+		t.Errorf("http_requests_total = %v, want 4", v)
+	}
+	if v := value("http_requests_total", dto.MetricType_COUNTER, "route", `/a"b`+"\n", "status", "500").GetCounter().GetValue(); v != 1 {
+		t.Errorf("escaped http_requests_total = %v, want 1", v)
+	}
+	if v := value("queue_depth", dto.MetricType_GAUGE).GetGauge().GetValue(); v != 3 {
+		t.Errorf("queue_depth = %v, want 3", v)
+	}
+	if v := value("cache_size", dto.MetricType_GAUGE).GetGauge().GetValue(); v != 1.5 {
+		t.Errorf("cache_size = %v, want 1.5", v)
+	}
+
+	h := value("latency_seconds", dto.MetricType_HISTOGRAM, "route", "/api").GetHistogram()
+	if h.GetSampleCount() != 3 || h.GetSampleSum() != 2.55 {
+		t.Errorf("latency_seconds count = %d, sum = %v", h.GetSampleCount(), h.GetSampleSum())
+	}
+	buckets := h.GetBucket()
+	if len(buckets) != 3 || buckets[0].GetUpperBound() != 0.1 || buckets[0].GetCumulativeCount() != 1 ||
+		buckets[1].GetUpperBound() != 1 || buckets[1].GetCumulativeCount() != 2 ||
+		!math.IsInf(buckets[2].GetUpperBound(), 1) || buckets[2].GetCumulativeCount() != 3 {
+		t.Errorf("latency_seconds buckets = %v", buckets)
+	}
+}
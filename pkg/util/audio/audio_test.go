@@ -0,0 +1,155 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+)
+
+func testPCM(n int) []byte {
+	pcm := make([]byte, n*2)
+	for i := 0; i < n; i++ {
+		v := int16(8000*math.Sin(float64(i)/7) + 3000*math.Sin(float64(i)/29))
+		if i%97 == 0 {
+			v = -32768
+		}
+		binary.LittleEndian.PutUint16(pcm[i*2:], uint16(v))
+	}
+	return pcm
+}
+
+func TestEncodeWAV(t *testing.T) {
+	pcm := testPCM(16000)
+	out := EncodeWAV(pcm, 16000, 1)
+	if len(out) != 44+len(pcm) || string(out[:4]) != "RIFF" || string(out[8:12]) != "WAVE" {
+		t.Fatalf("unexpected wav header: %q", out[:44])
+	}
+	if rate := binary.LittleEndian.Uint32(out[24:]); rate != 16000 {
+		t.Errorf("unexpected sample rate: %d", rate)
+	}
+	if d := Duration(pcm, 16000, 1); d != time.Second {
+		t.Errorf("unexpected duration: %s", d)
+	}
+}
+
+func TestEncodeFLAC(t *testing.T) {
+	for _, n := range []int{0, 1, 5000, FLACBlockSize * 2} {
+		pcm := testPCM(n)
+		out := EncodeFLAC(pcm, 24000)
+		if !bytes.HasPrefix(out, []byte("fLaC\x00\x00\x00\x22")) {
+			t.Fatalf("%d samples: unexpected stream header", n)
+		}
+
+		// skip the metadata blocks, the high bit of a block header marks the last one
+		data := out[4:]
+		for last := false; !last; {
+			last = data[0]&0x80 != 0
+			size := int(data[1])<<16 | int(data[2])<<8 | int(data[3])
+			data = data[4+size:]
+		}
+
+		var decoded []byte
+		for len(data) > 0 {
+			frame, size := decodeFLACFrame(t, data)
+			decoded = append(decoded, frame...)
+			data = data[size:]
+		}
+		if !bytes.Equal(decoded, pcm) {
+			t.Errorf("%d samples: decoded audio differs", n)
+		}
+		if n > FLACBlockSize && len(out) >= len(pcm) {
+			t.Errorf("%d samples: audio was not compressed", n)
+		}
+	}
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) uint64 {
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | uint64(bit)
+		r.pos++
+	}
+	return v
+}
+
+func (r *bitReader) signed(n int) int32 {
+	v := r.read(n)
+	return int32(int64(v<<(64-n)) >> (64 - n))
+}
+
+// decodeFLACFrame decodes the first frame of data in the subset of FLAC
+// produced by EncodeFLAC and returns its samples and size in bytes
+func decodeFLACFrame(t *testing.T, data []byte) ([]byte, int) {
+	r := &bitReader{data: data}
+	if r.read(14) != 0x3FFE {
+		t.Fatal("invalid sync code")
+	}
+	r.read(2 + 4 + 4 + 4 + 3 + 1)
+	for first := r.read(8); first&0xC0 == 0xC0; first <<= 1 {
+		r.read(8)
+	}
+	blockSize := int(r.read(16)) + 1
+	if crc8(data[:r.pos/8]) != byte(r.read(8)) {
+		t.Fatal("header crc mismatch")
+	}
+
+	samples := make([]int32, 0, blockSize)
+	header := r.read(8)
+	switch {
+	case header == 0x02:
+		for i := 0; i < blockSize; i++ {
+			samples = append(samples, r.signed(BitsPerSample))
+		}
+	case header>>4 == 0x1:
+		order := int(header >> 1 & 0x7)
+		for i := 0; i < order; i++ {
+			samples = append(samples, r.signed(BitsPerSample))
+		}
+		r.read(6)
+		k := int(r.read(4))
+		for len(samples) < blockSize {
+			var q uint64
+			for r.read(1) == 0 {
+				q++
+			}
+			u := q<<k | r.read(k)
+			res := int32(u>>1) ^ -int32(u&1)
+			s := samples
+			i := len(s)
+			var p int32
+			switch order {
+			case 1:
+				p = s[i-1]
+			case 2:
+				p = 2*s[i-1] - s[i-2]
+			case 3:
+				p = 3*s[i-1] - 3*s[i-2] + s[i-3]
+			case 4:
+				p = 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+			}
+			samples = append(samples, p+res)
+		}
+	default:
+		t.Fatalf("unexpected subframe type %x", header)
+	}
+
+	// the frame is padded to a byte boundary and ends with its CRC-16
+	size := (r.pos+7)/8 + 2
+	if crc16(data[:size-2]) != binary.BigEndian.Uint16(data[size-2:]) {
+		t.Fatal("frame crc mismatch")
+	}
+
+	out := make([]byte, 0, blockSize*2)
+	for _, s := range samples {
+		out = binary.LittleEndian.AppendUint16(out, uint16(s))
+	}
+	return out, size
+}
@@ -0,0 +1,289 @@
+package audio
+
+import (
+	"crypto/md5"
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	// FLACBlockSize is the number of samples per FLAC frame
+	FLACBlockSize = 4096
+
+	flacVendor = "chatlog"
+	maxRiceK   = 14 // 4-bit rice parameters, 15 is the escape code
+)
+
+// EncodeFLAC encodes mono 16-bit PCM as a lossless native FLAC stream
+//
+// Only fixed linear predictors are used, which keeps the encoder small
+// while still compressing speech to roughly half of the PCM size
+func EncodeFLAC(pcm []byte, sampleRate int) []byte {
+	samples := make([]int32, len(pcm)/2)
+	for i := range samples {
+		samples[i] = int32(int16(binary.LittleEndian.Uint16(pcm[i*2:])))
+	}
+
+	frames := make([][]byte, 0, len(samples)/FLACBlockSize+1)
+	minFrame, maxFrame := 0, 0
+	for i, n := 0, 0; i < len(samples); i, n = i+FLACBlockSize, n+1 {
+		frame := encodeFLACFrame(samples[i:min(i+FLACBlockSize, len(samples))], n, sampleRate)
+		if minFrame == 0 || len(frame) < minFrame {
+			minFrame = len(frame)
+		}
+		maxFrame = max(maxFrame, len(frame))
+		frames = append(frames, frame)
+	}
+
+	out := []byte("fLaC")
+	out = append(out, 0, 0, 0, 34) // STREAMINFO block header, not the last block
+	out = append(out, streamInfo(len(samples), sampleRate, minFrame, maxFrame, md5.Sum(pcm[:len(samples)*2]))...)
+
+	comment := []byte{0x84, 0, 0, 0} // last metadata block, VORBIS_COMMENT
+	comment = binary.LittleEndian.AppendUint32(comment, uint32(len(flacVendor)))
+	comment = append(comment, flacVendor...)
+	comment = binary.LittleEndian.AppendUint32(comment, 0)
+	length := len(comment) - 4
+	comment[1], comment[2], comment[3] = byte(length>>16), byte(length>>8), byte(length)
+	out = append(out, comment...)
+
+	for _, frame := range frames {
+		out = append(out, frame...)
+	}
+	return out
+}
+
+func streamInfo(total, sampleRate, minFrame, maxFrame int, sum [16]byte) []byte {
+	b := &bitWriter{}
+	blockSize := min(FLACBlockSize, max(total, 16))
+	b.write(uint64(blockSize), 16)
+	b.write(uint64(blockSize), 16)
+	b.write(uint64(minFrame), 24)
+	b.write(uint64(maxFrame), 24)
+	b.write(uint64(sampleRate), 20)
+	b.write(0, 3) // channels - 1
+	b.write(BitsPerSample-1, 5)
+	b.write(uint64(total), 36)
+	return append(b.bytes(), sum[:]...)
+}
+
+func encodeFLACFrame(samples []int32, number, sampleRate int) []byte {
+	b := &bitWriter{}
+	b.write(0x3FFE, 14) // sync code
+	b.write(0, 1)
+	b.write(0, 1) // fixed block size
+
+	b.write(0x7, 4) // 16-bit block size at the end of the header
+	rateCode, rateExtra, rateBits := flacRateCode(sampleRate)
+	b.write(rateCode, 4)
+	b.write(0, 4) // mono
+	b.write(0x4, 3)
+	b.write(0, 1)
+	for _, c := range utf8Number(uint64(number)) {
+		b.write(uint64(c), 8)
+	}
+	b.write(uint64(len(samples)-1), 16)
+	if rateBits > 0 {
+		b.write(rateExtra, rateBits)
+	}
+	b.write(uint64(crc8(b.bytes())), 8)
+
+	writeSubframe(b, samples)
+
+	out := b.bytes()
+	crc := crc16(out)
+	return append(out, byte(crc>>8), byte(crc))
+}
+
+// flacRateCode returns the frame header sample rate code and its optional trailing value
+func flacRateCode(rate int) (code, extra uint64, extraBits uint) {
+	switch rate {
+	case 8000:
+		return 0x4, 0, 0
+	case 16000:
+		return 0x5, 0, 0
+	case 22050:
+		return 0x6, 0, 0
+	case 24000:
+		return 0x7, 0, 0
+	case 32000:
+		return 0x8, 0, 0
+	case 44100:
+		return 0x9, 0, 0
+	case 48000:
+		return 0xA, 0, 0
+	}
+	if rate%1000 == 0 && rate/1000 < 256 {
+		return 0xC, uint64(rate / 1000), 8
+	}
+	return 0xD, uint64(rate), 16
+}
+
+// writeSubframe picks the fixed predictor with the cheapest residual,
+// falling back to verbatim samples when prediction does not help
+func writeSubframe(b *bitWriter, samples []int32) {
+	bestOrder, bestK, bestCost := -1, 0, len(samples)*BitsPerSample
+	var bestResidual []int32
+	for order := 0; order <= 4 && order < len(samples); order++ {
+		residual := fixedResidual(samples, order)
+		k, cost := riceParam(residual)
+		cost += order*BitsPerSample + 6 + 4
+		if cost < bestCost {
+			bestOrder, bestK, bestCost, bestResidual = order, k, cost, residual
+		}
+	}
+
+	if bestOrder < 0 {
+		b.write(0x02, 8) // VERBATIM
+		for _, s := range samples {
+			b.write(uint64(uint32(s)), BitsPerSample)
+		}
+		return
+	}
+
+	b.write(uint64(0x08|bestOrder)<<1, 8) // FIXED subframe, no wasted bits
+	for _, s := range samples[:bestOrder] {
+		b.write(uint64(uint32(s)), BitsPerSample)
+	}
+	b.write(0, 2) // rice coding with 4-bit parameters
+	b.write(0, 4) // a single partition
+	b.write(uint64(bestK), 4)
+	for _, r := range bestResidual {
+		u := uint64(uint32(r<<1) ^ uint32(r>>31))
+		b.unary(u >> bestK)
+		b.write(u, uint(bestK))
+	}
+}
+
+// fixedResidual returns the prediction error of the fixed predictor of the given order
+func fixedResidual(s []int32, order int) []int32 {
+	r := make([]int32, 0, len(s)-order)
+	for i := order; i < len(s); i++ {
+		var p int32
+		switch order {
+		case 0:
+			p = 0
+		case 1:
+			p = s[i-1]
+		case 2:
+			p = 2*s[i-1] - s[i-2]
+		case 3:
+			p = 3*s[i-1] - 3*s[i-2] + s[i-3]
+		case 4:
+			p = 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+		}
+		r = append(r, s[i]-p)
+	}
+	return r
+}
+
+// riceParam returns the rice parameter with the smallest encoded size and that size in bits
+func riceParam(residual []int32) (int, int) {
+	if len(residual) == 0 {
+		return 0, 0
+	}
+	var sum uint64
+	for _, r := range residual {
+		sum += uint64(uint32(r<<1) ^ uint32(r>>31))
+	}
+	mean := sum / uint64(len(residual))
+	guess := 0
+	if mean > 0 {
+		guess = bits.Len64(mean) - 1
+	}
+
+	bestK, bestCost := 0, -1
+	for k := max(0, guess-1); k <= min(maxRiceK, guess+1); k++ {
+		cost := len(residual) * (k + 1)
+		for _, r := range residual {
+			cost += int(uint64(uint32(r<<1)^uint32(r>>31)) >> k)
+		}
+		if bestCost < 0 || cost < bestCost {
+			bestK, bestCost = k, cost
+		}
+	}
+	return bestK, bestCost
+}
+
+// utf8Number encodes a frame number with the extended UTF-8 scheme used by FLAC
+func utf8Number(v uint64) []byte {
+	if v < 0x80 {
+		return []byte{byte(v)}
+	}
+	n := 2
+	for v >= 1<<(5*n+1) {
+		n++
+	}
+	out := make([]byte, n)
+	for i := n - 1; i > 0; i-- {
+		out[i] = 0x80 | byte(v&0x3F)
+		v >>= 6
+	}
+	out[0] = byte(0xFF<<(8-n)) | byte(v)
+	return out
+}
+
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nbits uint
+}
+
+// write appends the lowest n bits of v, n must not exceed 56
+func (w *bitWriter) write(v uint64, n uint) {
+	if n == 0 {
+		return
+	}
+	w.acc = w.acc<<n | v&(1<<n-1)
+	w.nbits += n
+	for w.nbits >= 8 {
+		w.nbits -= 8
+		w.buf = append(w.buf, byte(w.acc>>w.nbits))
+	}
+}
+
+// unary appends q zero bits followed by a one bit
+func (w *bitWriter) unary(q uint64) {
+	for ; q >= 32; q -= 32 {
+		w.write(0, 32)
+	}
+	w.write(1, uint(q)+1)
+}
+
+// bytes returns the written data padded with zero bits to a byte boundary
+func (w *bitWriter) bytes() []byte {
+	if w.nbits > 0 {
+		return append(w.buf[:len(w.buf):len(w.buf)], byte(w.acc<<(8-w.nbits)))
+	}
+	return w.buf
+}
+
+func crc8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func crc16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x8005
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
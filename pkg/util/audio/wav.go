@@ -0,0 +1,45 @@
+// Package audio encodes 16-bit little-endian PCM into container formats
+// that can be produced without external codecs
+package audio
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// BitsPerSample is the sample size of all PCM data handled by this package
+const BitsPerSample = 16
+
+// Duration returns the playback duration of 16-bit PCM data
+func Duration(pcm []byte, sampleRate, channels int) time.Duration {
+	if sampleRate <= 0 || channels <= 0 {
+		return 0
+	}
+	samples := len(pcm) / (BitsPerSample / 8 * channels)
+	return time.Duration(samples) * time.Second / time.Duration(sampleRate)
+}
+
+// EncodeWAV wraps 16-bit PCM data into a RIFF WAVE file
+func EncodeWAV(pcm []byte, sampleRate, channels int) []byte {
+	blockAlign := channels * BitsPerSample / 8
+	size := len(pcm) - len(pcm)%blockAlign
+
+	out := make([]byte, 44+size)
+	copy(out[0:], "RIFF")
+	binary.LittleEndian.PutUint32(out[4:], uint32(36+size))
+	copy(out[8:], "WAVE")
+
+	copy(out[12:], "fmt ")
+	binary.LittleEndian.PutUint32(out[16:], 16)
+	binary.LittleEndian.PutUint16(out[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(out[22:], uint16(channels))
+	binary.LittleEndian.PutUint32(out[24:], uint32(sampleRate))
+	binary.LittleEndian.PutUint32(out[28:], uint32(sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(out[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(out[34:], BitsPerSample)
+
+	copy(out[36:], "data")
+	binary.LittleEndian.PutUint32(out[40:], uint32(size))
+	copy(out[44:], pcm[:size])
+	return out
+}
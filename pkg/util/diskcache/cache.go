@@ -0,0 +1,152 @@
+// Package diskcache implements a size limited file cache with LRU eviction
+package diskcache
+
+import (
+	"container/list"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the default maximum total size of cached files
+const DefaultSize = 256 << 20
+
+// Cache stores files on disk and evicts the least recently used files
+// once the total size exceeds the limit
+type Cache struct {
+	dir     string
+	maxSize int64
+
+	mu      sync.Mutex
+	loaded  bool
+	size    int64
+	lru     *list.List // front is the most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	name string
+	size int64
+}
+
+// New creates a cache in dir, files left by previous runs are loaded lazily
+func New(dir string, maxSize int64) *Cache {
+	if maxSize <= 0 {
+		maxSize = DefaultSize
+	}
+	return &Cache{
+		dir:     dir,
+		maxSize: maxSize,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns a cached file and marks it as recently used
+func (c *Cache) Get(name string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	e, ok := c.entries[name]
+	if !ok {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(c.dir, name))
+	if err != nil {
+		c.remove(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	// keep the access order across restarts
+	now := time.Now()
+	os.Chtimes(filepath.Join(c.dir, name), now, now)
+	return data, true
+}
+
+// Put writes a file into the cache and evicts old files if needed
+func (c *Cache) Put(name string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return err
+	}
+	// write to a temporary file first so readers never see partial data
+	tmp := filepath.Join(c.dir, name+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if e, ok := c.entries[name]; ok {
+		c.size -= e.Value.(*cacheEntry).size
+		e.Value.(*cacheEntry).size = int64(len(data))
+		c.lru.MoveToFront(e)
+	} else {
+		c.entries[name] = c.lru.PushFront(&cacheEntry{name: name, size: int64(len(data))})
+	}
+	c.size += int64(len(data))
+
+	for c.size > c.maxSize && c.lru.Len() > 1 {
+		e := c.lru.Back()
+		os.Remove(filepath.Join(c.dir, e.Value.(*cacheEntry).name))
+		c.remove(e)
+	}
+	return nil
+}
+
+// Size returns the total size of cached files
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.load()
+	return c.size
+}
+
+func (c *Cache) remove(e *list.Element) {
+	entry := e.Value.(*cacheEntry)
+	c.size -= entry.size
+	delete(c.entries, entry.name)
+	c.lru.Remove(e)
+}
+
+// load indexes the files left by previous runs, ordered by modification time
+func (c *Cache) load() {
+	if c.loaded {
+		return
+	}
+	c.loaded = true
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	type file struct {
+		name    string
+		size    int64
+		modTime time.Time
+	}
+	files := make([]file, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) == ".tmp" {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, file{name: entry.Name(), size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.After(files[j].modTime) })
+	for _, f := range files {
+		c.entries[f.name] = c.lru.PushBack(&cacheEntry{name: f.name, size: f.size})
+		c.size += f.size
+	}
+}
@@ -0,0 +1,27 @@
+package diskcache
+
+import "testing"
+
+func TestCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir, 10)
+	c.Put("a", []byte("12345"))
+	c.Put("b", []byte("12345"))
+	if _, ok := c.Get("a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	c.Put("c", []byte("12345"))
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b should be evicted as least recently used")
+	}
+	if _, ok := c.Get("a"); !ok {
+		t.Error("a should be kept")
+	}
+
+	// files left by a previous run are indexed again
+	reloaded := New(dir, 10)
+	if reloaded.Size() != 10 {
+		t.Errorf("unexpected reloaded size: %d", reloaded.Size())
+	}
+}
@@ -1,29 +1,186 @@
 package silk
 
 import (
+	"bytes"
+	"crypto/md5"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/sjzar/go-lame"
 	"github.com/sjzar/go-silk"
+
+	"github.com/sjzar/chatlog/pkg/util/audio"
+	"github.com/sjzar/chatlog/pkg/util/diskcache"
 )
 
-func Silk2MP3(data []byte) ([]byte, error) {
+// Format is the output format of a transcoded voice message
+type Format string
+
+const (
+	FormatMP3  Format = "mp3"
+	FormatWAV  Format = "wav"  // 16-bit PCM
+	FormatFLAC Format = "flac" // lossless native FLAC
+	FormatRaw  Format = "raw"  // the original SILK data
+)
+
+const (
+	DefaultSampleRate = 24000
+	DefaultBitrate    = 16 // kbps, MP3 only
+
+	// FrameDuration is the duration of every SILK frame in a voice message
+	FrameDuration = 20 * time.Millisecond
+)
+
+// SampleRates are the output sample rates supported by the SILK decoder
+var SampleRates = []int{8000, 12000, 16000, 24000, 32000, 44100, 48000}
+
+var header = []byte("#!SILK_V3")
+
+// Options describes the transcoding output
+type Options struct {
+	Format     Format
+	SampleRate int // Hz, defaults to DefaultSampleRate
+	Bitrate    int // kbps, defaults to DefaultBitrate
+}
+
+// ParseFormat parses an output format, an empty string means FormatMP3
+func ParseFormat(s string) (Format, bool) {
+	switch f := Format(strings.ToLower(s)); f {
+	case "":
+		return FormatMP3, true
+	case FormatMP3, FormatWAV, FormatFLAC, FormatRaw:
+		return f, true
+	}
+	return "", false
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatWAV:
+		return "audio/wav"
+	case FormatFLAC:
+		return "audio/flac"
+	case FormatRaw:
+		return "audio/silk"
+	}
+	return "audio/mp3"
+}
+
+// Normalize fills in defaults and validates the options
+func (o Options) Normalize() (Options, error) {
+	if o.Format == "" {
+		o.Format = FormatMP3
+	}
+	if o.SampleRate == 0 {
+		o.SampleRate = DefaultSampleRate
+	}
+	if o.Bitrate == 0 {
+		o.Bitrate = DefaultBitrate
+	}
+	if _, ok := ParseFormat(string(o.Format)); !ok {
+		return o, fmt.Errorf("unsupported format: %s", o.Format)
+	}
+	valid := false
+	for _, rate := range SampleRates {
+		valid = valid || rate == o.SampleRate
+	}
+	if !valid {
+		return o, fmt.Errorf("unsupported sample rate: %d", o.SampleRate)
+	}
+	if o.Bitrate < 8 || o.Bitrate > 320 {
+		return o, fmt.Errorf("unsupported bitrate: %d", o.Bitrate)
+	}
+	return o, nil
+}
+
+// String returns a stable representation used in cache keys
+func (o Options) String() string {
+	switch o.Format {
+	case FormatRaw:
+		return string(o.Format)
+	case FormatMP3:
+		return fmt.Sprintf("%s_%d_%d", o.Format, o.SampleRate, o.Bitrate)
+	}
+	return fmt.Sprintf("%s_%d", o.Format, o.SampleRate)
+}
+
+// Duration returns the playback duration of SILK data by counting its frames
+func Duration(data []byte) time.Duration {
+	data = bytes.TrimPrefix(data, []byte{0x02})
+	if !bytes.HasPrefix(data, header) {
+		return 0
+	}
+	data = data[len(header):]
+
+	frames := 0
+	for len(data) >= 2 {
+		n := int(int16(binary.LittleEndian.Uint16(data)))
+		data = data[2:]
+		if n <= 0 {
+			continue
+		}
+		if len(data) < n {
+			break
+		}
+		data = data[n:]
+		frames++
+	}
+	return time.Duration(frames) * FrameDuration
+}
+
+// Decode decodes SILK data into 16-bit mono PCM at the given sample rate
+func Decode(data []byte, sampleRate int) (pcm []byte, err error) {
+	// the decoder panics on truncated or malformed data
+	defer func() {
+		if r := recover(); r != nil {
+			pcm, err = nil, fmt.Errorf("silk decode failed: %v", r)
+		}
+	}()
 
 	sd := silk.SilkInit()
 	defer sd.Close()
+	sd.SetSampleRate(sampleRate)
 
 	pcmdata := sd.Decode(data)
 	if len(pcmdata) == 0 {
 		return nil, fmt.Errorf("silk decode failed")
 	}
+	return pcmdata, nil
+}
+
+// Transcode converts SILK data into the requested format
+func Transcode(data []byte, opts Options) ([]byte, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	if opts.Format == FormatRaw {
+		return data, nil
+	}
+
+	pcmdata, err := Decode(data, opts.SampleRate)
+	if err != nil {
+		return nil, err
+	}
+
+	switch opts.Format {
+	case FormatWAV:
+		return audio.EncodeWAV(pcmdata, opts.SampleRate, 1), nil
+	case FormatFLAC:
+		return audio.EncodeFLAC(pcmdata, opts.SampleRate), nil
+	}
 
 	le := lame.Init()
 	defer le.Close()
 
-	le.SetInSamplerate(24000)
-	le.SetOutSamplerate(24000)
+	le.SetInSamplerate(opts.SampleRate)
+	le.SetOutSamplerate(opts.SampleRate)
 	le.SetNumChannels(1)
-	le.SetBitrate(16)
+	le.SetBitrate(opts.Bitrate)
 	// IMPORTANT!
 	le.InitParams()
 
@@ -31,6 +188,51 @@ func Silk2MP3(data []byte) ([]byte, error) {
 	if len(mp3data) == 0 {
 		return nil, fmt.Errorf("mp3 encode failed")
 	}
+	mp3data = append(mp3data, le.Flush()...)
 
 	return mp3data, nil
 }
+
+func Silk2MP3(data []byte) ([]byte, error) {
+	return Transcode(data, Options{Format: FormatMP3})
+}
+
+// Transcoder transcodes voice messages and caches the output on disk,
+// keyed by the md5 of the SILK data and the output options
+type Transcoder struct {
+	cache *diskcache.Cache
+}
+
+// NewTranscoder creates a transcoder, an empty cacheDir disables caching
+func NewTranscoder(cacheDir string, maxCacheSize int64) *Transcoder {
+	t := &Transcoder{}
+	if cacheDir != "" {
+		t.cache = diskcache.New(cacheDir, maxCacheSize)
+	}
+	return t
+}
+
+// Transcode returns the transcoded data, see Transcode
+func (t *Transcoder) Transcode(data []byte, opts Options) ([]byte, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	if t.cache == nil || opts.Format == FormatRaw {
+		return Transcode(data, opts)
+	}
+
+	sum := md5.Sum(data)
+	name := hex.EncodeToString(sum[:]) + "_" + opts.String()
+	if out, ok := t.cache.Get(name); ok {
+		return out, nil
+	}
+
+	out, err := Transcode(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	// a failed cache write only costs a transcode next time
+	_ = t.cache.Put(name, out)
+	return out, nil
+}
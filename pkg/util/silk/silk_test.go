@@ -0,0 +1,37 @@
+package silk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuration(t *testing.T) {
+	data := append([]byte{0x02}, header...)
+	for i := 0; i < 3; i++ {
+		data = append(data, 4, 0, 1, 2, 3, 4)
+	}
+	data = append(data, 0xFF, 0xFF) // end marker
+
+	if d := Duration(data); d != 60*time.Millisecond {
+		t.Errorf("unexpected duration: %s", d)
+	}
+	if d := Duration([]byte("not silk")); d != 0 {
+		t.Errorf("unexpected duration for invalid data: %s", d)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	opts, err := Options{}.Normalize()
+	if err != nil || opts.String() != "mp3_24000_16" {
+		t.Errorf("unexpected default options: %s %v", opts, err)
+	}
+	if opts, _ := (Options{Format: FormatWAV, SampleRate: 16000}).Normalize(); opts.String() != "wav_16000" {
+		t.Errorf("unexpected wav options: %s", opts)
+	}
+	if _, err := (Options{SampleRate: 11025}).Normalize(); err == nil {
+		t.Error("expected error for unsupported sample rate")
+	}
+	if _, err := (Options{Bitrate: 1000}).Normalize(); err == nil {
+		t.Error("expected error for unsupported bitrate")
+	}
+}
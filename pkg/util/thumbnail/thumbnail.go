@@ -0,0 +1,304 @@
+package thumbnail
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+
+	"github.com/sjzar/chatlog/pkg/util/diskcache"
+)
+
+// Fit defines how an image is scaled into the target box
+type Fit string
+
+const (
+	// FitContain scales the image to fit within the box, keeping the aspect ratio
+	FitContain Fit = "contain"
+	// FitCover scales the image to cover the box and crops the overflow from the center
+	FitCover Fit = "cover"
+	// FitFill stretches the image to exactly the box size
+	FitFill Fit = "fill"
+)
+
+const (
+	// MaxSize is the largest width or height accepted for a thumbnail
+	MaxSize = 4096
+
+	// MaxSourcePixels is the largest source image (width x height) that will be decoded,
+	// larger images are rejected before any pixel data is allocated
+	MaxSourcePixels = 64 << 20
+
+	JPEGQuality = 85
+
+	// DefaultCacheSize is the default maximum total size of cached thumbnails
+	DefaultCacheSize = diskcache.DefaultSize
+)
+
+// Options describes the requested thumbnail size
+// A zero width or height is derived from the other side using the aspect ratio
+type Options struct {
+	Width  int
+	Height int
+	Fit    Fit
+}
+
+// ParseFit parses a fit mode, an empty string means FitContain
+func ParseFit(s string) (Fit, bool) {
+	switch Fit(strings.ToLower(s)) {
+	case "", FitContain:
+		return FitContain, true
+	case FitCover:
+		return FitCover, true
+	case FitFill:
+		return FitFill, true
+	}
+	return "", false
+}
+
+// Valid reports whether the options describe a usable size
+func (o Options) Valid() bool {
+	return (o.Width > 0 || o.Height > 0) && o.Width >= 0 && o.Height >= 0 && o.Width <= MaxSize && o.Height <= MaxSize
+}
+
+// String returns a stable representation used in cache keys
+func (o Options) String() string {
+	fit := o.Fit
+	if fit == "" {
+		fit = FitContain
+	}
+	return fmt.Sprintf("%dx%d_%s", o.Width, o.Height, fit)
+}
+
+// Make decodes a JPEG, PNG or GIF image, resizes it and encodes the result
+// JPEG stays JPEG, everything else is encoded as PNG; only the first frame of a GIF is used
+// Images are never scaled up
+func Make(data []byte, opts Options) ([]byte, string, error) {
+	if !opts.Valid() {
+		return nil, "", fmt.Errorf("invalid thumbnail size: %s", opts)
+	}
+
+	// check the declared size first, a tiny file can declare a huge image
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image failed: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > MaxSourcePixels {
+		return nil, "", fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	src, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image failed: %w", err)
+	}
+
+	dst := Resize(src, opts)
+
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: JPEGQuality}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "jpg", nil
+	default:
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "png", nil
+	}
+}
+
+// Thumbnailer creates thumbnails and caches them on disk,
+// keyed by the md5 of the source image and the requested size
+type Thumbnailer struct {
+	cache *diskcache.Cache
+}
+
+// NewThumbnailer creates a thumbnailer, an empty cacheDir disables caching
+func NewThumbnailer(cacheDir string, maxCacheSize int64) *Thumbnailer {
+	t := &Thumbnailer{}
+	if cacheDir != "" {
+		t.cache = diskcache.New(cacheDir, maxCacheSize)
+	}
+	return t
+}
+
+// Thumbnail returns the resized image and its extension, see Make
+func (t *Thumbnailer) Thumbnail(data []byte, opts Options) ([]byte, string, error) {
+	if t.cache == nil {
+		return Make(data, opts)
+	}
+
+	sum := md5.Sum(data)
+	name := hex.EncodeToString(sum[:]) + "_" + opts.String()
+	if out, ok := t.cache.Get(name); ok {
+		return out, extOf(out), nil
+	}
+
+	out, ext, err := Make(data, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	// a failed cache write only costs a resize next time
+	_ = t.cache.Put(name, out)
+	return out, ext, nil
+}
+
+func extOf(data []byte) string {
+	if bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return "jpg"
+	}
+	return "png"
+}
+
+// Resize scales src according to opts using an area-averaging filter
+func Resize(src image.Image, opts Options) image.Image {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	if sw == 0 || sh == 0 {
+		return src
+	}
+
+	w, h := opts.Width, opts.Height
+	crop := sb
+	switch {
+	case w == 0:
+		w = max(1, sw*h/sh)
+	case h == 0:
+		h = max(1, sh*w/sw)
+	case opts.Fit == FitFill:
+	case opts.Fit == FitCover:
+		// crop the source to the target aspect ratio
+		if sw*h > sh*w {
+			cw := sh * w / h
+			crop = image.Rect(sb.Min.X+(sw-cw)/2, sb.Min.Y, sb.Min.X+(sw-cw)/2+cw, sb.Max.Y)
+		} else {
+			ch := sw * h / w
+			crop = image.Rect(sb.Min.X, sb.Min.Y+(sh-ch)/2, sb.Max.X, sb.Min.Y+(sh-ch)/2+ch)
+		}
+	default:
+		if sw*h > sh*w {
+			h = max(1, sh*w/sw)
+		} else {
+			w = max(1, sw*h/sh)
+		}
+	}
+
+	// never scale up, shrink the target box instead
+	if w > crop.Dx() || h > crop.Dy() {
+		scale := min(float64(crop.Dx())/float64(w), float64(crop.Dy())/float64(h))
+		w = min(crop.Dx(), max(1, int(float64(w)*scale+0.5)))
+		h = min(crop.Dy(), max(1, int(float64(h)*scale+0.5)))
+	}
+	if w == sw && h == sh && crop == sb {
+		return src
+	}
+
+	return resample(toNRGBA(src, crop), w, h)
+}
+
+// toNRGBA copies the cropped area into an NRGBA image with origin (0, 0)
+func toNRGBA(src image.Image, r image.Rectangle) *image.NRGBA {
+	dst := image.NewNRGBA(image.Rect(0, 0, r.Dx(), r.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, r.Min, draw.Src)
+	return dst
+}
+
+// resample scales src to w x h, each destination pixel is the weighted average
+// of the source area it covers
+func resample(src *image.NRGBA, w, h int) *image.NRGBA {
+	sw, sh := src.Bounds().Dx(), src.Bounds().Dy()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	xw := weights(sw, w)
+	yw := weights(sh, h)
+
+	// accumulate premultiplied values to avoid dark fringes around transparent pixels
+	row := make([]float64, w*4)
+	for dy := 0; dy < h; dy++ {
+		for i := range row {
+			row[i] = 0
+		}
+		var total float64
+		for _, ys := range yw[dy] {
+			total += ys.w
+			off := ys.i * src.Stride
+			for dx := 0; dx < w; dx++ {
+				var r, g, b, a float64
+				for _, xs := range xw[dx] {
+					p := src.Pix[off+xs.i*4 : off+xs.i*4+4]
+					pa := float64(p[3]) * xs.w
+					r += float64(p[0]) * pa
+					g += float64(p[1]) * pa
+					b += float64(p[2]) * pa
+					a += pa
+				}
+				row[dx*4] += r * ys.w
+				row[dx*4+1] += g * ys.w
+				row[dx*4+2] += b * ys.w
+				row[dx*4+3] += a * ys.w
+			}
+		}
+
+		off := dy * dst.Stride
+		for dx := 0; dx < w; dx++ {
+			a := row[dx*4+3]
+			if a == 0 {
+				continue
+			}
+			p := dst.Pix[off+dx*4 : off+dx*4+4]
+			p[0] = clamp(row[dx*4] / a)
+			p[1] = clamp(row[dx*4+1] / a)
+			p[2] = clamp(row[dx*4+2] / a)
+			p[3] = clamp(a / (total * sumWeights(xw[dx])))
+		}
+	}
+	return dst
+}
+
+type weight struct {
+	i int
+	w float64
+}
+
+// weights returns for every destination index the source indexes it covers
+// and how much of each source pixel falls into it
+func weights(src, dst int) [][]weight {
+	ret := make([][]weight, dst)
+	scale := float64(src) / float64(dst)
+	for d := 0; d < dst; d++ {
+		start, end := float64(d)*scale, float64(d+1)*scale
+		for s := int(start); s < src && float64(s) < end; s++ {
+			w := min(end, float64(s+1)) - max(start, float64(s))
+			if w > 0 {
+				ret[d] = append(ret[d], weight{i: s, w: w})
+			}
+		}
+	}
+	return ret
+}
+
+func sumWeights(ws []weight) float64 {
+	var sum float64
+	for _, w := range ws {
+		sum += w.w
+	}
+	return sum
+}
+
+func clamp(v float64) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
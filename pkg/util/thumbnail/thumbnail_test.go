@@ -0,0 +1,82 @@
+package thumbnail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func testImage(w, h int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.NRGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+func TestMake(t *testing.T) {
+	src := testImage(200, 100)
+	tests := []struct {
+		opts Options
+		w, h int
+	}{
+		{Options{Width: 100}, 100, 50},
+		{Options{Height: 20}, 40, 20},
+		{Options{Width: 50, Height: 50}, 50, 25},
+		{Options{Width: 50, Height: 50, Fit: FitCover}, 50, 50},
+		{Options{Width: 50, Height: 50, Fit: FitFill}, 50, 50},
+		{Options{Width: 400}, 200, 100},
+		{Options{Width: 400, Height: 400, Fit: FitCover}, 100, 100},
+	}
+	for _, tt := range tests {
+		out, ext, err := Make(src, tt.opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.opts, err)
+		}
+		img, err := png.Decode(bytes.NewReader(out))
+		if err != nil || ext != "png" {
+			t.Fatalf("%s: unexpected output %s: %v", tt.opts, ext, err)
+		}
+		if b := img.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("%s: got %dx%d, want %dx%d", tt.opts, b.Dx(), b.Dy(), tt.w, tt.h)
+		}
+	}
+
+	if _, _, err := Make(src, Options{}); err == nil {
+		t.Error("expected error for empty size")
+	}
+
+	// a small PNG declaring 100000x100000 pixels must be rejected before decoding
+	bomb := bytes.Clone(testImage(1, 1))
+	binary.BigEndian.PutUint32(bomb[16:], 100000)
+	binary.BigEndian.PutUint32(bomb[20:], 100000)
+	binary.BigEndian.PutUint32(bomb[29:], crc32.ChecksumIEEE(bomb[12:29]))
+	if _, _, err := Make(bomb, Options{Width: 100}); err == nil || !strings.Contains(err.Error(), "too large") {
+		t.Errorf("expected too large error, got %v", err)
+	}
+}
+
+func TestThumbnailer(t *testing.T) {
+	th := NewThumbnailer(t.TempDir(), 0)
+	src := testImage(64, 64)
+	first, _, err := th.Thumbnail(src, Options{Width: 16})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, ext, err := th.Thumbnail(src, Options{Width: 16})
+	if err != nil || ext != "png" || !bytes.Equal(first, second) {
+		t.Errorf("cached thumbnail mismatch: %s %v", ext, err)
+	}
+	if !strings.Contains(Options{Width: 16}.String(), "16x0") {
+		t.Errorf("unexpected key: %s", Options{Width: 16})
+	}
+}